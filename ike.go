@@ -7,6 +7,7 @@ import (
 
 	"github.com/nathaniel-bennett/ike/message"
 	"github.com/nathaniel-bennett/ike/security"
+	ikeCrypto "github.com/nathaniel-bennett/ike/security/IKECrypto"
 )
 
 func EncodeEncrypt(
@@ -62,6 +63,9 @@ func DecodeDecrypt(
 	return ikeMsg, nil
 }
 
+// verifyIntegrity compares checksum, the ICV received in the Encrypted
+// payload, with the truncated ICV calculateIntegrity computes over originData,
+// in constant time
 func verifyIntegrity(
 	originData []byte,
 	checksum []byte,
@@ -81,6 +85,12 @@ func verifyIntegrity(
 	return nil
 }
 
+// calculateIntegrity returns the ICV of originData: the Integ_i or Integ_r
+// hashes built by GenerateKeyForIKESA output the full HMAC, which is
+// truncated here to the GetOutputLength of the integrity algorithm, such as
+// 24 bytes for AUTH_HMAC_SHA2_384_192 (RFC 4868 section 2.3). Both the ICV
+// sent by encryptMsg and the one checked by verifyIntegrity are truncated
+// here.
 func calculateIntegrity(
 	ikesaKey *security.IKESAKey,
 	role message.Role,
@@ -154,6 +164,20 @@ func decryptPayload(
 	return plainText, nil
 }
 
+// aeadCrypto returns the AEAD crypto of the initiator or of the responder
+func aeadCrypto(ikesaKey *security.IKESAKey, initiator bool) (ikeCrypto.IKECryptoAEAD, error) {
+	crypto := ikesaKey.Encr_r
+	if initiator {
+		crypto = ikesaKey.Encr_i
+	}
+	aead, ok := crypto.(ikeCrypto.IKECryptoAEAD)
+	if !ok {
+		return nil, errors.Errorf("Encryption algorithm[%d] has no AEAD crypto",
+			ikesaKey.EncrInfo.TransformID())
+	}
+	return aead, nil
+}
+
 func decryptMsg(
 	msg []byte,
 	ikeMsg *message.IKEMessage,
@@ -172,14 +196,15 @@ func decryptMsg(
 	}
 
 	// Check if the context contain needed data
-	if ikesaKey.IntegInfo == nil {
-		return nil, errors.Errorf("decryptMsg(): No integrity algorithm specified")
-	}
 	if ikesaKey.EncrInfo == nil {
 		return nil, errors.Errorf("decryptMsg(): No encryption algorithm specified")
 	}
+	aead := ikesaKey.EncrInfo.IsAEAD()
+	if !aead && ikesaKey.IntegInfo == nil {
+		return nil, errors.Errorf("decryptMsg(): No integrity algorithm specified")
+	}
 
-	if ikesaKey.Integ_i == nil {
+	if !aead && ikesaKey.Integ_i == nil {
 		return nil, errors.Errorf("decryptMsg(): No initiator's integrity key")
 	}
 	if ikesaKey.Encr_i == nil {
@@ -198,9 +223,56 @@ func decryptMsg(
 		}
 	}
 
-	checksumLength := ikesaKey.IntegInfo.GetOutputLength()
+	var plainText []byte
+	if aead {
+		// The associated data is everything preceding the IV
+		crypto, err := aeadCrypto(ikesaKey, role != message.Role_Initiator)
+		if err != nil {
+			return nil, errors.Wrapf(err, "decryptMsg()")
+		}
+		// The ICV is the trailing bytes of the negotiated length: anything
+		// shorter than the IV and the ICV cannot come from the transform
+		minLength := ikesaKey.EncrInfo.GetIVLength() + ikesaKey.EncrInfo.GetICVLength()
+		if len(encryptedPayload.EncryptedData) < minLength {
+			return nil, errors.Errorf("decryptMsg(): Encrypted data length %d shorter than IV and ICV length %d",
+				len(encryptedPayload.EncryptedData), minLength)
+		}
+		aad := msg[:len(msg)-len(encryptedPayload.EncryptedData)]
+		if plainText, err = crypto.Open(encryptedPayload.EncryptedData, aad); err != nil {
+			return nil, errors.Wrapf(err, "decryptMsg(): Error decrypting message")
+		}
+	} else {
+		var err error
+		if plainText, err = verifyAndDecrypt(msg, encryptedPayload, ikesaKey, role); err != nil {
+			return nil, err
+		}
+	}
+
+	var decryptedPayloads message.IKEPayloadContainer
+	err := decryptedPayloads.Decode(encryptedPayload.NextPayload, plainText)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decryptMsg(): Decoding decrypted payload failed")
+	}
+
+	ikeMsg.Payloads.Reset()
+	ikeMsg.Payloads = append(ikeMsg.Payloads, decryptedPayloads...)
+	return ikeMsg, nil
+}
+
+// verifyAndDecrypt checks the checksum of msg with the integrity algorithm,
+// then decrypts its Encrypted payload
+func verifyAndDecrypt(
+	msg []byte,
+	encryptedPayload *message.Encrypted,
+	ikesaKey *security.IKESAKey,
+	role message.Role,
+) ([]byte, error) {
+	checksumOffset, checksumLength := ikesaKey.ICVOffset(len(encryptedPayload.EncryptedData))
+	if len(encryptedPayload.EncryptedData) < checksumLength {
+		return nil, errors.Errorf("decryptMsg(): Encrypted payload shorter than checksum")
+	}
 	// Checksum
-	checksum := encryptedPayload.EncryptedData[len(encryptedPayload.EncryptedData)-checksumLength:]
+	checksum := encryptedPayload.EncryptedData[checksumOffset:]
 
 	err := verifyIntegrity(msg[:len(msg)-checksumLength], checksum, ikesaKey, !role)
 	if err != nil {
@@ -208,21 +280,12 @@ func decryptMsg(
 	}
 
 	// Decrypt
-	encryptedData := encryptedPayload.EncryptedData[:len(encryptedPayload.EncryptedData)-checksumLength]
+	encryptedData := encryptedPayload.EncryptedData[:checksumOffset]
 	plainText, err := decryptPayload(encryptedData, ikesaKey, role)
 	if err != nil {
 		return nil, errors.Wrapf(err, "decryptMsg(): Error decrypting message")
 	}
-
-	var decryptedPayloads message.IKEPayloadContainer
-	err = decryptedPayloads.Decode(encryptedPayload.NextPayload, plainText)
-	if err != nil {
-		return nil, errors.Wrapf(err, "decryptMsg(): Decoding decrypted payload failed")
-	}
-
-	ikeMsg.Payloads.Reset()
-	ikeMsg.Payloads = append(ikeMsg.Payloads, decryptedPayloads...)
-	return ikeMsg, nil
+	return plainText, nil
 }
 
 func encryptMsg(
@@ -239,27 +302,33 @@ func encryptMsg(
 	ikePayloads := ikeMsg.Payloads
 
 	// Check if the context contain needed data
-	if ikesaKey.IntegInfo == nil {
-		return errors.Errorf("encryptMsg(): No integrity algorithm specified")
-	}
 	if ikesaKey.EncrInfo == nil {
 		return errors.Errorf("encryptMsg(): No encryption algorithm specified")
 	}
+	if !ikesaKey.EncrInfo.IsAEAD() && ikesaKey.IntegInfo == nil {
+		return errors.Errorf("encryptMsg(): No integrity algorithm specified")
+	}
 
-	if ikesaKey.Integ_r == nil {
+	if !ikesaKey.EncrInfo.IsAEAD() && ikesaKey.Integ_r == nil {
 		return errors.Errorf("encryptMsg(): No responder's integrity key")
 	}
 	if ikesaKey.Encr_r == nil {
 		return errors.Errorf("encryptMsg(): No responder's encryption key")
 	}
 
-	checksumLength := ikesaKey.IntegInfo.GetOutputLength()
-
 	plainTextPayload, err := ikePayloads.Encode()
 	if err != nil {
 		return errors.Wrapf(err, "encryptMsg(): Encoding IKE payload failed.")
 	}
 
+	encrNextPayloadType := security.EncryptedNextPayload(ikePayloads)
+
+	if ikesaKey.EncrInfo.IsAEAD() {
+		return sealMsg(ikeMsg, plainTextPayload, encrNextPayloadType, ikesaKey, role)
+	}
+
+	checksumLength := ikesaKey.IntegInfo.GetOutputLength()
+
 	// Encrypting
 	encryptedData, err := encryptPayload(plainTextPayload, ikesaKey, role)
 	if err != nil {
@@ -269,12 +338,6 @@ func encryptMsg(
 	encryptedData = append(encryptedData, make([]byte, checksumLength)...)
 	ikeMsg.Payloads.Reset()
 
-	var encrNextPayloadType message.IKEPayloadType
-	if len(ikePayloads) == 0 {
-		encrNextPayloadType = message.NoNext
-	} else {
-		encrNextPayloadType = ikePayloads[0].Type()
-	}
 	sk := ikeMsg.Payloads.BuildEncrypted(encrNextPayloadType, encryptedData)
 
 	// Calculate checksum
@@ -292,3 +355,42 @@ func encryptMsg(
 
 	return nil
 }
+
+// sealMsg encrypts plainTextPayload with the AEAD cipher into the Encrypted
+// payload of ikeMsg, authenticating the IKE header and the Encrypted payload
+// header as defined in RFC 5282 section 5.1
+func sealMsg(
+	ikeMsg *message.IKEMessage,
+	plainTextPayload []byte,
+	encrNextPayloadType message.IKEPayloadType,
+	ikesaKey *security.IKESAKey,
+	role message.Role,
+) error {
+	crypto, err := aeadCrypto(ikesaKey, role == message.Role_Initiator)
+	if err != nil {
+		return errors.Wrapf(err, "encryptMsg()")
+	}
+
+	// The lengths in the headers must cover the sealed data: IV, plaintext
+	// with the pad length byte, then ICV
+	sealedLength := ikesaKey.EncrInfo.GetIVLength() + len(plainTextPayload) + 1 +
+		ikesaKey.EncrInfo.GetICVLength()
+
+	ikeMsg.Payloads.Reset()
+	sk := ikeMsg.Payloads.BuildEncrypted(encrNextPayloadType, make([]byte, sealedLength))
+	ikeMsgData, err := ikeMsg.Encode()
+	if err != nil {
+		return errors.Wrapf(err, "encryptMsg(): Encoding IKE message error")
+	}
+
+	aad := ikeMsgData[:len(ikeMsgData)-sealedLength]
+	sealed, err := crypto.Seal(plainTextPayload, aad)
+	if err != nil {
+		return errors.Wrapf(err, "encryptMsg(): Error encrypting message")
+	}
+	if len(sealed) != sealedLength {
+		return errors.Errorf("encryptMsg(): Sealed %d bytes, expected %d bytes", len(sealed), sealedLength)
+	}
+	sk.EncryptedData = sealed
+	return nil
+}
@@ -3,6 +3,7 @@ package ike
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"encoding/binary"
 	"encoding/hex"
 	"testing"
 
@@ -668,6 +669,29 @@ func TestVerifyIntegrity(t *testing.T) {
 			role:          message.Role_Initiator,
 			expectedValid: false,
 		},
+		{
+			name:       "HMAC SHA384 192 - valid",
+			key:        "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			originData: []byte("hello world"),
+			checksum:   "0d15f7a2843dc9814fb94adc9eab316e548984a9be028843",
+			ikeSAKey: &security.IKESAKey{
+				IntegInfo: integ.StrToType("AUTH_HMAC_SHA2_384_192"),
+			},
+			role:          message.Role_Responder,
+			expectedValid: true,
+		},
+		{
+			name: "HMAC SHA512 256 - valid",
+			key: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef" +
+				"0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			originData: []byte("hello world"),
+			checksum:   "9c76b44683fcedc60fc48e8b8f74229f09d441638b6fd4fb1f7f8e997ae7b292",
+			ikeSAKey: &security.IKESAKey{
+				IntegInfo: integ.StrToType("AUTH_HMAC_SHA2_512_256"),
+			},
+			role:          message.Role_Responder,
+			expectedValid: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -694,3 +718,161 @@ func TestVerifyIntegrity(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyIntegrityInvalidChecksum(t *testing.T) {
+	const (
+		key256 = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+		key384 = key256 + "0123456789abcdef0123456789abcdef"
+		key512 = key256 + key256
+	)
+	tests := []struct {
+		name     string
+		integ    string
+		key      string
+		checksum string
+	}{
+		{
+			name:     "HMAC SHA256 128 - invalid checksum",
+			integ:    "AUTH_HMAC_SHA2_256_128",
+			key:      key256,
+			checksum: "01231875aa",
+		},
+		{
+			name:     "HMAC SHA384 192 - truncated to the SHA256 128 length",
+			integ:    "AUTH_HMAC_SHA2_384_192",
+			key:      key384,
+			checksum: "0d15f7a2843dc9814fb94adc9eab316e",
+		},
+		{
+			name:     "HMAC SHA384 192 - last byte changed",
+			integ:    "AUTH_HMAC_SHA2_384_192",
+			key:      key384,
+			checksum: "0d15f7a2843dc9814fb94adc9eab316e548984a9be028844",
+		},
+		{
+			name:     "HMAC SHA512 256 - truncated to the SHA256 128 length",
+			integ:    "AUTH_HMAC_SHA2_512_256",
+			key:      key512,
+			checksum: "9c76b44683fcedc60fc48e8b8f74229f",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := hex.DecodeString(tt.key)
+			require.NoError(t, err)
+			checksum, err := hex.DecodeString(tt.checksum)
+			require.NoError(t, err)
+			ikeSAKey := &security.IKESAKey{
+				IntegInfo: integ.StrToType(tt.integ),
+			}
+			ikeSAKey.Integ_r = ikeSAKey.IntegInfo.Init(key)
+
+			err = verifyIntegrity([]byte("hello world"), checksum, ikeSAKey, message.Role_Responder)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestEncodeDecodeAEAD(t *testing.T) {
+	encryptionAlgorithm := encr.StrToType("ENCR_AES_GCM_16_256")
+	sk_ei := make([]byte, encryptionAlgorithm.GetKeyLength())
+	sk_er := make([]byte, encryptionAlgorithm.GetKeyLength())
+	for i := range sk_ei {
+		sk_ei[i], sk_er[i] = byte(i), byte(0xff-i)
+	}
+
+	newIKESAKey := func() *security.IKESAKey {
+		ikeSAKey := &security.IKESAKey{
+			EncrInfo: encryptionAlgorithm,
+		}
+		var err error
+		ikeSAKey.Encr_i, err = encryptionAlgorithm.NewCrypto(sk_ei)
+		require.NoError(t, err)
+		ikeSAKey.Encr_r, err = encryptionAlgorithm.NewCrypto(sk_er)
+		require.NoError(t, err)
+		return ikeSAKey
+	}
+	initiator, responder := newIKESAKey(), newIKESAKey()
+
+	ikeMsg := &message.IKEMessage{
+		IKEHeader: &message.IKEHeader{
+			InitiatorSPI: 0x000000000006f708,
+			ResponderSPI: 0xc9e2e31f8b64053d,
+			MajorVersion: 2,
+			MinorVersion: 0,
+			ExchangeType: message.IKE_AUTH,
+			Flags:        message.InitiatorBitCheck,
+			MessageID:    0x03,
+		},
+		Payloads: eapIkeMsg.Payloads,
+	}
+
+	b, err := EncodeEncrypt(ikeMsg, initiator, message.Role_Initiator)
+	require.NoError(t, err)
+
+	// IKE header, Encrypted payload header, IV, payloads with the pad length
+	// byte, ICV
+	plainText, err := eapIkeMsg.Payloads.Encode()
+	require.NoError(t, err)
+	require.Len(t, b, message.IKE_HEADER_LEN+4+8+len(plainText)+1+16)
+
+	decoded, err := DecodeDecrypt(b, nil, responder, message.Role_Responder)
+	require.NoError(t, err)
+	require.Equal(t, eapIkeMsg.Payloads, decoded.Payloads)
+
+	// The IKE header is authenticated
+	tampered := append([]byte(nil), b...)
+	tampered[23] ^= 0x01 // Message ID
+	_, err = DecodeDecrypt(tampered, nil, responder, message.Role_Responder)
+	require.ErrorIs(t, err, encr.ErrAEADAuthFailed)
+
+	// The initiator cannot decrypt its own message with the responder key
+	_, err = DecodeDecrypt(b, nil, initiator, message.Role_Initiator)
+	require.ErrorIs(t, err, encr.ErrAEADAuthFailed)
+}
+
+func TestDecodeDecryptAEADICVLength(t *testing.T) {
+	newIKESAKey := func(name string) *security.IKESAKey {
+		encryptionAlgorithm := encr.StrToType(name)
+		ikeSAKey := &security.IKESAKey{
+			EncrInfo: encryptionAlgorithm,
+		}
+		var err error
+		ikeSAKey.Encr_i, err = encryptionAlgorithm.NewCrypto(make([]byte, encryptionAlgorithm.GetKeyLength()))
+		require.NoError(t, err)
+		ikeSAKey.Encr_r, err = encryptionAlgorithm.NewCrypto(make([]byte, encryptionAlgorithm.GetKeyLength()))
+		require.NoError(t, err)
+		return ikeSAKey
+	}
+
+	ikeMsg := &message.IKEMessage{
+		IKEHeader: &message.IKEHeader{
+			InitiatorSPI: 0x000000000006f708,
+			ResponderSPI: 0xc9e2e31f8b64053d,
+			MajorVersion: 2,
+			MinorVersion: 0,
+			ExchangeType: message.IKE_AUTH,
+			Flags:        message.InitiatorBitCheck,
+			MessageID:    0x03,
+		},
+		Payloads: eapIkeMsg.Payloads,
+	}
+	b, err := EncodeEncrypt(ikeMsg, newIKESAKey("ENCR_AES_GCM_16_256"), message.Role_Initiator)
+	require.NoError(t, err)
+
+	// A 16 bytes ICV checked against an 8 or 12 bytes ICV transform: only
+	// the trailing bytes are taken as the ICV, and the check fails
+	for _, name := range []string{"ENCR_AES_GCM_8_256", "ENCR_AES_GCM_12_256"} {
+		_, err = DecodeDecrypt(b, nil, newIKESAKey(name), message.Role_Responder)
+		require.ErrorIs(t, err, encr.ErrAEADAuthFailed, name)
+	}
+
+	// Encrypted data shorter than the IV and the ICV is rejected before
+	// decryption
+	short := append([]byte(nil), b[:message.IKE_HEADER_LEN+4+8+12]...)
+	binary.BigEndian.PutUint32(short[24:28], uint32(len(short)))
+	binary.BigEndian.PutUint16(short[message.IKE_HEADER_LEN+2:], 4+8+12)
+	_, err = DecodeDecrypt(short, nil, newIKESAKey("ENCR_AES_GCM_16_256"), message.Role_Responder)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "shorter than IV and ICV length 24")
+}
@@ -5,6 +5,8 @@ import "github.com/pkg/errors"
 var _ IKEPayload = &Encrypted{}
 
 type Encrypted struct {
+	// Type of the first payload carried once decrypted, or NoNext: the
+	// Encrypted payload is the last one of the message, RFC 7296 section 3.14
 	NextPayload   uint8
 	EncryptedData []byte
 }
@@ -8,6 +8,14 @@ import (
 
 var _ IKEPayload = &SecurityAssociation{}
 
+var ErrMalformedTransformChain = errors.New("malformed transform chain")
+
+// Last Substruc values of a transform, RFC 7296 section 3.3.2
+const (
+	lastTransform = 0
+	moreTransform = 3
+)
+
 type SecurityAssociation struct {
 	Proposals ProposalContainer
 }
@@ -87,9 +95,9 @@ func (securityAssociation *SecurityAssociation) marshal() ([]byte, error) {
 			transformData := make([]byte, 8)
 
 			if (transformIndex + 1) < len(transformList) {
-				transformData[0] = 3
+				transformData[0] = moreTransform
 			} else {
-				transformData[0] = 0
+				transformData[0] = lastTransform
 			}
 
 			transformData[4] = transform.TransformType
@@ -162,10 +170,10 @@ func (securityAssociation *SecurityAssociation) unmarshal(b []byte) error {
 		proposal.ProposalNumber = b[4]
 		proposal.ProtocolID = b[5]
 
-		spiSize := b[6]
+		spiSize := int(b[6])
 		if spiSize > 0 {
 			// bounds checking
-			if len(b) < int(8+spiSize) {
+			if int(proposalLength) < 8+spiSize {
 				return errors.Errorf("Proposal: No sufficient bytes for unmarshalling SPI of proposal")
 			}
 			proposal.SPI = append(proposal.SPI, b[8:8+spiSize]...)
@@ -186,23 +194,41 @@ func (securityAssociation *SecurityAssociation) unmarshal(b []byte) error {
 				return errors.Errorf("Transform: The length of received message not matchs the length specified in header")
 			}
 
+			// Only the last transform of the proposal has its Last
+			// Substruc set to 0, the others have 3
+			last := len(transformData) == int(transformLength)
+			switch {
+			case last && transformData[0] != lastTransform:
+				return errors.Wrapf(ErrMalformedTransformChain, "Transform: Last Substruc %d on last transform",
+					transformData[0])
+			case !last && transformData[0] != moreTransform:
+				return errors.Wrapf(ErrMalformedTransformChain, "Transform: Last Substruc %d before another transform",
+					transformData[0])
+			}
+
 			transform := new(Transform)
 
 			transform.TransformType = transformData[4]
 			transform.TransformID = binary.BigEndian.Uint16(transformData[6:8])
 			if transformLength > 8 {
+				// bounds checking
+				if transformLength < 12 {
+					return errors.Errorf("Transform: Illegal payload length %d < attribute header length 12",
+						transformLength)
+				}
 				transform.AttributePresent = true
 				transform.AttributeFormat = ((transformData[8] & 0x80) >> 7)
 				transform.AttributeType = binary.BigEndian.Uint16(transformData[8:10]) & 0x7f
 
 				if transform.AttributeFormat == 0 {
-					attributeLength := binary.BigEndian.Uint16(transformData[10:12])
+					attributeLength := int(binary.BigEndian.Uint16(transformData[10:12]))
 					// bounds checking
-					if (12 + attributeLength) != transformLength {
+					if (12 + attributeLength) != int(transformLength) {
 						return errors.Errorf("Illegal attribute length %d not satisfies the transform length %d",
 							attributeLength, transformLength)
 					}
-					copy(transform.VariableLengthAttributeValue, transformData[12:12+attributeLength])
+					transform.VariableLengthAttributeValue = append(transform.VariableLengthAttributeValue,
+						transformData[12:12+attributeLength]...)
 				} else {
 					transform.AttributeValue = binary.BigEndian.Uint16(transformData[10:12])
 				}
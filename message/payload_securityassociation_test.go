@@ -268,6 +268,59 @@ func TestSecurityAssociationUnmarshal(t *testing.T) {
 			},
 			expErr: true,
 		},
+		{
+			description: "SPI exceeds proposal length",
+			b: []byte{
+				0x00, 0x00, 0x00, 0x0a, 0x01, 0x01, 0x08, 0x00,
+				0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+			},
+			expErr: true,
+		},
+		{
+			description: "Transform too short for attribute",
+			b: []byte{
+				0x00, 0x00, 0x00, 0x12, 0x01, 0x01, 0x00, 0x01,
+				0x00, 0x00, 0x00, 0x0a, 0x01, 0x00, 0x00, 0x0c,
+				0x80, 0x0e,
+			},
+			expErr: true,
+		},
+		{
+			description: "Oversized declared attribute length",
+			b: []byte{
+				0x00, 0x00, 0x00, 0x16, 0x01, 0x01, 0x00, 0x01,
+				0x00, 0x00, 0x00, 0x0e, 0x01, 0x00, 0x00, 0x0c,
+				0x00, 0x0e, 0xff, 0xf6, 0x01, 0x00,
+			},
+			expErr: true,
+		},
+		{
+			description: "Variable length attribute",
+			b: []byte{
+				0x00, 0x00, 0x00, 0x16, 0x01, 0x01, 0x00, 0x01,
+				0x00, 0x00, 0x00, 0x0e, 0x01, 0x00, 0x00, 0x0c,
+				0x00, 0x0e, 0x00, 0x02, 0x01, 0x00,
+			},
+			expSA: &SecurityAssociation{
+				ProposalContainer{
+					&Proposal{
+						ProposalNumber: 1,
+						ProtocolID:     1,
+						EncryptionAlgorithm: TransformContainer{
+							&Transform{
+								TransformType:                TypeEncryptionAlgorithm,
+								TransformID:                  ENCR_AES_CBC,
+								AttributePresent:             true,
+								AttributeFormat:              AttributeFormatUseTLV,
+								AttributeType:                AttributeTypeKeyLength,
+								VariableLengthAttributeValue: []byte{0x01, 0x00},
+							},
+						},
+					},
+				},
+			},
+			expErr: false,
+		},
 		{
 			description: "SecurityAssociation Unmarshal",
 			b:           validSecurityAssociationByte,
@@ -289,3 +342,43 @@ func TestSecurityAssociationUnmarshal(t *testing.T) {
 		})
 	}
 }
+
+func TestSecurityAssociationUnmarshalMalformedLengths(t *testing.T) {
+	// Overwrite every length field reachable from the first bytes of a valid
+	// payload with oversized values: decoding must fail or succeed, never panic
+	for i := 0; i+1 < len(validSecurityAssociationByte); i++ {
+		for _, length := range []uint16{0x0009, 0x000b, 0x00ff, 0x7fff, 0xfff5, 0xffff} {
+			b := append([]byte(nil), validSecurityAssociationByte...)
+			b[i] = byte(length >> 8)
+			b[i+1] = byte(length)
+			require.NotPanics(t, func() {
+				var sa SecurityAssociation
+				_ = sa.unmarshal(b)
+			}, "length 0x%04x at offset %d", length, i)
+		}
+	}
+}
+
+func TestSecurityAssociationUnmarshalTransformChain(t *testing.T) {
+	testcases := []struct {
+		description string
+		offset      int
+		value       byte
+	}{
+		// The first transform of the first proposal is followed by others
+		{"Last flag before another transform", 11, 0x00},
+		{"Unknown flag before another transform", 11, 0x02},
+		// The ESN transform ends the first proposal
+		{"More flag on the last transform", 83, 0x03},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.description, func(t *testing.T) {
+			b := append([]byte(nil), validSecurityAssociationByte...)
+			b[tc.offset] = tc.value
+			var sa SecurityAssociation
+			err := sa.unmarshal(b)
+			require.ErrorIs(t, err, ErrMalformedTransformChain)
+		})
+	}
+}
@@ -74,13 +74,25 @@ const (
 	ENCR_NULL     = 11
 	ENCR_AES_CBC  = 12
 	ENCR_AES_CTR  = 13
+
+	ENCR_AES_GCM_8  = 18
+	ENCR_AES_GCM_12 = 19
+	ENCR_AES_GCM_16 = 20
+
+	ENCR_CAMELLIA_CBC = 23
+
+	ENCR_CHACHA20_POLY1305 = 28
 )
 
 const (
 	PRF_HMAC_MD5 = iota + 1
 	PRF_HMAC_SHA1
 	PRF_HMAC_TIGER
+	PRF_AES128_XCBC
 	PRF_HMAC_SHA2_256 = 5
+	PRF_HMAC_SHA2_384 = 6
+	PRF_HMAC_SHA2_512 = 7
+	PRF_AES128_CMAC   = 8
 )
 
 const (
@@ -90,7 +102,10 @@ const (
 	AUTH_DES_MAC
 	AUTH_KPDK_MD5
 	AUTH_AES_XCBC_96
+	AUTH_AES_CMAC_96       = 8
 	AUTH_HMAC_SHA2_256_128 = 12
+	AUTH_HMAC_SHA2_384_192 = 13
+	AUTH_HMAC_SHA2_512_256 = 14
 )
 
 const (
@@ -103,6 +118,13 @@ const (
 	DH_4096_BIT_MODP
 	DH_6144_BIT_MODP
 	DH_8192_BIT_MODP
+
+	DH_256_BIT_RANDOM_ECP = 19
+	DH_384_BIT_RANDOM_ECP = 20
+	DH_521_BIT_RANDOM_ECP = 21
+
+	DH_CURVE25519 = 31
+	DH_CURVE448   = 32
 )
 
 const (
@@ -5,3 +5,13 @@ type IKECrypto interface {
 	Encrypt(plainText []byte) ([]byte, error)
 	Decrypt(cipherText []byte) ([]byte, error)
 }
+
+// IKECryptoAEAD is the IKECrypto of a combined mode cipher, returned by the
+// NewCrypto of an encryption algorithm whose IsAEAD is true. Seal and Open
+// also authenticate the associated data aad, and the ICV is part of their
+// output and input, so no separate integrity algorithm is used.
+type IKECryptoAEAD interface {
+	IKECrypto
+	Seal(plainText, aad []byte) ([]byte, error)
+	Open(cipherText, aad []byte) ([]byte, error)
+}
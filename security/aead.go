@@ -0,0 +1,15 @@
+package security
+
+// BuildAEADAssociatedData returns the associated data authenticated by an
+// AEAD cipher for an IKE message, as defined in RFC 5282 section 5.1: the IKE
+// header, followed by any payload preceding the Encrypted payload, followed by
+// the generic payload header of the Encrypted payload. ikeHeader holds the IKE
+// header and the preceding payloads, encryptedPayloadHeader the 4 bytes
+// generic header of the Encrypted payload, without the IV and ciphertext.
+// The length fields of both headers must already cover the whole message.
+func BuildAEADAssociatedData(ikeHeader []byte, encryptedPayloadHeader []byte) []byte {
+	aad := make([]byte, 0, len(ikeHeader)+len(encryptedPayloadHeader))
+	aad = append(aad, ikeHeader...)
+	aad = append(aad, encryptedPayloadHeader...)
+	return aad
+}
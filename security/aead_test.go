@@ -0,0 +1,41 @@
+package security
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nathaniel-bennett/ike/message"
+)
+
+func TestBuildAEADAssociatedData(t *testing.T) {
+	// IKE_AUTH request holding an Encrypted payload of 4 bytes header, 8
+	// bytes IV, 32 bytes ciphertext and 16 bytes ICV
+	skPayloadLength := 4 + 8 + 32 + 16
+	header := message.NewHeader(0x0102030405060708, 0x1112131415161718, message.IKE_AUTH,
+		false, true, 1, uint8(message.TypeSK), nil)
+	ikeHeader, err := header.Marshal()
+	require.NoError(t, err)
+	binary.BigEndian.PutUint32(ikeHeader[24:28], uint32(message.IKE_HEADER_LEN+skPayloadLength))
+
+	skHeader := []byte{uint8(message.TypeIDi), 0x00, 0x00, 0x00}
+	binary.BigEndian.PutUint16(skHeader[2:4], uint16(skPayloadLength))
+
+	expected := []byte{
+		// IKE header
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+		0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18,
+		0x2e, 0x20, 0x23, 0x08, 0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x58,
+		// Encrypted payload header
+		0x23, 0x00, 0x00, 0x3c,
+	}
+
+	aad := BuildAEADAssociatedData(ikeHeader, skHeader)
+	require.Equal(t, expected, aad)
+
+	// The associated data does not alias its inputs
+	aad[0] = 0xff
+	require.Equal(t, uint8(0x01), ikeHeader[0])
+}
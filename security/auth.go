@@ -0,0 +1,139 @@
+package security
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha1" // #nosec G505
+	"crypto/x509"
+	"hash"
+
+	"github.com/pkg/errors"
+
+	"github.com/nathaniel-bennett/ike/message"
+	"github.com/nathaniel-bennett/ike/security/prf"
+)
+
+// keyPadIKEv2 is the key pad of the shared key AUTH computation, RFC 7296
+// section 2.15
+const keyPadIKEv2 = "Key Pad for IKEv2"
+
+var ErrAuthenticationFailed = errors.New("authentication failed")
+
+// ComputePSKAuth computes the AUTH payload data for shared key message
+// integrity code authentication:
+// AUTH = prf( prf(Shared Secret, "Key Pad for IKEv2"), <SignedOctets>)
+func (ikesaKey *IKESAKey) ComputePSKAuth(psk, signedOctets []byte) ([]byte, error) {
+	if len(psk) == 0 {
+		return nil, errors.Errorf("ComputePSKAuth : No shared secret")
+	}
+	auth, err := ikesaKey.sharedKeyAuth(psk, signedOctets)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ComputePSKAuth")
+	}
+	return auth, nil
+}
+
+// ComputeEAPAuth computes the AUTH payload data of an IKE_AUTH exchange
+// authenticated with EAP, RFC 7296 section 2.16. The shared secret is the MSK
+// generated by the EAP method:
+// AUTH = prf( prf(MSK, "Key Pad for IKEv2"), <SignedOctets>)
+// With an EAP method generating no MSK, it is SK_pi for the AUTH sent by the
+// initiator, and SK_pr for the AUTH sent by the responder.
+func (ikesaKey *IKESAKey) ComputeEAPAuth(msk, signedOctets []byte, isInitiator bool) ([]byte, error) {
+	if ikesaKey == nil {
+		return nil, errors.Errorf("ComputeEAPAuth : IKE SA is nil")
+	}
+
+	if len(msk) != 0 {
+		auth, err := ikesaKey.sharedKeyAuth(msk, signedOctets)
+		if err != nil {
+			return nil, errors.Wrapf(err, "ComputeEAPAuth")
+		}
+		return auth, nil
+	}
+	if ikesaKey.PrfInfo == nil {
+		return nil, errors.Errorf("ComputeEAPAuth : No pseudorandom function specified")
+	}
+
+	label, key := KeyLabelSK_pi, ikesaKey.SK_pi
+	if !isInitiator {
+		label, key = KeyLabelSK_pr, ikesaKey.SK_pr
+	}
+	keyed, err := ikesaKey.keyedPRF(label, key, prf.PRFType.Init)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ComputeEAPAuth : No MSK")
+	}
+	auth, err := ikesaKey.keyPadAuth(keyed, signedOctets)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ComputeEAPAuth")
+	}
+	return auth, nil
+}
+
+// sharedKeyAuth returns prf( prf(sharedSecret, "Key Pad for IKEv2"),
+// <SignedOctets>)
+func (ikesaKey *IKESAKey) sharedKeyAuth(sharedSecret, signedOctets []byte) ([]byte, error) {
+	if ikesaKey == nil {
+		return nil, errors.Errorf("IKE SA is nil")
+	}
+	if ikesaKey.PrfInfo == nil {
+		return nil, errors.Errorf("No pseudorandom function specified")
+	}
+	return ikesaKey.keyPadAuth(ikesaKey.PrfInfo.Init(sharedSecret), signedOctets)
+}
+
+// keyPadAuth returns prf( keyedPRF("Key Pad for IKEv2"), <SignedOctets>),
+// where keyedPRF is the PRF keyed by the shared secret
+func (ikesaKey *IKESAKey) keyPadAuth(keyedPRF hash.Hash, signedOctets []byte) ([]byte, error) {
+	if _, err := keyedPRF.Write([]byte(keyPadIKEv2)); err != nil {
+		return nil, err
+	}
+	prf := ikesaKey.PrfInfo.Init(keyedPRF.Sum(nil))
+	if _, err := prf.Write(signedOctets); err != nil {
+		return nil, err
+	}
+	return prf.Sum(nil), nil
+}
+
+// VerifyPSKAuth checks the AUTH payload data received from the peer against
+// the value computed from the shared key. It returns ErrAuthenticationFailed
+// if they differ.
+func (ikesaKey *IKESAKey) VerifyPSKAuth(psk, signedOctets, authenticationData []byte) error {
+	expected, err := ikesaKey.ComputePSKAuth(psk, signedOctets)
+	if err != nil {
+		return errors.Wrapf(err, "VerifyPSKAuth")
+	}
+	if !hmac.Equal(expected, authenticationData) {
+		return errors.Wrapf(ErrAuthenticationFailed, "VerifyPSKAuth")
+	}
+	return nil
+}
+
+// VerifyAuthSignature checks the AUTH payload signature of the peer with the
+// public key of its certificate. Only RSA digital signature (PKCS#1 v1.5 with
+// SHA-1, RFC 7296 section 3.8) is supported. It returns
+// ErrAuthenticationFailed if the signature does not verify.
+func VerifyAuthSignature(
+	cert *x509.Certificate,
+	authenticationMethod uint8,
+	signedOctets, signature []byte,
+) error {
+	if cert == nil {
+		return errors.Errorf("VerifyAuthSignature : certificate is nil")
+	}
+	if authenticationMethod != message.RSADigitalSignature {
+		return errors.Errorf("VerifyAuthSignature : unsupported authentication method %d",
+			authenticationMethod)
+	}
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.Errorf("VerifyAuthSignature : certificate does not hold an RSA public key")
+	}
+
+	hash := sha1.Sum(signedOctets) // #nosec G401
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA1, hash[:], signature); err != nil {
+		return errors.Wrapf(ErrAuthenticationFailed, "VerifyAuthSignature: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package security
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" // #nosec G505
+	"crypto/x509"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nathaniel-bennett/ike/message"
+	"github.com/nathaniel-bennett/ike/security/prf"
+)
+
+func TestVerifyPSKAuth(t *testing.T) {
+	ikesaKey := &IKESAKey{
+		PrfInfo: prf.StrToType("PRF_HMAC_SHA1"),
+	}
+	signedOctets := []byte("signed octets")
+
+	auth, err := ikesaKey.ComputePSKAuth([]byte("correct psk"), signedOctets)
+	require.NoError(t, err)
+	require.Len(t, auth, 20)
+
+	err = ikesaKey.VerifyPSKAuth([]byte("correct psk"), signedOctets, auth)
+	require.NoError(t, err)
+
+	// Wrong shared key
+	err = ikesaKey.VerifyPSKAuth([]byte("wrong psk"), signedOctets, auth)
+	require.ErrorIs(t, err, ErrAuthenticationFailed)
+	require.Equal(t, uint16(message.AUTHENTICATION_FAILED), NotifyTypeForError(err))
+
+	// No pseudorandom function
+	_, err = (&IKESAKey{}).ComputePSKAuth([]byte("correct psk"), signedOctets)
+	require.Error(t, err)
+	require.Equal(t, uint16(0), NotifyTypeForError(err))
+}
+
+func TestComputeEAPAuth(t *testing.T) {
+	ikesaKey := &IKESAKey{
+		PrfInfo: prf.StrToType("PRF_HMAC_SHA2_256"),
+		SK_pi:   bytes.Repeat([]byte{0xa5}, 32),
+		SK_pr:   bytes.Repeat([]byte{0x5a}, 32),
+	}
+	msk := make([]byte, 64)
+	for i := range msk {
+		msk[i] = byte(i)
+	}
+	signedOctets := []byte("signed octets")
+
+	// prf(prf(MSK, "Key Pad for IKEv2"), signedOctets) with HMAC-SHA-256
+	expected, err := hex.DecodeString("93b9c2ba199ca3b3c18354fb5da5d7b25e329ed37220faea4fddc6e76c5cb87b")
+	require.NoError(t, err)
+
+	// The MSK is used in both directions
+	auth, err := ikesaKey.ComputeEAPAuth(msk, signedOctets, true)
+	require.NoError(t, err)
+	require.Equal(t, expected, auth)
+	auth, err = ikesaKey.ComputeEAPAuth(msk, signedOctets, false)
+	require.NoError(t, err)
+	require.Equal(t, expected, auth)
+
+	pskAuth, err := ikesaKey.ComputePSKAuth(msk, signedOctets)
+	require.NoError(t, err)
+	require.Equal(t, expected, pskAuth)
+
+	// Without MSK, SK_pi or SK_pr is the shared secret
+	expected, err = hex.DecodeString("7a7ebcc50c75d2f3bcffb65878995d677394a3050aa2b46434aad9a5e41eec05")
+	require.NoError(t, err)
+	auth, err = ikesaKey.ComputeEAPAuth(nil, signedOctets, true)
+	require.NoError(t, err)
+	require.Equal(t, expected, auth)
+	auth, err = ikesaKey.ComputeEAPAuth(nil, signedOctets, false)
+	require.NoError(t, err)
+	require.NotEqual(t, expected, auth)
+
+	_, err = (&IKESAKey{PrfInfo: ikesaKey.PrfInfo}).ComputeEAPAuth(nil, signedOctets, true)
+	require.Error(t, err)
+	_, err = (&IKESAKey{}).ComputeEAPAuth(msk, signedOctets, true)
+	require.Error(t, err)
+}
+
+func TestVerifyAuthSignature(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	cert := &x509.Certificate{PublicKey: &privateKey.PublicKey}
+
+	signedOctets := []byte("signed octets")
+	hash := sha1.Sum(signedOctets) // #nosec G401
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA1, hash[:])
+	require.NoError(t, err)
+
+	err = VerifyAuthSignature(cert, message.RSADigitalSignature, signedOctets, signature)
+	require.NoError(t, err)
+
+	err = VerifyAuthSignature(cert, message.RSADigitalSignature, []byte("other octets"), signature)
+	require.ErrorIs(t, err, ErrAuthenticationFailed)
+	require.Equal(t, uint16(message.AUTHENTICATION_FAILED), NotifyTypeForError(err))
+
+	err = VerifyAuthSignature(cert, message.DSSDigitalSignature, signedOctets, signature)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrAuthenticationFailed)
+}
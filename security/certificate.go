@@ -0,0 +1,143 @@
+package security
+
+import (
+	"bytes"
+	"crypto/sha1" // #nosec G505
+	"crypto/x509"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/nathaniel-bennett/ike/message"
+)
+
+var (
+	ErrInvalidCertificateChain = errors.New("invalid certificate chain")
+	ErrIdentityMismatch        = errors.New("identity does not match certificate")
+)
+
+// CertificateAuthorityHash returns the SHA-1 hash of the SubjectPublicKeyInfo
+// of ca, as carried in the Certification Authority field of CERTREQ payloads
+// (RFC 7296 section 3.7) and matched by CompareRootCertificate
+func CertificateAuthorityHash(ca *x509.Certificate) []byte {
+	if ca == nil {
+		return nil
+	}
+	hash := sha1.Sum(ca.RawSubjectPublicKeyInfo) // #nosec G401
+	return hash[:]
+}
+
+// VerifyCertificateChain verifies that leaf chains up to one of roots through
+// intermediates, and that peerID is an identity of leaf. peerID is matched
+// against the subjectAltName of leaf as defined in RFC 7296 section 3.5: an IP
+// address against the IP addresses, an address holding '@' against the email
+// addresses and anything else against the DNS names. If nothing matches,
+// peerID is compared with the subject of leaf. An empty peerID skips the
+// identity check.
+func VerifyCertificateChain(
+	leaf *x509.Certificate,
+	intermediates []*x509.Certificate,
+	roots *x509.CertPool,
+	peerID string,
+) error {
+	if leaf == nil {
+		return errors.Wrapf(ErrInvalidCertificateChain, "VerifyCertificateChain : leaf is nil")
+	}
+	if roots == nil {
+		return errors.Wrapf(ErrInvalidCertificateChain, "VerifyCertificateChain : roots is nil")
+	}
+
+	intermediatePool := x509.NewCertPool()
+	for _, cert := range intermediates {
+		intermediatePool.AddCert(cert)
+	}
+
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediatePool,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return errors.Wrapf(ErrInvalidCertificateChain, "VerifyCertificateChain : %v", err)
+	}
+
+	if peerID == "" {
+		return nil
+	}
+	if !matchPeerID(leaf, peerID) {
+		return errors.Wrapf(ErrIdentityMismatch, "VerifyCertificateChain : %s", peerID)
+	}
+	return nil
+}
+
+// MatchIdentity verifies that the identity of an IDi or IDr payload appears
+// in cert. ID_FQDN, ID_RFC822_ADDR, ID_IPV4_ADDR and ID_IPV6_ADDR are matched
+// against the subjectAltName of cert and ID_DER_ASN1_DN against its subject.
+func MatchIdentity(idType uint8, idData []byte, cert *x509.Certificate) error {
+	if cert == nil {
+		return errors.Errorf("MatchIdentity : certificate is nil")
+	}
+
+	var matched bool
+	switch idType {
+	case message.ID_FQDN:
+		matched = matchString(cert.DNSNames, string(idData))
+	case message.ID_RFC822_ADDR:
+		matched = matchString(cert.EmailAddresses, string(idData))
+	case message.ID_IPV4_ADDR:
+		if len(idData) != net.IPv4len {
+			return errors.Errorf("MatchIdentity : invalid IPv4 address length %d", len(idData))
+		}
+		matched = matchIPAddress(cert, net.IP(idData))
+	case message.ID_IPV6_ADDR:
+		if len(idData) != net.IPv6len {
+			return errors.Errorf("MatchIdentity : invalid IPv6 address length %d", len(idData))
+		}
+		matched = matchIPAddress(cert, net.IP(idData))
+	case message.ID_DER_ASN1_DN:
+		matched = bytes.Equal(cert.RawSubject, idData)
+	default:
+		return errors.Errorf("MatchIdentity : unsupported ID type %d", idType)
+	}
+
+	if !matched {
+		return errors.Wrapf(ErrIdentityMismatch, "MatchIdentity : ID type %d", idType)
+	}
+	return nil
+}
+
+func matchPeerID(cert *x509.Certificate, peerID string) bool {
+	if ip := net.ParseIP(peerID); ip != nil {
+		if matchIPAddress(cert, ip) {
+			return true
+		}
+	} else if strings.Contains(peerID, "@") {
+		if matchString(cert.EmailAddresses, peerID) {
+			return true
+		}
+	} else if matchString(cert.DNSNames, peerID) {
+		return true
+	}
+	return cert.Subject.String() == peerID
+}
+
+// matchString reports whether id is in names, ignoring case as DNS names and
+// the domain part of email addresses are case insensitive
+func matchString(names []string, id string) bool {
+	for _, name := range names {
+		if strings.EqualFold(name, id) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchIPAddress(cert *x509.Certificate, ip net.IP) bool {
+	for _, certIP := range cert.IPAddresses {
+		if certIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
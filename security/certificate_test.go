@@ -0,0 +1,170 @@
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1" // #nosec G505
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nathaniel-bennett/ike/message"
+)
+
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCertificate(t *testing.T, template *x509.Certificate, issuer *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template.SerialNumber = serial
+	if template.NotBefore.IsZero() {
+		template.NotBefore = time.Now().Add(-time.Hour)
+	}
+	if template.NotAfter.IsZero() {
+		template.NotAfter = time.Now().Add(time.Hour)
+	}
+
+	parent, signer := template, key
+	if issuer != nil {
+		parent, signer = issuer.cert, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCertificate{cert: cert, key: key}
+}
+
+func newTestCA(t *testing.T, name string, issuer *testCertificate) *testCertificate {
+	return newTestCertificate(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, issuer)
+}
+
+func TestVerifyCertificateChain(t *testing.T) {
+	root := newTestCA(t, "root", nil)
+	intermediate := newTestCA(t, "intermediate", root)
+	leaf := newTestCertificate(t, &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "gateway", Organization: []string{"ike"}},
+		DNSNames:       []string{"gw.example.com"},
+		EmailAddresses: []string{"gw@example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("192.0.2.1")},
+	}, intermediate)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+	intermediates := []*x509.Certificate{intermediate.cert}
+
+	for _, peerID := range []string{
+		"", "gw.example.com", "GW.example.com", "gw@example.com", "192.0.2.1",
+		leaf.cert.Subject.String(),
+	} {
+		err := VerifyCertificateChain(leaf.cert, intermediates, roots, peerID)
+		require.NoError(t, err, peerID)
+	}
+
+	for _, peerID := range []string{"other.example.com", "other@example.com", "192.0.2.2"} {
+		err := VerifyCertificateChain(leaf.cert, intermediates, roots, peerID)
+		require.ErrorIs(t, err, ErrIdentityMismatch, peerID)
+	}
+
+	// Missing intermediate
+	err := VerifyCertificateChain(leaf.cert, nil, roots, "gw.example.com")
+	require.ErrorIs(t, err, ErrInvalidCertificateChain)
+	require.Equal(t, uint16(message.AUTHENTICATION_FAILED), NotifyTypeForError(err))
+
+	// Untrusted root
+	err = VerifyCertificateChain(leaf.cert, intermediates, x509.NewCertPool(), "gw.example.com")
+	require.ErrorIs(t, err, ErrInvalidCertificateChain)
+}
+
+func TestVerifyCertificateChainExpired(t *testing.T) {
+	root := newTestCA(t, "root", nil)
+	leaf := newTestCertificate(t, &x509.Certificate{
+		Subject:   pkix.Name{CommonName: "gateway"},
+		DNSNames:  []string{"gw.example.com"},
+		NotBefore: time.Now().Add(-48 * time.Hour),
+		NotAfter:  time.Now().Add(-24 * time.Hour),
+	}, root)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+
+	err := VerifyCertificateChain(leaf.cert, nil, roots, "gw.example.com")
+	require.ErrorIs(t, err, ErrInvalidCertificateChain)
+}
+
+func TestMatchIdentity(t *testing.T) {
+	root := newTestCA(t, "root", nil)
+	leaf := newTestCertificate(t, &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "gateway", Organization: []string{"ike"}},
+		DNSNames:       []string{"gw.example.com"},
+		EmailAddresses: []string{"gw@example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")},
+	}, root)
+
+	testcases := []struct {
+		name    string
+		idType  uint8
+		match   []byte
+		noMatch []byte
+	}{
+		{"FQDN", message.ID_FQDN, []byte("gw.example.com"), []byte("other.example.com")},
+		{"RFC822", message.ID_RFC822_ADDR, []byte("gw@example.com"), []byte("other@example.com")},
+		{"IPv4", message.ID_IPV4_ADDR, net.ParseIP("192.0.2.1").To4(), net.ParseIP("192.0.2.2").To4()},
+		{"IPv6", message.ID_IPV6_ADDR, net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")},
+		{"DN", message.ID_DER_ASN1_DN, leaf.cert.RawSubject, root.cert.RawSubject},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := MatchIdentity(tc.idType, tc.match, leaf.cert)
+			require.NoError(t, err)
+
+			err = MatchIdentity(tc.idType, tc.noMatch, leaf.cert)
+			require.ErrorIs(t, err, ErrIdentityMismatch)
+			require.Equal(t, uint16(message.AUTHENTICATION_FAILED), NotifyTypeForError(err))
+		})
+	}
+
+	err := MatchIdentity(message.ID_IPV4_ADDR, net.ParseIP("192.0.2.1"), leaf.cert)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrIdentityMismatch)
+
+	err = MatchIdentity(message.ID_KEY_ID, []byte("key"), leaf.cert)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrIdentityMismatch)
+}
+
+func TestCertificateAuthorityHash(t *testing.T) {
+	root := newTestCA(t, "root", nil)
+	other := newTestCA(t, "other", nil)
+
+	hash := CertificateAuthorityHash(root.cert)
+	require.Len(t, hash, 20)
+	expected := sha1.Sum(root.cert.RawSubjectPublicKeyInfo) // #nosec G401
+	require.Equal(t, expected[:], hash)
+
+	// The hash sent in a CERTREQ matches the local CA hash
+	require.True(t, CompareRootCertificate(hash, message.X509CertificateSignature,
+		CertificateAuthorityHash(root.cert)))
+	require.False(t, CompareRootCertificate(hash, message.X509CertificateSignature,
+		CertificateAuthorityHash(other.cert)))
+
+	require.Nil(t, CertificateAuthorityHash(nil))
+}
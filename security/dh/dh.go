@@ -1,14 +1,31 @@
 package dh
 
 import (
+	"crypto/elliptic"
+	"crypto/rand"
 	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
 
 	"github.com/nathaniel-bennett/ike/message"
 )
 
+var ErrInvalidPublicValue = errors.New("invalid Diffie-Hellman public value")
+
 const (
-	DH_1024_BIT_MODP string = "DH_1024_BIT_MODP"
-	DH_2048_BIT_MODP string = "DH_2048_BIT_MODP"
+	DH_1024_BIT_MODP      string = "DH_1024_BIT_MODP"
+	DH_2048_BIT_MODP      string = "DH_2048_BIT_MODP"
+	DH_3072_BIT_MODP      string = "DH_3072_BIT_MODP"
+	DH_4096_BIT_MODP      string = "DH_4096_BIT_MODP"
+	DH_6144_BIT_MODP      string = "DH_6144_BIT_MODP"
+	DH_8192_BIT_MODP      string = "DH_8192_BIT_MODP"
+	DH_256_BIT_RANDOM_ECP string = "DH_256_BIT_RANDOM_ECP"
+	DH_384_BIT_RANDOM_ECP string = "DH_384_BIT_RANDOM_ECP"
+	DH_521_BIT_RANDOM_ECP string = "DH_521_BIT_RANDOM_ECP"
+	DH_CURVE25519         string = "DH_CURVE25519"
+	DH_CURVE448           string = "DH_CURVE448"
 )
 
 var (
@@ -16,11 +33,52 @@ var (
 	dhTypes  map[string]DHType
 )
 
+// Short names of the groups, as used in configurations, mapped to the group
+// names
+var dhAliases = map[string]string{
+	"modp1024":   DH_1024_BIT_MODP,
+	"group2":     DH_1024_BIT_MODP,
+	"modp2048":   DH_2048_BIT_MODP,
+	"group14":    DH_2048_BIT_MODP,
+	"modp3072":   DH_3072_BIT_MODP,
+	"group15":    DH_3072_BIT_MODP,
+	"modp4096":   DH_4096_BIT_MODP,
+	"group16":    DH_4096_BIT_MODP,
+	"modp6144":   DH_6144_BIT_MODP,
+	"group17":    DH_6144_BIT_MODP,
+	"modp8192":   DH_8192_BIT_MODP,
+	"group18":    DH_8192_BIT_MODP,
+	"ecp256":     DH_256_BIT_RANDOM_ECP,
+	"p256":       DH_256_BIT_RANDOM_ECP,
+	"group19":    DH_256_BIT_RANDOM_ECP,
+	"ecp384":     DH_384_BIT_RANDOM_ECP,
+	"p384":       DH_384_BIT_RANDOM_ECP,
+	"group20":    DH_384_BIT_RANDOM_ECP,
+	"ecp521":     DH_521_BIT_RANDOM_ECP,
+	"p521":       DH_521_BIT_RANDOM_ECP,
+	"group21":    DH_521_BIT_RANDOM_ECP,
+	"x25519":     DH_CURVE25519,
+	"curve25519": DH_CURVE25519,
+	"group31":    DH_CURVE25519,
+	"x448":       DH_CURVE448,
+	"curve448":   DH_CURVE448,
+	"group32":    DH_CURVE448,
+}
+
 func init() {
 	// DH String
 	dhString = make(map[uint16]func(uint16, uint16, []byte) string)
 	dhString[message.DH_1024_BIT_MODP] = toString_DH_1024_BIT_MODP
 	dhString[message.DH_2048_BIT_MODP] = toString_DH_2048_BIT_MODP
+	dhString[message.DH_3072_BIT_MODP] = toString_DH_3072_BIT_MODP
+	dhString[message.DH_4096_BIT_MODP] = toString_DH_4096_BIT_MODP
+	dhString[message.DH_6144_BIT_MODP] = toString_DH_6144_BIT_MODP
+	dhString[message.DH_8192_BIT_MODP] = toString_DH_8192_BIT_MODP
+	dhString[message.DH_256_BIT_RANDOM_ECP] = toString_DH_256_BIT_RANDOM_ECP
+	dhString[message.DH_384_BIT_RANDOM_ECP] = toString_DH_384_BIT_RANDOM_ECP
+	dhString[message.DH_521_BIT_RANDOM_ECP] = toString_DH_521_BIT_RANDOM_ECP
+	dhString[message.DH_CURVE25519] = toString_DH_CURVE25519
+	dhString[message.DH_CURVE448] = toString_DH_CURVE448
 
 	// DH Types
 	dhTypes = make(map[string]DHType)
@@ -50,9 +108,83 @@ func init() {
 		generator:         generator,
 		factorBytesLength: len(factor.Bytes()),
 	}
+
+	// Group 15: Dh3072BitModp
+	factor, ok = new(big.Int).SetString(Group15PrimeString, 16)
+	if !ok {
+		panic("IKE Diffie Hellman Group failed to init.")
+	}
+	generator = new(big.Int).SetUint64(Group15Generator)
+	dhTypes[DH_3072_BIT_MODP] = &Dh3072BitModp{
+		factor:            factor,
+		generator:         generator,
+		factorBytesLength: len(factor.Bytes()),
+	}
+
+	// Group 16: Dh4096BitModp
+	factor, ok = new(big.Int).SetString(Group16PrimeString, 16)
+	if !ok {
+		panic("IKE Diffie Hellman Group failed to init.")
+	}
+	generator = new(big.Int).SetUint64(Group16Generator)
+	dhTypes[DH_4096_BIT_MODP] = &Dh4096BitModp{
+		factor:            factor,
+		generator:         generator,
+		factorBytesLength: len(factor.Bytes()),
+	}
+
+	// Group 17: Dh6144BitModp
+	factor, ok = new(big.Int).SetString(Group17PrimeString, 16)
+	if !ok {
+		panic("IKE Diffie Hellman Group failed to init.")
+	}
+	generator = new(big.Int).SetUint64(Group17Generator)
+	dhTypes[DH_6144_BIT_MODP] = &Dh6144BitModp{
+		factor:            factor,
+		generator:         generator,
+		factorBytesLength: len(factor.Bytes()),
+	}
+
+	// Group 18: Dh8192BitModp
+	factor, ok = new(big.Int).SetString(Group18PrimeString, 16)
+	if !ok {
+		panic("IKE Diffie Hellman Group failed to init.")
+	}
+	generator = new(big.Int).SetUint64(Group18Generator)
+	dhTypes[DH_8192_BIT_MODP] = &Dh8192BitModp{
+		factor:            factor,
+		generator:         generator,
+		factorBytesLength: len(factor.Bytes()),
+	}
+
+	// Group 19: DhEcp256
+	dhTypes[DH_256_BIT_RANDOM_ECP] = &DhEcp256{
+		curve: elliptic.P256(),
+	}
+
+	// Group 20: DhEcp384
+	dhTypes[DH_384_BIT_RANDOM_ECP] = &DhEcp384{
+		curve: elliptic.P384(),
+	}
+
+	// Group 21: DhEcp521
+	dhTypes[DH_521_BIT_RANDOM_ECP] = &DhEcp521{
+		curve: elliptic.P521(),
+	}
+
+	// Group 31: Curve25519
+	dhTypes[DH_CURVE25519] = &DhCurve25519{}
+
+	// Group 32: Curve448
+	dhTypes[DH_CURVE448] = &DhCurve448{}
 }
 
+// StrToType returns the group named algo, which is either the group name
+// (e.g. DH_2048_BIT_MODP) or a short name (e.g. modp2048 or group14)
 func StrToType(algo string) DHType {
+	if name, ok := dhAliases[strings.ToLower(algo)]; ok {
+		algo = name
+	}
 	if t, ok := dhTypes[algo]; ok {
 		return t
 	} else {
@@ -60,6 +192,40 @@ func StrToType(algo string) DHType {
 	}
 }
 
+// SupportedGroups returns the transform IDs of the registered groups, in
+// ascending order
+func SupportedGroups() []uint16 {
+	groups := make([]uint16, 0, len(dhTypes))
+	for _, dhType := range dhTypes {
+		groups = append(groups, dhType.TransformID())
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i] < groups[j] })
+	return groups
+}
+
+// SupportedGroupsByPreference returns the transform IDs of the registered
+// groups from the most to the least preferred, that is by decreasing
+// security strength. The first one is the group to name in an
+// INVALID_KE_PAYLOAD notify.
+func SupportedGroupsByPreference() []uint16 {
+	types := make([]DHType, 0, len(dhTypes))
+	for _, dhType := range dhTypes {
+		types = append(types, dhType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if types[i].GetSecurityStrength() != types[j].GetSecurityStrength() {
+			return types[i].GetSecurityStrength() > types[j].GetSecurityStrength()
+		}
+		return types[i].TransformID() > types[j].TransformID()
+	})
+
+	groups := make([]uint16, 0, len(types))
+	for _, dhType := range types {
+		groups = append(groups, dhType.TransformID())
+	}
+	return groups
+}
+
 func DecodeTransform(transform *message.Transform) DHType {
 	if f, ok := dhString[transform.TransformID]; ok {
 		s := f(transform.AttributeType, transform.AttributeValue, transform.VariableLengthAttributeValue)
@@ -88,9 +254,74 @@ func ToTransform(dhType DHType) *message.Transform {
 	return t
 }
 
+// DHType is a Diffie-Hellman group. Secrets, public values and shared keys
+// are handled as the group defines them, so that both MODP groups, whose
+// secret is an exponent, and elliptic curve groups, whose secret is a raw
+// scalar, fit the same interface.
 type DHType interface {
 	TransformID() uint16
+	Name() string
 	getAttribute() (bool, uint16, uint16, []byte)
-	GetSharedKey(secret, peerPublicValue *big.Int) []byte
-	GetPublicValue(secret *big.Int) []byte
+	// GenerateSecret returns a new private value of the group
+	GenerateSecret() (DHSecret, error)
+	// GetSharedKey returns the shared key of secret and of the public value
+	// of the peer, encoded as the Key Exchange payload data
+	GetSharedKey(secret DHSecret, peerPublicValue []byte) ([]byte, error)
+	// GetPublicValue returns the public value of secret, encoded as the Key
+	// Exchange payload data
+	GetPublicValue(secret DHSecret) ([]byte, error)
+	// GetSecurityStrength returns the security strength of the group in
+	// bits: the estimated work to solve its discrete logarithm problem,
+	// comparable to the key size of a symmetric cipher. It ranks the groups
+	// in SupportedGroupsByPreference, and each group documents the source of
+	// its value.
+	GetSecurityStrength() int
+	// GetSecretBitLength returns the bit length of the private value. For
+	// the MODP groups it is twice the security strength as NIST SP 800-56A
+	// section 5.6.1.1.1 requires: a longer exponent only slows down the
+	// exponentiations
+	GetSecretBitLength() int
+	// ValidatePublicValue returns ErrInvalidPublicValue if peerPublicValue
+	// is not a valid public value of the group, which would make the shared
+	// key predictable
+	ValidatePublicValue(peerPublicValue []byte) error
+}
+
+// generateModPSecret returns a random exponent of exactly bitLength bits
+func generateModPSecret(bitLength int) (DHSecret, error) {
+	// Random in [0, 2^(n-1)), with the top bit set
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(bitLength-1))
+	number, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return DHSecret{}, errors.Wrapf(err, "Generate Diffie-Hellman secret")
+	}
+	number.SetBit(number, bitLength-1, 1)
+	return NewDHSecret(number), nil
+}
+
+// modpExp returns base^exponent mod factor, left padded with zeros to the
+// length of factor as RFC 7296 section 3.4 requires
+func modpExp(base, exponent, factor *big.Int, factorBytesLength int) []byte {
+	value := new(big.Int).Exp(base, exponent, factor).Bytes()
+	padded := make([]byte, factorBytesLength)
+	copy(padded[factorBytesLength-len(value):], value)
+	return padded
+}
+
+// validateModPPublicValue rejects a public value of a MODP group that is
+// longer than the prime, or outside [2, p-2]: 0, 1 and p-1 give a shared key
+// of 0, 1 or +-1 whatever the secret, RFC 7296 section 5
+func validateModPPublicValue(peerPublicValue []byte, factor *big.Int, factorBytesLength int) error {
+	if len(peerPublicValue) > factorBytesLength {
+		return errors.Wrapf(ErrInvalidPublicValue, "public value length %d exceeds %d bytes",
+			len(peerPublicValue), factorBytesLength)
+	}
+	peer := new(big.Int).SetBytes(peerPublicValue)
+	if peer.Cmp(big.NewInt(1)) <= 0 {
+		return errors.Wrapf(ErrInvalidPublicValue, "public value %s is at most 1", peer)
+	}
+	if peer.Cmp(new(big.Int).Sub(factor, big.NewInt(1))) >= 0 {
+		return errors.Wrapf(ErrInvalidPublicValue, "public value is at least p-1")
+	}
+	return nil
 }
@@ -3,6 +3,8 @@ package dh
 import (
 	"math/big"
 
+	"github.com/pkg/errors"
+
 	"github.com/nathaniel-bennett/ike/message"
 )
 
@@ -35,20 +37,41 @@ func (t *Dh1024BitModp) TransformID() uint16 {
 	return message.DH_1024_BIT_MODP
 }
 
+func (t *Dh1024BitModp) Name() string {
+	return DH_1024_BIT_MODP
+}
+
 func (t *Dh1024BitModp) getAttribute() (bool, uint16, uint16, []byte) {
 	return false, 0, 0, nil
 }
 
-func (t *Dh1024BitModp) GetSharedKey(secret, peerPublicValue *big.Int) []byte {
-	sharedKey := new(big.Int).Exp(peerPublicValue, secret, t.factor).Bytes()
-	prependZero := make([]byte, t.factorBytesLength-len(sharedKey))
-	sharedKey = append(prependZero, sharedKey...)
-	return sharedKey
+// GetSecurityStrength returns 80, from NIST SP 800-57 part 1 revision 5, table 2
+func (t *Dh1024BitModp) GetSecurityStrength() int {
+	return 80
+}
+
+func (t *Dh1024BitModp) GetSecretBitLength() int {
+	return 2 * t.GetSecurityStrength()
+}
+
+func (t *Dh1024BitModp) GenerateSecret() (DHSecret, error) {
+	return generateModPSecret(t.GetSecretBitLength())
+}
+
+func (t *Dh1024BitModp) ValidatePublicValue(peerPublicValue []byte) error {
+	return validateModPPublicValue(peerPublicValue, t.factor, t.factorBytesLength)
+}
+
+func (t *Dh1024BitModp) GetSharedKey(secret DHSecret, peerPublicValue []byte) ([]byte, error) {
+	if secret.Int() == nil {
+		return nil, errors.Errorf("Dh1024BitModp: secret is not an exponent")
+	}
+	return modpExp(new(big.Int).SetBytes(peerPublicValue), secret.Int(), t.factor, t.factorBytesLength), nil
 }
 
-func (t *Dh1024BitModp) GetPublicValue(secret *big.Int) []byte {
-	localPublicValue := new(big.Int).Exp(t.generator, secret, t.factor).Bytes()
-	prependZero := make([]byte, t.factorBytesLength-len(localPublicValue))
-	localPublicValue = append(prependZero, localPublicValue...)
-	return localPublicValue
+func (t *Dh1024BitModp) GetPublicValue(secret DHSecret) ([]byte, error) {
+	if secret.Int() == nil {
+		return nil, errors.Errorf("Dh1024BitModp: secret is not an exponent")
+	}
+	return modpExp(t.generator, secret.Int(), t.factor, t.factorBytesLength), nil
 }
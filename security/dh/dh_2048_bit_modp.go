@@ -3,6 +3,8 @@ package dh
 import (
 	"math/big"
 
+	"github.com/pkg/errors"
+
 	"github.com/nathaniel-bennett/ike/message"
 )
 
@@ -43,20 +45,41 @@ func (t *DH2048BitModp) TransformID() uint16 {
 	return message.DH_2048_BIT_MODP
 }
 
+func (t *DH2048BitModp) Name() string {
+	return DH_2048_BIT_MODP
+}
+
 func (t *DH2048BitModp) getAttribute() (bool, uint16, uint16, []byte) {
 	return false, 0, 0, nil
 }
 
-func (t *DH2048BitModp) GetSharedKey(secret, peerPublicValue *big.Int) []byte {
-	sharedKey := new(big.Int).Exp(peerPublicValue, secret, t.factor).Bytes()
-	prependZero := make([]byte, t.factorBytesLength-len(sharedKey))
-	sharedKey = append(prependZero, sharedKey...)
-	return sharedKey
+// GetSecurityStrength returns 112, from NIST SP 800-57 part 1 revision 5, table 2
+func (t *DH2048BitModp) GetSecurityStrength() int {
+	return 112
+}
+
+func (t *DH2048BitModp) GetSecretBitLength() int {
+	return 2 * t.GetSecurityStrength()
+}
+
+func (t *DH2048BitModp) GenerateSecret() (DHSecret, error) {
+	return generateModPSecret(t.GetSecretBitLength())
+}
+
+func (t *DH2048BitModp) ValidatePublicValue(peerPublicValue []byte) error {
+	return validateModPPublicValue(peerPublicValue, t.factor, t.factorBytesLength)
+}
+
+func (t *DH2048BitModp) GetSharedKey(secret DHSecret, peerPublicValue []byte) ([]byte, error) {
+	if secret.Int() == nil {
+		return nil, errors.Errorf("DH2048BitModp: secret is not an exponent")
+	}
+	return modpExp(new(big.Int).SetBytes(peerPublicValue), secret.Int(), t.factor, t.factorBytesLength), nil
 }
 
-func (t *DH2048BitModp) GetPublicValue(secret *big.Int) []byte {
-	localPublicValue := new(big.Int).Exp(t.generator, secret, t.factor).Bytes()
-	prependZero := make([]byte, t.factorBytesLength-len(localPublicValue))
-	localPublicValue = append(prependZero, localPublicValue...)
-	return localPublicValue
+func (t *DH2048BitModp) GetPublicValue(secret DHSecret) ([]byte, error) {
+	if secret.Int() == nil {
+		return nil, errors.Errorf("DH2048BitModp: secret is not an exponent")
+	}
+	return modpExp(t.generator, secret.Int(), t.factor, t.factorBytesLength), nil
 }
@@ -0,0 +1,57 @@
+package dh
+
+import (
+	"crypto/elliptic"
+
+	"github.com/nathaniel-bennett/ike/message"
+)
+
+func toString_DH_256_BIT_RANDOM_ECP(attrType uint16, intValue uint16, bytesValue []byte) string {
+	return DH_256_BIT_RANDOM_ECP
+}
+
+var _ DHType = &DhEcp256{}
+
+// DhEcp256 is the NIST P-256 curve, as defined in RFC 5903 for IKEv2. Public
+// values are 64 bytes and shared keys 32 bytes.
+type DhEcp256 struct {
+	curve elliptic.Curve
+}
+
+func (t *DhEcp256) TransformID() uint16 {
+	return message.DH_256_BIT_RANDOM_ECP
+}
+
+func (t *DhEcp256) Name() string {
+	return DH_256_BIT_RANDOM_ECP
+}
+
+func (t *DhEcp256) getAttribute() (bool, uint16, uint16, []byte) {
+	return false, 0, 0, nil
+}
+
+// GetSecurityStrength returns 128, from NIST SP 800-57 part 1 revision 5, table 2
+func (t *DhEcp256) GetSecurityStrength() int {
+	return 128
+}
+
+func (t *DhEcp256) GetSecretBitLength() int {
+	return t.curve.Params().N.BitLen()
+}
+
+func (t *DhEcp256) GenerateSecret() (DHSecret, error) {
+	return generateECPSecret(t.curve)
+}
+
+func (t *DhEcp256) ValidatePublicValue(peerPublicValue []byte) error {
+	_, _, err := validateECPPublicValue(t.curve, peerPublicValue)
+	return err
+}
+
+func (t *DhEcp256) GetSharedKey(secret DHSecret, peerPublicValue []byte) ([]byte, error) {
+	return ecpSharedKey(t.curve, secret, peerPublicValue)
+}
+
+func (t *DhEcp256) GetPublicValue(secret DHSecret) ([]byte, error) {
+	return ecpPublicValue(t.curve, secret)
+}
@@ -0,0 +1,57 @@
+package dh
+
+import (
+	"crypto/elliptic"
+
+	"github.com/nathaniel-bennett/ike/message"
+)
+
+func toString_DH_384_BIT_RANDOM_ECP(attrType uint16, intValue uint16, bytesValue []byte) string {
+	return DH_384_BIT_RANDOM_ECP
+}
+
+var _ DHType = &DhEcp384{}
+
+// DhEcp384 is the NIST P-384 curve, as defined in RFC 5903 for IKEv2. Public
+// values are 96 bytes and shared keys 48 bytes.
+type DhEcp384 struct {
+	curve elliptic.Curve
+}
+
+func (t *DhEcp384) TransformID() uint16 {
+	return message.DH_384_BIT_RANDOM_ECP
+}
+
+func (t *DhEcp384) Name() string {
+	return DH_384_BIT_RANDOM_ECP
+}
+
+func (t *DhEcp384) getAttribute() (bool, uint16, uint16, []byte) {
+	return false, 0, 0, nil
+}
+
+// GetSecurityStrength returns 192, from NIST SP 800-57 part 1 revision 5, table 2
+func (t *DhEcp384) GetSecurityStrength() int {
+	return 192
+}
+
+func (t *DhEcp384) GetSecretBitLength() int {
+	return t.curve.Params().N.BitLen()
+}
+
+func (t *DhEcp384) GenerateSecret() (DHSecret, error) {
+	return generateECPSecret(t.curve)
+}
+
+func (t *DhEcp384) ValidatePublicValue(peerPublicValue []byte) error {
+	_, _, err := validateECPPublicValue(t.curve, peerPublicValue)
+	return err
+}
+
+func (t *DhEcp384) GetSharedKey(secret DHSecret, peerPublicValue []byte) ([]byte, error) {
+	return ecpSharedKey(t.curve, secret, peerPublicValue)
+}
+
+func (t *DhEcp384) GetPublicValue(secret DHSecret) ([]byte, error) {
+	return ecpPublicValue(t.curve, secret)
+}
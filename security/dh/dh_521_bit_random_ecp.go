@@ -0,0 +1,57 @@
+package dh
+
+import (
+	"crypto/elliptic"
+
+	"github.com/nathaniel-bennett/ike/message"
+)
+
+func toString_DH_521_BIT_RANDOM_ECP(attrType uint16, intValue uint16, bytesValue []byte) string {
+	return DH_521_BIT_RANDOM_ECP
+}
+
+var _ DHType = &DhEcp521{}
+
+// DhEcp521 is the NIST P-521 curve, as defined in RFC 5903 for IKEv2. Public
+// values are 132 bytes and shared keys 66 bytes.
+type DhEcp521 struct {
+	curve elliptic.Curve
+}
+
+func (t *DhEcp521) TransformID() uint16 {
+	return message.DH_521_BIT_RANDOM_ECP
+}
+
+func (t *DhEcp521) Name() string {
+	return DH_521_BIT_RANDOM_ECP
+}
+
+func (t *DhEcp521) getAttribute() (bool, uint16, uint16, []byte) {
+	return false, 0, 0, nil
+}
+
+// GetSecurityStrength returns 256, from NIST SP 800-57 part 1 revision 5, table 2
+func (t *DhEcp521) GetSecurityStrength() int {
+	return 256
+}
+
+func (t *DhEcp521) GetSecretBitLength() int {
+	return t.curve.Params().N.BitLen()
+}
+
+func (t *DhEcp521) GenerateSecret() (DHSecret, error) {
+	return generateECPSecret(t.curve)
+}
+
+func (t *DhEcp521) ValidatePublicValue(peerPublicValue []byte) error {
+	_, _, err := validateECPPublicValue(t.curve, peerPublicValue)
+	return err
+}
+
+func (t *DhEcp521) GetSharedKey(secret DHSecret, peerPublicValue []byte) ([]byte, error) {
+	return ecpSharedKey(t.curve, secret, peerPublicValue)
+}
+
+func (t *DhEcp521) GetPublicValue(secret DHSecret) ([]byte, error) {
+	return ecpPublicValue(t.curve, secret)
+}
@@ -0,0 +1,118 @@
+package dh
+
+import (
+	"math/big"
+
+	"github.com/pkg/errors"
+
+	"github.com/nathaniel-bennett/ike/message"
+)
+
+const (
+	// Parameters
+	Group17PrimeString string = "FFFFFFFFFFFFFFFFC90FDAA22168C234" +
+		"C4C6628B80DC1CD129024E088A67CC74" +
+		"020BBEA63B139B22514A08798E3404DD" +
+		"EF9519B3CD3A431B302B0A6DF25F1437" +
+		"4FE1356D6D51C245E485B576625E7EC6" +
+		"F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+		"EE386BFB5A899FA5AE9F24117C4B1FE6" +
+		"49286651ECE45B3DC2007CB8A163BF05" +
+		"98DA48361C55D39A69163FA8FD24CF5F" +
+		"83655D23DCA3AD961C62F356208552BB" +
+		"9ED529077096966D670C354E4ABC9804" +
+		"F1746C08CA18217C32905E462E36CE3B" +
+		"E39E772C180E86039B2783A2EC07A28F" +
+		"B5C55DF06F4C52C9DE2BCBF695581718" +
+		"3995497CEA956AE515D2261898FA0510" +
+		"15728E5A8AAAC42DAD33170D04507A33" +
+		"A85521ABDF1CBA64ECFB850458DBEF0A" +
+		"8AEA71575D060C7DB3970F85A6E1E4C7" +
+		"ABF5AE8CDB0933D71E8C94E04A25619D" +
+		"CEE3D2261AD2EE6BF12FFA06D98A0864" +
+		"D87602733EC86A64521F2B18177B200C" +
+		"BBE117577A615D6C770988C0BAD946E2" +
+		"08E24FA074E5AB3143DB5BFCE0FD108E" +
+		"4B82D120A92108011A723C12A787E6D7" +
+		"88719A10BDBA5B2699C327186AF4E23C" +
+		"1A946834B6150BDA2583E9CA2AD44CE8" +
+		"DBBBC2DB04DE8EF92E8EFC141FBECAA6" +
+		"287C59474E6BC05D99B2964FA090C3A2" +
+		"233BA186515BE7ED1F612970CEE2D7AF" +
+		"B81BDD762170481CD0069127D5B05AA9" +
+		"93B4EA988D8FDDC186FFB7DC90A6C08F" +
+		"4DF435C93402849236C3FAB4D27C7026" +
+		"C1D4DCB2602646DEC9751E763DBA37BD" +
+		"F8FF9406AD9E530EE5DB382F413001AE" +
+		"B06A53ED9027D831179727B0865A8918" +
+		"DA3EDBEBCF9B14ED44CE6CBACED4BB1B" +
+		"DB7F1447E6CC254B332051512BD7AF42" +
+		"6FB8F401378CD2BF5983CA01C64B92EC" +
+		"F032EA15D1721D03F482D7CE6E74FEF6" +
+		"D55E702F46980C82B5A84031900B1C9E" +
+		"59E7C97FBEC7E8F323A97A7E36CC88BE" +
+		"0F1D45B7FF585AC54BD407B22B4154AA" +
+		"CC8F6D7EBF48E1D814CC5ED20F8037E0" +
+		"A79715EEF29BE32806A1D58BB7C5DA76" +
+		"F550AA3D8A1FBFF0EB19CCB1A313D55C" +
+		"DA56C9EC2EF29632387FE8D76E3C0468" +
+		"043E8F663F4860EE12BF2D5B0B7474D6" +
+		"E694F91E6DCC4024FFFFFFFFFFFFFFFF"
+	Group17Generator = 2
+)
+
+func toString_DH_6144_BIT_MODP(attrType uint16, intValue uint16, bytesValue []byte) string {
+	return DH_6144_BIT_MODP
+}
+
+var _ DHType = &Dh6144BitModp{}
+
+type Dh6144BitModp struct {
+	factor            *big.Int
+	generator         *big.Int
+	factorBytesLength int
+}
+
+func (t *Dh6144BitModp) TransformID() uint16 {
+	return message.DH_6144_BIT_MODP
+}
+
+func (t *Dh6144BitModp) Name() string {
+	return DH_6144_BIT_MODP
+}
+
+func (t *Dh6144BitModp) getAttribute() (bool, uint16, uint16, []byte) {
+	return false, 0, 0, nil
+}
+
+// GetSecurityStrength returns 176, from the formula of FIPS 140-3 IG D.B (IG 7.5
+// in FIPS 140-2) rounded to a multiple of 8: SP 800-57 stops at 3072 bits
+func (t *Dh6144BitModp) GetSecurityStrength() int {
+	return 176
+}
+
+func (t *Dh6144BitModp) GetSecretBitLength() int {
+	return 2 * t.GetSecurityStrength()
+}
+
+func (t *Dh6144BitModp) GenerateSecret() (DHSecret, error) {
+	return generateModPSecret(t.GetSecretBitLength())
+}
+
+func (t *Dh6144BitModp) ValidatePublicValue(peerPublicValue []byte) error {
+	return validateModPPublicValue(peerPublicValue, t.factor, t.factorBytesLength)
+}
+
+func (t *Dh6144BitModp) GetSharedKey(secret DHSecret, peerPublicValue []byte) ([]byte, error) {
+	if secret.Int() == nil {
+		return nil, errors.Errorf("Dh6144BitModp: secret is not an exponent")
+	}
+	return modpExp(new(big.Int).SetBytes(peerPublicValue), secret.Int(), t.factor, t.factorBytesLength), nil
+}
+
+func (t *Dh6144BitModp) GetPublicValue(secret DHSecret) ([]byte, error) {
+	if secret.Int() == nil {
+		return nil, errors.Errorf("Dh6144BitModp: secret is not an exponent")
+	}
+	return modpExp(t.generator, secret.Int(), t.factor, t.factorBytesLength), nil
+}
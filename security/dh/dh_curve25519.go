@@ -0,0 +1,86 @@
+package dh
+
+import (
+	"crypto/rand"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/curve25519"
+
+	"github.com/nathaniel-bennett/ike/message"
+)
+
+func toString_DH_CURVE25519(attrType uint16, intValue uint16, bytesValue []byte) string {
+	return DH_CURVE25519
+}
+
+var _ DHType = &DhCurve25519{}
+
+// DhCurve25519 is the X25519 function, as defined in RFC 8031 for IKEv2 and
+// RFC 7748. Secrets are 32 bytes scalars, and public values and shared keys
+// are 32 bytes u-coordinates.
+type DhCurve25519 struct{}
+
+func (t *DhCurve25519) TransformID() uint16 {
+	return message.DH_CURVE25519
+}
+
+func (t *DhCurve25519) Name() string {
+	return DH_CURVE25519
+}
+
+func (t *DhCurve25519) getAttribute() (bool, uint16, uint16, []byte) {
+	return false, 0, 0, nil
+}
+
+// GetSecurityStrength returns 128, from RFC 7748 section 7
+func (t *DhCurve25519) GetSecurityStrength() int {
+	return 128
+}
+
+func (t *DhCurve25519) GetSecretBitLength() int {
+	return 8 * curve25519.ScalarSize
+}
+
+// GenerateSecret returns 32 random bytes: X25519 clamps the scalar itself
+func (t *DhCurve25519) GenerateSecret() (DHSecret, error) {
+	scalar := make([]byte, curve25519.ScalarSize)
+	if _, err := io.ReadFull(rand.Reader, scalar); err != nil {
+		return DHSecret{}, errors.Wrapf(err, "Generate Diffie-Hellman secret")
+	}
+	return NewDHSecretBytes(scalar), nil
+}
+
+// ValidatePublicValue checks the length of the public value, RFC 8031
+// section 2. The public values of small order are only detected by
+// GetSharedKey, from the all-zero shared key they give.
+func (t *DhCurve25519) ValidatePublicValue(peerPublicValue []byte) error {
+	if len(peerPublicValue) != curve25519.PointSize {
+		return errors.Wrapf(ErrInvalidPublicValue, "public value length %d, expected %d bytes",
+			len(peerPublicValue), curve25519.PointSize)
+	}
+	return nil
+}
+
+// GetSharedKey returns ErrInvalidPublicValue for an all-zero shared key,
+// which RFC 8031 section 2 requires to be rejected
+func (t *DhCurve25519) GetSharedKey(secret DHSecret, peerPublicValue []byte) ([]byte, error) {
+	if len(secret.Bytes()) != curve25519.ScalarSize {
+		return nil, errors.Errorf("DhCurve25519: secret is not a %d bytes scalar", curve25519.ScalarSize)
+	}
+	if err := t.ValidatePublicValue(peerPublicValue); err != nil {
+		return nil, err
+	}
+	sharedKey, err := curve25519.X25519(secret.Bytes(), peerPublicValue)
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidPublicValue, "%v", err)
+	}
+	return sharedKey, nil
+}
+
+func (t *DhCurve25519) GetPublicValue(secret DHSecret) ([]byte, error) {
+	if len(secret.Bytes()) != curve25519.ScalarSize {
+		return nil, errors.Errorf("DhCurve25519: secret is not a %d bytes scalar", curve25519.ScalarSize)
+	}
+	return curve25519.X25519(secret.Bytes(), curve25519.Basepoint)
+}
@@ -0,0 +1,86 @@
+package dh
+
+import (
+	"crypto/rand"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/nathaniel-bennett/ike/message"
+	"github.com/nathaniel-bennett/ike/security/dh/x448"
+)
+
+func toString_DH_CURVE448(attrType uint16, intValue uint16, bytesValue []byte) string {
+	return DH_CURVE448
+}
+
+var _ DHType = &DhCurve448{}
+
+// DhCurve448 is the X448 function, as defined in RFC 8031 for IKEv2 and
+// RFC 7748. Secrets are 56 bytes scalars, and public values and shared keys
+// are 56 bytes u-coordinates.
+type DhCurve448 struct{}
+
+func (t *DhCurve448) TransformID() uint16 {
+	return message.DH_CURVE448
+}
+
+func (t *DhCurve448) Name() string {
+	return DH_CURVE448
+}
+
+func (t *DhCurve448) getAttribute() (bool, uint16, uint16, []byte) {
+	return false, 0, 0, nil
+}
+
+// GetSecurityStrength returns 224, from RFC 7748 section 7
+func (t *DhCurve448) GetSecurityStrength() int {
+	return 224
+}
+
+func (t *DhCurve448) GetSecretBitLength() int {
+	return 8 * x448.ScalarSize
+}
+
+// GenerateSecret returns 56 random bytes: X448 clamps the scalar itself
+func (t *DhCurve448) GenerateSecret() (DHSecret, error) {
+	scalar := make([]byte, x448.ScalarSize)
+	if _, err := io.ReadFull(rand.Reader, scalar); err != nil {
+		return DHSecret{}, errors.Wrapf(err, "Generate Diffie-Hellman secret")
+	}
+	return NewDHSecretBytes(scalar), nil
+}
+
+// ValidatePublicValue checks the length of the public value, RFC 8031
+// section 2. The public values of small order are only detected by
+// GetSharedKey, from the all-zero shared key they give.
+func (t *DhCurve448) ValidatePublicValue(peerPublicValue []byte) error {
+	if len(peerPublicValue) != x448.PointSize {
+		return errors.Wrapf(ErrInvalidPublicValue, "public value length %d, expected %d bytes",
+			len(peerPublicValue), x448.PointSize)
+	}
+	return nil
+}
+
+// GetSharedKey returns ErrInvalidPublicValue for an all-zero shared key,
+// which RFC 8031 section 2 requires to be rejected
+func (t *DhCurve448) GetSharedKey(secret DHSecret, peerPublicValue []byte) ([]byte, error) {
+	if len(secret.Bytes()) != x448.ScalarSize {
+		return nil, errors.Errorf("DhCurve448: secret is not a %d bytes scalar", x448.ScalarSize)
+	}
+	if err := t.ValidatePublicValue(peerPublicValue); err != nil {
+		return nil, err
+	}
+	sharedKey, err := x448.X448(secret.Bytes(), peerPublicValue)
+	if err != nil {
+		return nil, errors.Wrapf(ErrInvalidPublicValue, "%v", err)
+	}
+	return sharedKey, nil
+}
+
+func (t *DhCurve448) GetPublicValue(secret DHSecret) ([]byte, error) {
+	if len(secret.Bytes()) != x448.ScalarSize {
+		return nil, errors.Errorf("DhCurve448: secret is not a %d bytes scalar", x448.ScalarSize)
+	}
+	return x448.X448(secret.Bytes(), x448.Basepoint)
+}
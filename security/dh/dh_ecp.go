@@ -0,0 +1,118 @@
+package dh
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"io"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// The helpers below implement the random ECP groups of RFC 5903. A secret is
+// a scalar in [1, n-1] encoded on the byte length of the curve order, a public
+// value is the concatenation of the x and y coordinates, and the shared key is
+// the x coordinate of the shared point, each coordinate being left padded with
+// zeros to the byte length of the field (RFC 5903 sections 7 and 9).
+
+// ecpCoordinateLength returns the byte length of a coordinate of curve
+func ecpCoordinateLength(curve elliptic.Curve) int {
+	return (curve.Params().BitSize + 7) / 8
+}
+
+// Extra random bits drawn beyond the bit length of the curve order, which
+// make the bias of the reduction modulo the order negligible (FIPS 186-5
+// appendix A.2.1)
+const ecpScalarExtraBits = 64
+
+// generateECPSecret returns a random scalar of curve
+func generateECPSecret(curve elliptic.Curve) (DHSecret, error) {
+	scalar, err := generateECPScalar(curve, rand.Reader)
+	if err != nil {
+		return DHSecret{}, errors.Wrapf(err, "Generate Diffie-Hellman secret")
+	}
+	return NewDHSecretBytes(scalar), nil
+}
+
+// generateECPScalar returns a scalar in [1, n-1], n being the order of curve,
+// encoded on the byte length of n. It draws 64 more bits than n has and
+// reduces them modulo n-1 before adding 1, so zero is never returned and every
+// scalar is about equally likely.
+func generateECPScalar(curve elliptic.Curve, random io.Reader) ([]byte, error) {
+	n := curve.Params().N
+	buf := make([]byte, (n.BitLen()+ecpScalarExtraBits+7)/8)
+	if _, err := io.ReadFull(random, buf); err != nil {
+		return nil, errors.Wrapf(err, "%s: read random scalar", curve.Params().Name)
+	}
+
+	nMinus1 := new(big.Int).Sub(n, big.NewInt(1))
+	d := new(big.Int).SetBytes(buf)
+	d.Mod(d, nMinus1)
+	d.Add(d, big.NewInt(1))
+	for i := range buf {
+		buf[i] = 0
+	}
+
+	scalar := d.FillBytes(make([]byte, (n.BitLen()+7)/8))
+	ZeroizeBigInt(d)
+	return scalar, nil
+}
+
+// ecpPoint returns the coordinates encoded as x | y, left padded to the
+// length of a coordinate
+func ecpPoint(curve elliptic.Curve, x, y *big.Int) []byte {
+	length := ecpCoordinateLength(curve)
+	point := make([]byte, 2*length)
+	x.FillBytes(point[:length])
+	y.FillBytes(point[length:])
+	return point
+}
+
+// validateECPPublicValue rejects a public value that is not 2 coordinates
+// long, or whose point is not on curve. The point at infinity has no
+// encoding, and the coordinates (0, 0) are not on the curve.
+func validateECPPublicValue(curve elliptic.Curve, peerPublicValue []byte) (*big.Int, *big.Int, error) {
+	length := ecpCoordinateLength(curve)
+	if len(peerPublicValue) != 2*length {
+		return nil, nil, errors.Wrapf(ErrInvalidPublicValue, "public value length %d, expected %d bytes",
+			len(peerPublicValue), 2*length)
+	}
+	x := new(big.Int).SetBytes(peerPublicValue[:length])
+	y := new(big.Int).SetBytes(peerPublicValue[length:])
+	p := curve.Params().P
+	if x.Cmp(p) >= 0 || y.Cmp(p) >= 0 || !curve.IsOnCurve(x, y) {
+		return nil, nil, errors.Wrapf(ErrInvalidPublicValue, "public value is not a point of the curve")
+	}
+	return x, y, nil
+}
+
+func checkECPSecret(curve elliptic.Curve, secret DHSecret) error {
+	if len(secret.Bytes()) != (curve.Params().N.BitLen()+7)/8 {
+		return errors.Errorf("%s: secret is not a scalar of the curve", curve.Params().Name)
+	}
+	return nil
+}
+
+func ecpPublicValue(curve elliptic.Curve, secret DHSecret) ([]byte, error) {
+	if err := checkECPSecret(curve, secret); err != nil {
+		return nil, err
+	}
+	x, y := curve.ScalarBaseMult(secret.Bytes())
+	return ecpPoint(curve, x, y), nil
+}
+
+// ecpSharedKey returns the x coordinate of the shared point
+func ecpSharedKey(curve elliptic.Curve, secret DHSecret, peerPublicValue []byte) ([]byte, error) {
+	if err := checkECPSecret(curve, secret); err != nil {
+		return nil, err
+	}
+	peerX, peerY, err := validateECPPublicValue(curve, peerPublicValue)
+	if err != nil {
+		return nil, err
+	}
+	x, y := curve.ScalarMult(peerX, peerY, secret.Bytes())
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, errors.Wrapf(ErrInvalidPublicValue, "shared point is the point at infinity")
+	}
+	return ecpPoint(curve, x, y)[:ecpCoordinateLength(curve)], nil
+}
@@ -0,0 +1,516 @@
+package dh
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nathaniel-bennett/ike/message"
+)
+
+func TestStrToType(t *testing.T) {
+	testcases := []struct {
+		name        string
+		transformID uint16
+		groupName   string
+	}{
+		{"DH_1024_BIT_MODP", message.DH_1024_BIT_MODP, DH_1024_BIT_MODP},
+		{"modp1024", message.DH_1024_BIT_MODP, DH_1024_BIT_MODP},
+		{"group2", message.DH_1024_BIT_MODP, DH_1024_BIT_MODP},
+		{"DH_2048_BIT_MODP", message.DH_2048_BIT_MODP, DH_2048_BIT_MODP},
+		{"modp2048", message.DH_2048_BIT_MODP, DH_2048_BIT_MODP},
+		{"MODP2048", message.DH_2048_BIT_MODP, DH_2048_BIT_MODP},
+		{"group14", message.DH_2048_BIT_MODP, DH_2048_BIT_MODP},
+		{"DH_3072_BIT_MODP", message.DH_3072_BIT_MODP, DH_3072_BIT_MODP},
+		{"modp3072", message.DH_3072_BIT_MODP, DH_3072_BIT_MODP},
+		{"group15", message.DH_3072_BIT_MODP, DH_3072_BIT_MODP},
+		{"DH_4096_BIT_MODP", message.DH_4096_BIT_MODP, DH_4096_BIT_MODP},
+		{"modp4096", message.DH_4096_BIT_MODP, DH_4096_BIT_MODP},
+		{"group16", message.DH_4096_BIT_MODP, DH_4096_BIT_MODP},
+		{"DH_6144_BIT_MODP", message.DH_6144_BIT_MODP, DH_6144_BIT_MODP},
+		{"modp6144", message.DH_6144_BIT_MODP, DH_6144_BIT_MODP},
+		{"group17", message.DH_6144_BIT_MODP, DH_6144_BIT_MODP},
+		{"DH_8192_BIT_MODP", message.DH_8192_BIT_MODP, DH_8192_BIT_MODP},
+		{"modp8192", message.DH_8192_BIT_MODP, DH_8192_BIT_MODP},
+		{"group18", message.DH_8192_BIT_MODP, DH_8192_BIT_MODP},
+		{"DH_CURVE25519", message.DH_CURVE25519, DH_CURVE25519},
+		{"x25519", message.DH_CURVE25519, DH_CURVE25519},
+		{"group31", message.DH_CURVE25519, DH_CURVE25519},
+		{"DH_CURVE448", message.DH_CURVE448, DH_CURVE448},
+		{"x448", message.DH_CURVE448, DH_CURVE448},
+		{"group32", message.DH_CURVE448, DH_CURVE448},
+		{"DH_256_BIT_RANDOM_ECP", message.DH_256_BIT_RANDOM_ECP, DH_256_BIT_RANDOM_ECP},
+		{"ecp256", message.DH_256_BIT_RANDOM_ECP, DH_256_BIT_RANDOM_ECP},
+		{"group19", message.DH_256_BIT_RANDOM_ECP, DH_256_BIT_RANDOM_ECP},
+		{"DH_384_BIT_RANDOM_ECP", message.DH_384_BIT_RANDOM_ECP, DH_384_BIT_RANDOM_ECP},
+		{"p384", message.DH_384_BIT_RANDOM_ECP, DH_384_BIT_RANDOM_ECP},
+		{"group20", message.DH_384_BIT_RANDOM_ECP, DH_384_BIT_RANDOM_ECP},
+		{"DH_521_BIT_RANDOM_ECP", message.DH_521_BIT_RANDOM_ECP, DH_521_BIT_RANDOM_ECP},
+		{"ecp521", message.DH_521_BIT_RANDOM_ECP, DH_521_BIT_RANDOM_ECP},
+		{"group21", message.DH_521_BIT_RANDOM_ECP, DH_521_BIT_RANDOM_ECP},
+	}
+
+	for _, tc := range testcases {
+		dhType := StrToType(tc.name)
+		require.NotNil(t, dhType, tc.name)
+		require.Equal(t, tc.transformID, dhType.TransformID(), tc.name)
+		require.Equal(t, tc.groupName, dhType.Name(), tc.name)
+		require.Equal(t, dhType, StrToType(dhType.Name()), tc.name)
+	}
+
+	require.Nil(t, StrToType("modp768"))
+	require.Nil(t, StrToType("dh_2048_bit_modp"))
+}
+
+func TestSupportedGroupsByPreference(t *testing.T) {
+	expected := []uint16{
+		message.DH_521_BIT_RANDOM_ECP, message.DH_CURVE448, message.DH_8192_BIT_MODP, message.DH_384_BIT_RANDOM_ECP,
+		message.DH_6144_BIT_MODP, message.DH_4096_BIT_MODP, message.DH_CURVE25519,
+		message.DH_256_BIT_RANDOM_ECP, message.DH_3072_BIT_MODP, message.DH_2048_BIT_MODP,
+		message.DH_1024_BIT_MODP,
+	}
+	for i := 0; i < 10; i++ {
+		require.Equal(t, expected, SupportedGroupsByPreference())
+	}
+	require.ElementsMatch(t, SupportedGroups(), SupportedGroupsByPreference())
+
+	// Groups rank by security strength only, not by cost: MODP-8192, at 200
+	// bits, comes before the much faster P-384, at 192 bits
+	require.Equal(t, 200, StrToType(DH_8192_BIT_MODP).GetSecurityStrength())
+	require.Equal(t, 192, StrToType(DH_384_BIT_RANDOM_ECP).GetSecurityStrength())
+}
+
+func TestValidatePublicValue(t *testing.T) {
+	testcases := []struct {
+		name  string
+		prime string
+	}{
+		{DH_1024_BIT_MODP, Group2PrimeString},
+		{DH_2048_BIT_MODP, Group14PrimeString},
+		{DH_3072_BIT_MODP, Group15PrimeString},
+		{DH_4096_BIT_MODP, Group16PrimeString},
+		{DH_6144_BIT_MODP, Group17PrimeString},
+		{DH_8192_BIT_MODP, Group18PrimeString},
+	}
+
+	for _, tc := range testcases {
+		dhType := StrToType(tc.name)
+		p, ok := new(big.Int).SetString(tc.prime, 16)
+		require.True(t, ok)
+		one := big.NewInt(1)
+
+		for _, peer := range [][]byte{
+			nil,
+			{0x00},
+			{0x01},
+			new(big.Int).Sub(p, one).Bytes(),
+			p.Bytes(),
+			new(big.Int).Add(p, one).Bytes(),
+			append([]byte{0x01}, make([]byte, len(p.Bytes()))...),
+		} {
+			err := dhType.ValidatePublicValue(peer)
+			require.ErrorIs(t, err, ErrInvalidPublicValue, "%s: %x", tc.name, peer)
+		}
+
+		for _, peer := range [][]byte{{0x02}, new(big.Int).Sub(p, big.NewInt(2)).Bytes()} {
+			require.NoError(t, dhType.ValidatePublicValue(peer), "%s: %x", tc.name, peer)
+		}
+	}
+}
+
+func TestCurve25519(t *testing.T) {
+	// RFC 7748 section 6.1
+	alicePrivate, err := hex.DecodeString("77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a")
+	require.NoError(t, err)
+	alicePublic, err := hex.DecodeString("8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a")
+	require.NoError(t, err)
+	bobPrivate, err := hex.DecodeString("5dab087e624a8a4b79e17f8b83800ee66f3bb1292618b6fd1c2f8b27ff88e0eb")
+	require.NoError(t, err)
+	bobPublic, err := hex.DecodeString("de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f")
+	require.NoError(t, err)
+	expectedSharedKey, err := hex.DecodeString("4a5d9d5ba4ce2de1728e3bf480350f25e07e21c947d19e3376f09b3c1e161742")
+	require.NoError(t, err)
+
+	dhType := StrToType(DH_CURVE25519)
+	require.Equal(t, 128, dhType.GetSecurityStrength())
+	require.Equal(t, 256, dhType.GetSecretBitLength())
+
+	aliceSecret := NewDHSecretBytes(alicePrivate)
+	bobSecret := NewDHSecretBytes(bobPrivate)
+
+	publicValue, err := dhType.GetPublicValue(aliceSecret)
+	require.NoError(t, err)
+	require.Equal(t, alicePublic, publicValue)
+	publicValue, err = dhType.GetPublicValue(bobSecret)
+	require.NoError(t, err)
+	require.Equal(t, bobPublic, publicValue)
+
+	sharedKey, err := dhType.GetSharedKey(aliceSecret, bobPublic)
+	require.NoError(t, err)
+	require.Equal(t, expectedSharedKey, sharedKey)
+	sharedKey, err = dhType.GetSharedKey(bobSecret, alicePublic)
+	require.NoError(t, err)
+	require.Equal(t, expectedSharedKey, sharedKey)
+
+	secret, err := dhType.GenerateSecret()
+	require.NoError(t, err)
+	require.Len(t, secret.Bytes(), 32)
+	publicValue, err = dhType.GetPublicValue(secret)
+	require.NoError(t, err)
+	require.Len(t, publicValue, 32)
+}
+
+func TestCurve25519InvalidPublicValue(t *testing.T) {
+	dhType := StrToType(DH_CURVE25519)
+	secret, err := dhType.GenerateSecret()
+	require.NoError(t, err)
+
+	for _, peer := range [][]byte{nil, make([]byte, 31), make([]byte, 33)} {
+		require.ErrorIs(t, dhType.ValidatePublicValue(peer), ErrInvalidPublicValue, "%x", peer)
+		_, err = dhType.GetSharedKey(secret, peer)
+		require.ErrorIs(t, err, ErrInvalidPublicValue, "%x", peer)
+	}
+
+	// A point of small order gives an all-zero shared key
+	zero := make([]byte, 32)
+	require.NoError(t, dhType.ValidatePublicValue(zero))
+	_, err = dhType.GetSharedKey(secret, zero)
+	require.ErrorIs(t, err, ErrInvalidPublicValue)
+}
+
+func TestCurve448(t *testing.T) {
+	// RFC 7748 section 6.2
+	decode := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		require.NoError(t, err)
+		return b
+	}
+	alicePrivate := decode("9a8f4925d1519f5775cf46b04b5800d4ee9ee8bae8bc5565d498c28d" +
+		"d9c9baf574a9419744897391006382a6f127ab1d9ac2d8c0a598726b")
+	alicePublic := decode("9b08f7cc31b7e3e67d22d5aea121074a273bd2b83de09c63faa73d2c" +
+		"22c5d9bbc836647241d953d40c5b12da88120d53177f80e532c41fa0")
+	bobPrivate := decode("1c306a7ac2a0e2e0990b294470cba339e6453772b075811d8fad0d1d" +
+		"6927c120bb5ee8972b0d3e21374c9c921b09d1b0366f10b65173992d")
+	bobPublic := decode("3eb7a829b0cd20f5bcfc0b599b6feccf6da4627107bdb0d4f345b430" +
+		"27d8b972fc3e34fb4232a13ca706dcb57aec3dae07bdc1c67bf33609")
+	expectedSharedKey := decode("07fff4181ac6cc95ec1c16a94a0f74d12da232ce40a77552281d282b" +
+		"b60c0b56fd2464c335543936521c24403085d59a449a5037514a879d")
+
+	dhType := StrToType(DH_CURVE448)
+	require.Equal(t, 224, dhType.GetSecurityStrength())
+	require.Equal(t, 448, dhType.GetSecretBitLength())
+
+	aliceSecret := NewDHSecretBytes(alicePrivate)
+	bobSecret := NewDHSecretBytes(bobPrivate)
+
+	publicValue, err := dhType.GetPublicValue(aliceSecret)
+	require.NoError(t, err)
+	require.Equal(t, alicePublic, publicValue)
+	publicValue, err = dhType.GetPublicValue(bobSecret)
+	require.NoError(t, err)
+	require.Equal(t, bobPublic, publicValue)
+
+	sharedKey, err := dhType.GetSharedKey(aliceSecret, bobPublic)
+	require.NoError(t, err)
+	require.Equal(t, expectedSharedKey, sharedKey)
+	sharedKey, err = dhType.GetSharedKey(bobSecret, alicePublic)
+	require.NoError(t, err)
+	require.Equal(t, expectedSharedKey, sharedKey)
+}
+
+func TestCurve448KeyExchange(t *testing.T) {
+	dhType := StrToType(DH_CURVE448)
+
+	initiatorSecret, err := dhType.GenerateSecret()
+	require.NoError(t, err)
+	require.Len(t, initiatorSecret.Bytes(), 56)
+	responderSecret, err := dhType.GenerateSecret()
+	require.NoError(t, err)
+
+	initiatorPublicValue, err := dhType.GetPublicValue(initiatorSecret)
+	require.NoError(t, err)
+	require.Len(t, initiatorPublicValue, 56)
+	responderPublicValue, err := dhType.GetPublicValue(responderSecret)
+	require.NoError(t, err)
+
+	initiatorSharedKey, err := dhType.GetSharedKey(initiatorSecret, responderPublicValue)
+	require.NoError(t, err)
+	responderSharedKey, err := dhType.GetSharedKey(responderSecret, initiatorPublicValue)
+	require.NoError(t, err)
+	require.Len(t, initiatorSharedKey, 56)
+	require.Equal(t, initiatorSharedKey, responderSharedKey)
+
+	for _, peer := range [][]byte{nil, make([]byte, 32), make([]byte, 55), make([]byte, 57)} {
+		err = dhType.ValidatePublicValue(peer)
+		require.ErrorIs(t, err, ErrInvalidPublicValue, "%x", peer)
+		require.Contains(t, err.Error(), "expected 56 bytes")
+		_, err = dhType.GetSharedKey(initiatorSecret, peer)
+		require.ErrorIs(t, err, ErrInvalidPublicValue, "%x", peer)
+	}
+
+	// A point of small order gives an all-zero shared key
+	_, err = dhType.GetSharedKey(initiatorSecret, make([]byte, 56))
+	require.ErrorIs(t, err, ErrInvalidPublicValue)
+}
+
+func TestEcp256(t *testing.T) {
+	// RFC 5903 section 8.1
+	i := "c88f01f510d9ac3f70a292daa2316de544e9aab8afe84049c62a9c57862d1433"
+	gi := "dad0b65394221cf9b051e1feca5787d098dfe637fc90b9ef945d0c3772581180" +
+		"5271a0461cdb8252d61f1c456fa3e59ab1f45b33accf5f58389e0577b8990bb3"
+	r := "c6ef9c5d78ae012a011164acb397ce2088685d8f06bf9be0b283ab46476bee53"
+	gr := "d12dfb5289c8d4f81208b70270398c342296970a0bccb74c736fc7554494bf63" +
+		"56fbf3ca366cc23e8157854c13c58d6aac23f046ada30f8353e74f33039872ab"
+	girx := "d6840f6b42f6edafd13116e0e12565202fef8e9ece7dce03812464d04b9442de"
+
+	dhType := StrToType(DH_256_BIT_RANDOM_ECP)
+	require.Equal(t, 128, dhType.GetSecurityStrength())
+	require.Equal(t, 256, dhType.GetSecretBitLength())
+	testECPVector(t, dhType, i, gi, r, gr, girx)
+}
+
+func TestEcp384(t *testing.T) {
+	// RFC 5903 section 8.2
+	i := "099f3c7034d4a2c699884d73a375a67f7624ef7c6b3c0f160647b67414dce655e35b538041e649ee3faef896783ab194"
+	gi := "667842d7d180ac2cde6f74f37551f55755c7645c20ef73e31634fe72b4c55ee6de3ac808acb4bdb4c88732aee95f41aa" +
+		"9482ed1fc0eeb9cafc4984625ccfc23f65032149e0e144ada024181535a0f38eeb9fcff3c2c947dae69b4c634573a81c"
+	r := "41cb0779b4bdb85d47846725fbec3c9430fab46cc8dc5060855cc9bda0aa2942e0308312916b8ed2960e4bd55a7448fc"
+	gr := "e558dbef53eecde3d3fccfc1aea08a89a987475d12fd950d83cfa41732bc509d0d1ac43a0336def96fda41d0774a3571" +
+		"dcfbec7aacf3196472169e838430367f66eebe3c6e70c416dd5f0c68759dd1fff83fa40142209dff5eaad96db9e6386c"
+	girx := "11187331c279962d93d604243fd592cb9d0a926f422e47187521287e7156c5c4d603135569b9e9d09cf5d4a270f59746"
+
+	dhType := StrToType(DH_384_BIT_RANDOM_ECP)
+	require.Equal(t, 192, dhType.GetSecurityStrength())
+	require.Equal(t, 384, dhType.GetSecretBitLength())
+	testECPVector(t, dhType, i, gi, r, gr, girx)
+}
+
+func TestEcp521(t *testing.T) {
+	// RFC 5903 section 8.3
+	i := "0037ade9319a89f4dabdb3ef411aaccca5123c61acab57b5393dce47608172a095aa85a30fe1c2952c6771d937ba9777" +
+		"f5957b2639bab072462f68c27a57382d4a52"
+	gi := "0015417e84dbf28c0ad3c278713349dc7df153c897a1891bd98bab4357c9ecbee1e3bf42e00b8e380aeae57c2d107564" +
+		"941885942af5a7f4601723c4195d176ced3e" +
+		"017cae20b6641d2eeb695786d8c946146239d099e18e1d5a514c739d7cb4a10ad8a788015ac405d7799dc75e7b7d5b6c" +
+		"f2261a6a7f1507438bf01beb6ca3926f9582"
+	r := "0145ba99a847af43793fdd0e872e7cdfa16be30fdc780f97bccc3f078380201e9c677d600b343757a3bdbf2a3163e4c2" +
+		"f869cca7458aa4a4effc311f5cb151685eb9"
+	gr := "00d0b3975ac4b799f5bea16d5e13e9af971d5e9b984c9f39728b5e5739735a219b97c356436adc6e95bb0352f6be64a6" +
+		"c2912d4ef2d0433ced2b6171640012d9460f" +
+		"015c68226383956e3bd066e797b623c27ce0eac2f551a10c2c724d9852077b87220b6536c5c408a1d2aebb8e86d678ae" +
+		"49cb57091f4732296579ab44fcd17f0fc56a"
+	girx := "01144c7d79ae6956bc8edb8e7c787c4521cb086fa64407f97894e5e6b2d79b04d1427e73ca4baa240a34786859810c06" +
+		"b3c715a3a8cc3151f2bee417996d19f3ddea"
+
+	dhType := StrToType(DH_521_BIT_RANDOM_ECP)
+	require.Equal(t, 256, dhType.GetSecurityStrength())
+	require.Equal(t, 521, dhType.GetSecretBitLength())
+	testECPVector(t, dhType, i, gi, r, gr, girx)
+}
+
+// testECPVector checks the public values and the shared key computed from
+// the hexadecimal RFC 5903 test vector of dhType
+func testECPVector(t *testing.T, dhType DHType, i, gi, r, gr, girx string) {
+	decode := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		require.NoError(t, err)
+		return b
+	}
+	initiatorSecret := NewDHSecretBytes(decode(i))
+	responderSecret := NewDHSecretBytes(decode(r))
+
+	publicValue, err := dhType.GetPublicValue(initiatorSecret)
+	require.NoError(t, err)
+	require.Equal(t, decode(gi), publicValue)
+	publicValue, err = dhType.GetPublicValue(responderSecret)
+	require.NoError(t, err)
+	require.Equal(t, decode(gr), publicValue)
+
+	sharedKey, err := dhType.GetSharedKey(initiatorSecret, decode(gr))
+	require.NoError(t, err)
+	require.Equal(t, decode(girx), sharedKey)
+	sharedKey, err = dhType.GetSharedKey(responderSecret, decode(gi))
+	require.NoError(t, err)
+	require.Equal(t, decode(girx), sharedKey)
+
+	secret, err := dhType.GenerateSecret()
+	require.NoError(t, err)
+	publicValue, err = dhType.GetPublicValue(secret)
+	require.NoError(t, err)
+	require.NoError(t, dhType.ValidatePublicValue(publicValue))
+
+	// Not on the curve: the y coordinate is changed, or the point is (0, 0)
+	notOnCurve := decode(gr)
+	notOnCurve[len(notOnCurve)-1] ^= 0x01
+	for _, peer := range [][]byte{
+		nil,
+		decode(gr)[:len(gr)/2-1],
+		append(decode(gr), 0x00),
+		notOnCurve,
+		make([]byte, len(gr)/2),
+	} {
+		require.ErrorIs(t, dhType.ValidatePublicValue(peer), ErrInvalidPublicValue, "%x", peer)
+		_, err = dhType.GetSharedKey(secret, peer)
+		require.ErrorIs(t, err, ErrInvalidPublicValue, "%x", peer)
+	}
+}
+
+// constantReader returns the same byte forever
+type constantReader byte
+
+func (r constantReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(r)
+	}
+	return len(p), nil
+}
+
+func TestGenerateECPScalar(t *testing.T) {
+	one := big.NewInt(1)
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		n := curve.Params().N
+		nMinus1 := new(big.Int).Sub(n, one)
+		scalarLength := (n.BitLen() + 7) / 8
+
+		for i := 0; i < 100; i++ {
+			scalar, err := generateECPScalar(curve, rand.Reader)
+			require.NoError(t, err, curve.Params().Name)
+			require.Len(t, scalar, scalarLength, curve.Params().Name)
+			d := new(big.Int).SetBytes(scalar)
+			require.True(t, d.Sign() > 0 && d.Cmp(n) < 0, "%s: scalar %x out of range", curve.Params().Name, scalar)
+		}
+
+		// All-zero random gives 1 instead of 0, and all-ones random stays
+		// below n
+		scalar, err := generateECPScalar(curve, constantReader(0x00))
+		require.NoError(t, err)
+		require.Equal(t, 0, new(big.Int).SetBytes(scalar).Cmp(one), curve.Params().Name)
+		scalar, err = generateECPScalar(curve, constantReader(0xff))
+		require.NoError(t, err)
+		require.True(t, new(big.Int).SetBytes(scalar).Cmp(nMinus1) <= 0, curve.Params().Name)
+
+		_, err = generateECPScalar(curve, bytes.NewReader(nil))
+		require.Error(t, err)
+	}
+
+	for _, name := range []string{DH_256_BIT_RANDOM_ECP, DH_384_BIT_RANDOM_ECP, DH_521_BIT_RANDOM_ECP} {
+		dhType := StrToType(name)
+		secret, err := dhType.GenerateSecret()
+		require.NoError(t, err, name)
+		require.Len(t, secret.Bytes(), (dhType.GetSecretBitLength()+7)/8, name)
+	}
+}
+
+func TestModPKeyExchange(t *testing.T) {
+	testcases := []struct {
+		name         string
+		publicLength int
+	}{
+		{DH_1024_BIT_MODP, 128},
+		{DH_2048_BIT_MODP, 256},
+		{DH_3072_BIT_MODP, 384},
+		{DH_4096_BIT_MODP, 512},
+		{DH_6144_BIT_MODP, 768},
+		{DH_8192_BIT_MODP, 1024},
+	}
+
+	for _, tc := range testcases {
+		dhType := StrToType(tc.name)
+
+		initiatorSecret, err := dhType.GenerateSecret()
+		require.NoError(t, err, tc.name)
+		responderSecret, err := dhType.GenerateSecret()
+		require.NoError(t, err, tc.name)
+
+		initiatorPublicValue, err := dhType.GetPublicValue(initiatorSecret)
+		require.NoError(t, err, tc.name)
+		require.Len(t, initiatorPublicValue, tc.publicLength, tc.name)
+		responderPublicValue, err := dhType.GetPublicValue(responderSecret)
+		require.NoError(t, err, tc.name)
+		require.Len(t, responderPublicValue, tc.publicLength, tc.name)
+		require.NotEqual(t, initiatorPublicValue, responderPublicValue, tc.name)
+
+		require.NoError(t, dhType.ValidatePublicValue(initiatorPublicValue), tc.name)
+		require.NoError(t, dhType.ValidatePublicValue(responderPublicValue), tc.name)
+
+		initiatorSharedKey, err := dhType.GetSharedKey(initiatorSecret, responderPublicValue)
+		require.NoError(t, err, tc.name)
+		responderSharedKey, err := dhType.GetSharedKey(responderSecret, initiatorPublicValue)
+		require.NoError(t, err, tc.name)
+		require.Equal(t, initiatorSharedKey, responderSharedKey, tc.name)
+		require.Len(t, initiatorSharedKey, tc.publicLength, tc.name)
+
+		// A short result is left padded with zeros to the length of the prime
+		sharedKey, err := dhType.GetSharedKey(NewDHSecret(big.NewInt(1)), []byte{0x02})
+		require.NoError(t, err, tc.name)
+		expected := make([]byte, tc.publicLength)
+		expected[tc.publicLength-1] = 0x02
+		require.Equal(t, expected, sharedKey, tc.name)
+	}
+}
+
+func TestModPPrimes(t *testing.T) {
+	testcases := []struct {
+		prime     string
+		bitLength int
+	}{
+		{Group2PrimeString, 1024},
+		{Group14PrimeString, 2048},
+		{Group15PrimeString, 3072},
+		{Group16PrimeString, 4096},
+		{Group17PrimeString, 6144},
+		{Group18PrimeString, 8192},
+	}
+
+	allOnes := new(big.Int).SetUint64(^uint64(0))
+	for _, tc := range testcases {
+		p, ok := new(big.Int).SetString(tc.prime, 16)
+		require.True(t, ok)
+		require.Equal(t, tc.bitLength, p.BitLen())
+
+		// RFC 3526: the 64 high and low order bits are forced to 1
+		require.Equal(t, allOnes, new(big.Int).Rsh(p, uint(tc.bitLength-64)), tc.bitLength)
+		require.Equal(t, allOnes, new(big.Int).And(p, allOnes), tc.bitLength)
+
+		if testing.Short() {
+			continue
+		}
+		// p is a safe prime
+		require.True(t, p.ProbablyPrime(1), tc.bitLength)
+		q := new(big.Int).Rsh(p, 1)
+		require.True(t, q.ProbablyPrime(1), tc.bitLength)
+	}
+}
+
+func BenchmarkModPKeyExchange(b *testing.B) {
+	for _, name := range []string{
+		DH_1024_BIT_MODP, DH_2048_BIT_MODP, DH_3072_BIT_MODP,
+		DH_4096_BIT_MODP, DH_6144_BIT_MODP, DH_8192_BIT_MODP,
+	} {
+		dhType := StrToType(name)
+		peerSecret, err := dhType.GenerateSecret()
+		require.NoError(b, err)
+		peerPublicValue, err := dhType.GetPublicValue(peerSecret)
+		require.NoError(b, err)
+
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				secret, err := dhType.GenerateSecret()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err = dhType.GetPublicValue(secret); err != nil {
+					b.Fatal(err)
+				}
+				if _, err = dhType.GetSharedKey(secret, peerPublicValue); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
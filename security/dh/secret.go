@@ -0,0 +1,74 @@
+package dh
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+)
+
+const redacted = "[REDACTED]"
+
+// DHSecret holds the private Diffie-Hellman value: an exponent for the MODP
+// groups, or a raw scalar for the elliptic curve groups. It prints as
+// "[REDACTED]" with any fmt verb, so that it cannot leak into a log line; the
+// value is only reachable through Int or Bytes.
+type DHSecret struct {
+	value  *big.Int
+	scalar []byte
+}
+
+// NewDHSecret wraps the exponent value into a DHSecret
+func NewDHSecret(value *big.Int) DHSecret {
+	return DHSecret{value: value}
+}
+
+// NewDHSecretBytes wraps the raw scalar into a DHSecret
+func NewDHSecretBytes(scalar []byte) DHSecret {
+	return DHSecret{scalar: scalar}
+}
+
+// Int returns the secret exponent, or nil for a raw scalar secret
+func (s DHSecret) Int() *big.Int {
+	return s.value
+}
+
+// Bytes returns the raw scalar, or nil for an exponent secret
+func (s DHSecret) Bytes() []byte {
+	return s.scalar
+}
+
+// Zeroize overwrites the secret, with ZeroizeBigInt for an exponent, once the
+// shared key is computed
+func (s DHSecret) Zeroize() {
+	ZeroizeBigInt(s.value)
+	for i := range s.scalar {
+		s.scalar[i] = 0
+	}
+}
+
+// ZeroizeBigInt overwrites the words backing x with zeros and sets x to 0.
+// This is best effort: big.Int gives no control over its memory, and copies
+// made by earlier operations, such as a reallocation when x grew or the
+// temporaries of Exp, are not reached.
+func ZeroizeBigInt(x *big.Int) {
+	if x == nil {
+		return
+	}
+	words := x.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	x.SetInt64(0)
+}
+
+func (s DHSecret) String() string {
+	return redacted
+}
+
+func (s DHSecret) GoString() string {
+	return redacted
+}
+
+func (s DHSecret) Format(f fmt.State, verb rune) {
+	_, _ = io.WriteString(f, redacted)
+}
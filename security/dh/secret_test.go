@@ -0,0 +1,42 @@
+package dh
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHSecretRedacted(t *testing.T) {
+	secret := NewDHSecret(big.NewInt(0x1234567))
+
+	require.Equal(t, "[REDACTED]", secret.String())
+	for _, format := range []string{"%v", "%+v", "%#v", "%s", "%d", "%x"} {
+		require.Equal(t, "[REDACTED]", fmt.Sprintf(format, secret), format)
+		require.Equal(t, "[REDACTED]", fmt.Sprintf(format, &secret), format)
+	}
+	require.Equal(t, "{[REDACTED]}", fmt.Sprintf("%v", struct{ S DHSecret }{secret}))
+
+	require.Equal(t, int64(0x1234567), secret.Int().Int64())
+}
+
+func TestZeroizeBigInt(t *testing.T) {
+	x, ok := new(big.Int).SetString("123456789abcdef0123456789abcdef0123456789abcdef", 16)
+	require.True(t, ok)
+	words := x.Bits()
+	require.NotEmpty(t, words)
+
+	ZeroizeBigInt(x)
+	require.Equal(t, 0, x.Sign())
+	// The backing words themselves are overwritten
+	for i, w := range words {
+		require.Zero(t, w, "word %d", i)
+	}
+
+	ZeroizeBigInt(nil)
+
+	secret := NewDHSecret(big.NewInt(0x1234567))
+	secret.Zeroize()
+	require.Equal(t, 0, secret.Int().Sign())
+}
@@ -0,0 +1,255 @@
+// Package x448 implements the X448 function, as defined in RFC 7748
+// section 5.
+//
+// The field arithmetic works on fixed-size limbs and the ladder swaps its
+// points with a mask, so that neither the timing nor the memory accesses of
+// X448 depend on the scalar.
+package x448
+
+import (
+	"crypto/subtle"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ScalarSize is the size of the scalar input to X448
+	ScalarSize = 56
+	// PointSize is the size of the point input to X448
+	PointSize = 56
+)
+
+// Basepoint is the canonical Curve448 generator, u = 5
+var Basepoint = func() []byte {
+	b := make([]byte, PointSize)
+	b[0] = 5
+	return b
+}()
+
+// a24 = (156326 - 2) / 4, RFC 7748 section 5
+const a24 = 39081
+
+// X448 returns the result of the scalar multiplication (scalar * point),
+// according to RFC 7748 section 5. It returns an error if the inputs do not
+// have the expected sizes, or if the result is the all-zero value, which a
+// point of small order gives, as RFC 7748 section 6.2 allows to check.
+func X448(scalar, point []byte) ([]byte, error) {
+	if len(scalar) != ScalarSize {
+		return nil, errors.Errorf("bad scalar length: %d, expected %d", len(scalar), ScalarSize)
+	}
+	if len(point) != PointSize {
+		return nil, errors.Errorf("bad point length: %d, expected %d", len(point), PointSize)
+	}
+
+	var u fieldElement
+	u.setBytes(point)
+	out := ladder(decodeScalar(scalar), &u).bytes()
+	if subtle.ConstantTimeCompare(out, make([]byte, PointSize)) == 1 {
+		return nil, errors.Errorf("bad input point: low order point")
+	}
+	return out, nil
+}
+
+// decodeScalar clears the two least significant bits and sets the most
+// significant one
+func decodeScalar(scalar []byte) []byte {
+	k := make([]byte, ScalarSize)
+	copy(k, scalar)
+	k[0] &= 252
+	k[ScalarSize-1] |= 128
+	return k
+}
+
+// ladder runs the Montgomery ladder of RFC 7748 section 5 on the u
+// coordinate, k being the decoded little-endian scalar
+func ladder(k []byte, u *fieldElement) *fieldElement {
+	x1 := *u
+	var x2, z2, x3, z3 fieldElement
+	x2[0], z3[0] = 1, 1
+	x3 = x1
+
+	var a, aa, b, bb, e, c, d, da, cb fieldElement
+	swap := uint64(0)
+	for t := 8*ScalarSize - 1; t >= 0; t-- {
+		kt := uint64(k[t/8]>>uint(t%8)) & 1
+		swap ^= kt
+		cswap(&x2, &x3, swap)
+		cswap(&z2, &z3, swap)
+		swap = kt
+
+		a.add(&x2, &z2)
+		aa.mul(&a, &a)
+		b.sub(&x2, &z2)
+		bb.mul(&b, &b)
+		e.sub(&aa, &bb)
+		c.add(&x3, &z3)
+		d.sub(&x3, &z3)
+		da.mul(&d, &a)
+		cb.mul(&c, &b)
+		x3.add(&da, &cb)
+		x3.mul(&x3, &x3)
+		z3.sub(&da, &cb)
+		z3.mul(&z3, &z3)
+		z3.mul(&x1, &z3)
+		x2.mul(&aa, &bb)
+		z2.mulSmall(&e, a24)
+		z2.add(&aa, &z2)
+		z2.mul(&e, &z2)
+	}
+	cswap(&x2, &x3, swap)
+	cswap(&z2, &z3, swap)
+
+	z2.invert(&z2)
+	return x2.mul(&x2, &z2)
+}
+
+const (
+	limbs    = 16
+	limbBits = 28
+	limbMask = 1<<limbBits - 1
+)
+
+// fieldElement is an element of GF(p), p = 2^448 - 2^224 - 1, as 16 limbs of
+// 28 bits, least significant first. Limbs may exceed 28 bits between
+// operations, but stay below 2^29, and the value is not always fully
+// reduced: only bytes returns it in canonical form.
+type fieldElement [limbs]uint64
+
+// pLimbs holds p in limbs: all ones but the lowest bit of limb 8, for 2^224
+var pLimbs = fieldElement{
+	limbMask, limbMask, limbMask, limbMask, limbMask, limbMask, limbMask, limbMask,
+	limbMask - 1, limbMask, limbMask, limbMask, limbMask, limbMask, limbMask, limbMask,
+}
+
+// setBytes sets v to the 56 bytes little-endian value b. Values above p are
+// accepted, as RFC 7748 section 5 requires for X448.
+func (v *fieldElement) setBytes(b []byte) {
+	for i := 0; i < limbs; i += 2 {
+		var w uint64
+		for j := 0; j < 7; j++ {
+			w |= uint64(b[i/2*7+j]) << uint(8*j)
+		}
+		v[i] = w & limbMask
+		v[i+1] = w >> limbBits
+	}
+}
+
+// bytes returns v fully reduced modulo p, as 56 bytes little-endian
+func (v *fieldElement) bytes() []byte {
+	t := *v
+	t.carry()
+
+	// t is below 2p: subtract p, and add it back if the result is negative
+	var s fieldElement
+	var borrow int64
+	for i := 0; i < limbs; i++ {
+		borrow += int64(t[i]) - int64(pLimbs[i])
+		s[i] = uint64(borrow) & limbMask
+		borrow >>= limbBits
+	}
+	mask := uint64(borrow)
+	var carry uint64
+	for i := 0; i < limbs; i++ {
+		carry += s[i] + pLimbs[i]&mask
+		s[i] = carry & limbMask
+		carry >>= limbBits
+	}
+
+	out := make([]byte, PointSize)
+	for i := 0; i < limbs; i += 2 {
+		w := s[i] | s[i+1]<<limbBits
+		for j := 0; j < 7; j++ {
+			out[i/2*7+j] = byte(w >> uint(8*j))
+		}
+	}
+	return out
+}
+
+// carry brings every limb back below 2^28, but limbs 0 and 8 which may reach
+// it. The carry out of the top limb is folded back as 2^448 = 2^224 + 1.
+func (v *fieldElement) carry() {
+	for pass := 0; pass < 2; pass++ {
+		for i := 0; i < limbs-1; i++ {
+			v[i+1] += v[i] >> limbBits
+			v[i] &= limbMask
+		}
+		top := v[limbs-1] >> limbBits
+		v[limbs-1] &= limbMask
+		v[0] += top
+		v[limbs/2] += top
+	}
+}
+
+func (v *fieldElement) add(a, b *fieldElement) *fieldElement {
+	for i := range v {
+		v[i] = a[i] + b[i]
+	}
+	v.carry()
+	return v
+}
+
+// sub adds 4p ahead of subtracting, so that no limb goes negative
+func (v *fieldElement) sub(a, b *fieldElement) *fieldElement {
+	for i := range v {
+		v[i] = a[i] + 4*pLimbs[i] - b[i]
+	}
+	v.carry()
+	return v
+}
+
+func (v *fieldElement) mul(a, b *fieldElement) *fieldElement {
+	// The products are below 2^58, and their sums below 2^62
+	var t [2 * limbs]uint64
+	for i := 0; i < limbs; i++ {
+		for j := 0; j < limbs; j++ {
+			t[i+j] += a[i] * b[j]
+		}
+	}
+	for i := 0; i < 2*limbs-1; i++ {
+		t[i+1] += t[i] >> limbBits
+		t[i] &= limbMask
+	}
+	// Fold the high limbs, as 2^(448+28i) = 2^(224+28i) + 2^(28i)
+	for i := 2*limbs - 1; i >= limbs; i-- {
+		t[i-limbs] += t[i]
+		t[i-limbs/2] += t[i]
+	}
+	copy(v[:], t[:limbs])
+	v.carry()
+	return v
+}
+
+func (v *fieldElement) mulSmall(a *fieldElement, n uint64) *fieldElement {
+	for i := range v {
+		v[i] = a[i] * n
+	}
+	v.carry()
+	return v
+}
+
+// invert sets v to a^(p-2), which is 1/a or 0 if a is 0. The exponent is
+// public: its bits are all ones but bits 224 and 1.
+func (v *fieldElement) invert(a *fieldElement) *fieldElement {
+	x := *a
+	var r fieldElement
+	r[0] = 1
+	for t := 447; t >= 0; t-- {
+		r.mul(&r, &r)
+		if t != 224 && t != 1 {
+			r.mul(&r, &x)
+		}
+	}
+	*v = r
+	return v
+}
+
+// cswap swaps a and b if swap is 1, and leaves them if it is 0, without
+// branching on swap
+func cswap(a, b *fieldElement, swap uint64) {
+	mask := -swap
+	for i := range a {
+		t := mask & (a[i] ^ b[i])
+		a[i] ^= t
+		b[i] ^= t
+	}
+}
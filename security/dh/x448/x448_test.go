@@ -0,0 +1,106 @@
+package x448
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func decodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+func TestX448KnownAnswer(t *testing.T) {
+	// Test vectors of RFC 7748 section 5.2
+	testcases := []struct {
+		scalar string
+		u      string
+		out    string
+	}{
+		{
+			"3d262fddf9ec8e88495266fea19a34d28882acef045104d0d1aae121700a779c984c24f8cdd78fbff44943eba368f54b29259a4f1c600ad3",
+			"06fce640fa3487bfda5f6cf2d5263f8aad88334cbd07437f020f08f9814dc031ddbdc38c19c6da2583fa5429db94ada18aa7a7fb4ef8a086",
+			"ce3e4ff95a60dc6697da1db1d85e6afbdf79b50a2412d7546d5f239fe14fbaadeb445fc66a01b0779d98223961111e21766282f73dd96b6f",
+		},
+		{
+			"203d494428b8399352665ddca42f9de8fef600908e0d461cb021f8c538345dd77c3e4806e25f46d3315c44e0a5b4371282dd2c8d5be3095f",
+			"0fbcc2f993cd56d3305b0b7d9e55d4c1a8fb5dbb52f8e9a1e9b6201b165d015894e56c4d3570bee52fe205e28a78b91cdfbde71ce8d157db",
+			"884a02576239ff7a2f2f63b2db6a9ff37047ac13568e1e30fe63c4a7ad1b3ee3a5700df34321d62077e63633c575c1c954514e99da7c179d",
+		},
+	}
+
+	for _, tc := range testcases {
+		out, err := X448(decodeHex(t, tc.scalar), decodeHex(t, tc.u))
+		require.NoError(t, err)
+		require.Equal(t, decodeHex(t, tc.out), out)
+	}
+}
+
+func TestX448Iterated(t *testing.T) {
+	// RFC 7748 section 5.2: k and u start as the base point, then k becomes
+	// the result and u the former k at each iteration
+	k := append([]byte(nil), Basepoint...)
+	u := append([]byte(nil), Basepoint...)
+	iterate := func(n int) {
+		for i := 0; i < n; i++ {
+			out, err := X448(k, u)
+			require.NoError(t, err)
+			k, u = out, k
+		}
+	}
+
+	iterate(1)
+	require.Equal(t, decodeHex(t, "3f482c8a9f19b01e6c46ee9711d9dc14fd4bf67af30765c2ae2b846a4d23a8cd"+
+		"0db897086239492caf350b51f833868b9bc2b3bca9cf4113"), k)
+	if testing.Short() {
+		return
+	}
+	iterate(999)
+	require.Equal(t, decodeHex(t, "aa3b4749d55b9daf1e5b00288826c467274ce3ebbdd5c17b975e09d4af6c67cf"+
+		"10d087202db88286e2b79fceea3ec353ef54faa26e219f38"), k)
+}
+
+func TestX448InvalidInput(t *testing.T) {
+	scalar := make([]byte, ScalarSize)
+	scalar[0] = 1
+
+	for _, length := range []int{0, 32, 55, 57} {
+		_, err := X448(make([]byte, length), Basepoint)
+		require.Error(t, err, "scalar length %d", length)
+		_, err = X448(scalar, make([]byte, length))
+		require.Error(t, err, "point length %d", length)
+	}
+
+	// u = 0 is a point of small order, giving an all-zero output
+	_, err := X448(scalar, make([]byte, PointSize))
+	require.Error(t, err)
+}
+
+func TestX448NonCanonicalPoint(t *testing.T) {
+	scalar := decodeHex(t, "9a8f4925d1519f5775cf46b04b5800d4ee9ee8bae8bc5565d498c28dd9c9baf5"+
+		"74a9419744897391006382a6f127ab1d9ac2d8c0a598726b")
+	expected, err := X448(scalar, Basepoint)
+	require.NoError(t, err)
+
+	// u = p + 5 = 2^448 - 2^224 + 4 must be taken as u = 5, RFC 7748
+	// section 5
+	u := make([]byte, PointSize)
+	u[0] = 4
+	for i := 28; i < PointSize; i++ {
+		u[i] = 0xff
+	}
+	out, err := X448(scalar, u)
+	require.NoError(t, err)
+	require.Equal(t, expected, out)
+
+	// u = p is u = 0, a point of small order
+	for i := 0; i < 28; i++ {
+		u[i] = 0xff
+	}
+	u[28] = 0xfe
+	_, err = X448(scalar, u)
+	require.Error(t, err)
+}
@@ -0,0 +1,220 @@
+// Package camellia implements the Camellia block cipher, as defined in
+// RFC 3713, with 128, 192 and 256 bits keys.
+package camellia
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+// The Camellia block size in bytes
+const BlockSize = 16
+
+// Key schedule constants, RFC 3713 section 2.2
+const (
+	sigma1 uint64 = 0xA09E667F3BCC908B
+	sigma2 uint64 = 0xB67AE8584CAA73B2
+	sigma3 uint64 = 0xC6EF372FE94F82BE
+	sigma4 uint64 = 0x54FF53A5F1D36F1C
+	sigma5 uint64 = 0x10E527FADE682D1D
+	sigma6 uint64 = 0xB05688C2B3E6C1FD
+)
+
+var sbox1 = [256]byte{
+	0x70, 0x82, 0x2c, 0xec, 0xb3, 0x27, 0xc0, 0xe5, 0xe4, 0x85, 0x57, 0x35, 0xea, 0x0c, 0xae, 0x41,
+	0x23, 0xef, 0x6b, 0x93, 0x45, 0x19, 0xa5, 0x21, 0xed, 0x0e, 0x4f, 0x4e, 0x1d, 0x65, 0x92, 0xbd,
+	0x86, 0xb8, 0xaf, 0x8f, 0x7c, 0xeb, 0x1f, 0xce, 0x3e, 0x30, 0xdc, 0x5f, 0x5e, 0xc5, 0x0b, 0x1a,
+	0xa6, 0xe1, 0x39, 0xca, 0xd5, 0x47, 0x5d, 0x3d, 0xd9, 0x01, 0x5a, 0xd6, 0x51, 0x56, 0x6c, 0x4d,
+	0x8b, 0x0d, 0x9a, 0x66, 0xfb, 0xcc, 0xb0, 0x2d, 0x74, 0x12, 0x2b, 0x20, 0xf0, 0xb1, 0x84, 0x99,
+	0xdf, 0x4c, 0xcb, 0xc2, 0x34, 0x7e, 0x76, 0x05, 0x6d, 0xb7, 0xa9, 0x31, 0xd1, 0x17, 0x04, 0xd7,
+	0x14, 0x58, 0x3a, 0x61, 0xde, 0x1b, 0x11, 0x1c, 0x32, 0x0f, 0x9c, 0x16, 0x53, 0x18, 0xf2, 0x22,
+	0xfe, 0x44, 0xcf, 0xb2, 0xc3, 0xb5, 0x7a, 0x91, 0x24, 0x08, 0xe8, 0xa8, 0x60, 0xfc, 0x69, 0x50,
+	0xaa, 0xd0, 0xa0, 0x7d, 0xa1, 0x89, 0x62, 0x97, 0x54, 0x5b, 0x1e, 0x95, 0xe0, 0xff, 0x64, 0xd2,
+	0x10, 0xc4, 0x00, 0x48, 0xa3, 0xf7, 0x75, 0xdb, 0x8a, 0x03, 0xe6, 0xda, 0x09, 0x3f, 0xdd, 0x94,
+	0x87, 0x5c, 0x83, 0x02, 0xcd, 0x4a, 0x90, 0x33, 0x73, 0x67, 0xf6, 0xf3, 0x9d, 0x7f, 0xbf, 0xe2,
+	0x52, 0x9b, 0xd8, 0x26, 0xc8, 0x37, 0xc6, 0x3b, 0x81, 0x96, 0x6f, 0x4b, 0x13, 0xbe, 0x63, 0x2e,
+	0xe9, 0x79, 0xa7, 0x8c, 0x9f, 0x6e, 0xbc, 0x8e, 0x29, 0xf5, 0xf9, 0xb6, 0x2f, 0xfd, 0xb4, 0x59,
+	0x78, 0x98, 0x06, 0x6a, 0xe7, 0x46, 0x71, 0xba, 0xd4, 0x25, 0xab, 0x42, 0x88, 0xa2, 0x8d, 0xfa,
+	0x72, 0x07, 0xb9, 0x55, 0xf8, 0xee, 0xac, 0x0a, 0x36, 0x49, 0x2a, 0x68, 0x3c, 0x38, 0xf1, 0xa4,
+	0x40, 0x28, 0xd3, 0x7b, 0xbb, 0xc9, 0x43, 0xc1, 0x15, 0xe3, 0xad, 0xf4, 0x77, 0xc7, 0x80, 0x9e,
+}
+
+// sbox2, sbox3 and sbox4 derive from sbox1, RFC 3713 section 2.4.4
+func sbox2(x byte) byte { return bits.RotateLeft8(sbox1[x], 1) }
+func sbox3(x byte) byte { return bits.RotateLeft8(sbox1[x], 7) }
+func sbox4(x byte) byte { return sbox1[bits.RotateLeft8(x, 1)] }
+
+type camelliaCipher struct {
+	// Subkeys in encryption order, and in decryption order
+	kw, dkw [4]uint64
+	k, dk   []uint64
+	ke, dke []uint64
+}
+
+// NewCipher creates and returns a new cipher.Block. The key argument should
+// be the Camellia key, either 16, 24, or 32 bytes to select Camellia-128,
+// Camellia-192, or Camellia-256.
+func NewCipher(key []byte) (cipher.Block, error) {
+	var kl, kr [2]uint64
+	switch len(key) {
+	case 16:
+		kl = [2]uint64{binary.BigEndian.Uint64(key), binary.BigEndian.Uint64(key[8:])}
+	case 24:
+		kl = [2]uint64{binary.BigEndian.Uint64(key), binary.BigEndian.Uint64(key[8:])}
+		kr[0] = binary.BigEndian.Uint64(key[16:])
+		kr[1] = ^kr[0]
+	case 32:
+		kl = [2]uint64{binary.BigEndian.Uint64(key), binary.BigEndian.Uint64(key[8:])}
+		kr = [2]uint64{binary.BigEndian.Uint64(key[16:]), binary.BigEndian.Uint64(key[24:])}
+	default:
+		return nil, errors.Errorf("camellia: invalid key size %d", len(key))
+	}
+
+	// KA and KB, RFC 3713 section 2.2
+	d1, d2 := kl[0]^kr[0], kl[1]^kr[1]
+	d2 ^= f(d1, sigma1)
+	d1 ^= f(d2, sigma2)
+	d1 ^= kl[0]
+	d2 ^= kl[1]
+	d2 ^= f(d1, sigma3)
+	d1 ^= f(d2, sigma4)
+	ka := [2]uint64{d1, d2}
+	d1, d2 = ka[0]^kr[0], ka[1]^kr[1]
+	d2 ^= f(d1, sigma5)
+	d1 ^= f(d2, sigma6)
+	kb := [2]uint64{d1, d2}
+
+	// Subkeys, RFC 3713 section 2.2
+	c := new(camelliaCipher)
+	if len(key) == 16 {
+		c.kw = [4]uint64{kl[0], kl[1], rotate(ka, 111)[0], rotate(ka, 111)[1]}
+		c.k = subkeys(rotate(ka, 0), rotate(kl, 15), rotate(ka, 15), rotate(kl, 45))
+		c.k = append(c.k, rotate(ka, 45)[0], rotate(kl, 60)[1])
+		c.k = append(c.k, subkeys(rotate(ka, 60), rotate(kl, 94), rotate(ka, 94), rotate(kl, 111))...)
+		c.ke = subkeys(rotate(ka, 30), rotate(kl, 77))
+	} else {
+		c.kw = [4]uint64{kl[0], kl[1], rotate(kb, 111)[0], rotate(kb, 111)[1]}
+		c.k = subkeys(
+			rotate(kb, 0), rotate(kr, 15), rotate(ka, 15), rotate(kb, 30),
+			rotate(kl, 45), rotate(ka, 45), rotate(kr, 60), rotate(kb, 60),
+			rotate(kl, 77), rotate(kr, 94), rotate(ka, 94), rotate(kl, 111),
+		)
+		c.ke = subkeys(rotate(kr, 30), rotate(kl, 60), rotate(ka, 77))
+	}
+
+	// Decryption uses the subkeys in reverse order, RFC 3713 section 2.3.3
+	c.dkw = [4]uint64{c.kw[2], c.kw[3], c.kw[0], c.kw[1]}
+	c.dk = reversed(c.k)
+	c.dke = reversed(c.ke)
+	return c, nil
+}
+
+func (c *camelliaCipher) BlockSize() int {
+	return BlockSize
+}
+
+func (c *camelliaCipher) Encrypt(dst, src []byte) {
+	crypt(dst, src, &c.kw, c.k, c.ke)
+}
+
+func (c *camelliaCipher) Decrypt(dst, src []byte) {
+	crypt(dst, src, &c.dkw, c.dk, c.dke)
+}
+
+// crypt runs the Camellia data randomizing part on one block, RFC 3713
+// section 2.3: 6 rounds between each FL/FLINV layer
+func crypt(dst, src []byte, kw *[4]uint64, k, ke []uint64) {
+	if len(src) < BlockSize {
+		panic("camellia: input not full block")
+	}
+	if len(dst) < BlockSize {
+		panic("camellia: output not full block")
+	}
+
+	d1 := binary.BigEndian.Uint64(src) ^ kw[0]
+	d2 := binary.BigEndian.Uint64(src[8:]) ^ kw[1]
+	for i := 0; i < len(k); i += 2 {
+		if i > 0 && i%6 == 0 {
+			d1 = fl(d1, ke[i/3-2])
+			d2 = flinv(d2, ke[i/3-1])
+		}
+		d2 ^= f(d1, k[i])
+		d1 ^= f(d2, k[i+1])
+	}
+	binary.BigEndian.PutUint64(dst, d2^kw[2])
+	binary.BigEndian.PutUint64(dst[8:], d1^kw[3])
+}
+
+// f is the F-function, RFC 3713 section 2.4.1
+func f(in, ke uint64) uint64 {
+	x := in ^ ke
+	t1 := sbox1[byte(x>>56)]
+	t2 := sbox2(byte(x >> 48))
+	t3 := sbox3(byte(x >> 40))
+	t4 := sbox4(byte(x >> 32))
+	t5 := sbox2(byte(x >> 24))
+	t6 := sbox3(byte(x >> 16))
+	t7 := sbox4(byte(x >> 8))
+	t8 := sbox1[byte(x)]
+
+	y1 := t1 ^ t3 ^ t4 ^ t6 ^ t7 ^ t8
+	y2 := t1 ^ t2 ^ t4 ^ t5 ^ t7 ^ t8
+	y3 := t1 ^ t2 ^ t3 ^ t5 ^ t6 ^ t8
+	y4 := t2 ^ t3 ^ t4 ^ t5 ^ t6 ^ t7
+	y5 := t1 ^ t2 ^ t6 ^ t7 ^ t8
+	y6 := t2 ^ t3 ^ t5 ^ t7 ^ t8
+	y7 := t3 ^ t4 ^ t5 ^ t6 ^ t8
+	y8 := t1 ^ t4 ^ t5 ^ t6 ^ t7
+	return uint64(y1)<<56 | uint64(y2)<<48 | uint64(y3)<<40 | uint64(y4)<<32 |
+		uint64(y5)<<24 | uint64(y6)<<16 | uint64(y7)<<8 | uint64(y8)
+}
+
+// fl is the FL-function, RFC 3713 section 2.4.2
+func fl(in, ke uint64) uint64 {
+	x1, x2 := uint32(in>>32), uint32(in)
+	k1, k2 := uint32(ke>>32), uint32(ke)
+	x2 ^= bits.RotateLeft32(x1&k1, 1)
+	x1 ^= x2 | k2
+	return uint64(x1)<<32 | uint64(x2)
+}
+
+// flinv is the FLINV-function, RFC 3713 section 2.4.3
+func flinv(in, ke uint64) uint64 {
+	y1, y2 := uint32(in>>32), uint32(in)
+	k1, k2 := uint32(ke>>32), uint32(ke)
+	y1 ^= y2 | k2
+	y2 ^= bits.RotateLeft32(y1&k1, 1)
+	return uint64(y1)<<32 | uint64(y2)
+}
+
+// rotate returns the 128 bits value x rotated left by n bits
+func rotate(x [2]uint64, n uint) [2]uint64 {
+	if n >= 64 {
+		x[0], x[1] = x[1], x[0]
+		n -= 64
+	}
+	if n == 0 {
+		return x
+	}
+	return [2]uint64{x[0]<<n | x[1]>>(64-n), x[1]<<n | x[0]>>(64-n)}
+}
+
+// subkeys splits each 128 bits value into its upper and lower halves
+func subkeys(values ...[2]uint64) []uint64 {
+	keys := make([]uint64, 0, 2*len(values))
+	for _, v := range values {
+		keys = append(keys, v[0], v[1])
+	}
+	return keys
+}
+
+func reversed(keys []uint64) []uint64 {
+	r := make([]uint64, len(keys))
+	for i, k := range keys {
+		r[len(keys)-1-i] = k
+	}
+	return r
+}
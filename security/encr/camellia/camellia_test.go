@@ -0,0 +1,48 @@
+package camellia
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCamelliaKnownAnswer(t *testing.T) {
+	// Test vectors of RFC 3713 appendix A
+	testcases := []struct {
+		key        string
+		cipherText string
+	}{
+		{"0123456789abcdeffedcba9876543210", "67673138549669730857065648eabe43"},
+		{"0123456789abcdeffedcba98765432100011223344556677", "b4993401b3e996f84ee5cee7d79b09b9"},
+		{"0123456789abcdeffedcba987654321000112233445566778899aabbccddeeff", "9acc237dff16d76c20ef7c919e3a7509"},
+	}
+	plainText, err := hex.DecodeString("0123456789abcdeffedcba9876543210")
+	require.NoError(t, err)
+
+	for _, tc := range testcases {
+		key, err := hex.DecodeString(tc.key)
+		require.NoError(t, err)
+		expected, err := hex.DecodeString(tc.cipherText)
+		require.NoError(t, err)
+
+		block, err := NewCipher(key)
+		require.NoError(t, err)
+		require.Equal(t, BlockSize, block.BlockSize())
+
+		cipherText := make([]byte, BlockSize)
+		block.Encrypt(cipherText, plainText)
+		require.Equal(t, expected, cipherText, "key length %d", len(key))
+
+		decrypted := make([]byte, BlockSize)
+		block.Decrypt(decrypted, cipherText)
+		require.Equal(t, plainText, decrypted, "key length %d", len(key))
+	}
+}
+
+func TestCamelliaInvalidKeySize(t *testing.T) {
+	for _, length := range []int{0, 8, 15, 17, 33} {
+		_, err := NewCipher(make([]byte, length))
+		require.Error(t, err, "key length %d", length)
+	}
+}
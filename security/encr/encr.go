@@ -1,12 +1,22 @@
 package encr
 
 import (
+	"encoding/binary"
+
 	"github.com/pkg/errors"
 
 	"github.com/nathaniel-bennett/ike/message"
 	ikeCrypto "github.com/nathaniel-bennett/ike/security/IKECrypto"
 )
 
+// ErrKeyLengthMismatch is returned by NewCrypto for a key whose length is not
+// the GetKeyLength of the cipher
+var ErrKeyLengthMismatch = errors.New("encryption key length mismatch")
+
+// ErrInvalidKeyLength is returned for a key length the cipher does not define,
+// such as a 160-bit AES key
+var ErrInvalidKeyLength = errors.New("invalid encryption key length")
+
 var encrString map[uint16]func(uint16, uint16, []byte) string
 
 var (
@@ -17,12 +27,20 @@ var (
 func init() {
 	// ENCR String
 	encrString = make(map[uint16]func(uint16, uint16, []byte) string)
+	encrString[message.ENCR_3DES] = toString_ENCR_3DES
 	encrString[message.ENCR_NULL] = toString_ENCR_NULL
 	encrString[message.ENCR_AES_CBC] = toString_ENCR_AES_CBC
+	encrString[message.ENCR_AES_CTR] = toString_ENCR_AES_CTR
+	encrString[message.ENCR_AES_GCM_8] = toString_ENCR_AES_GCM_8
+	encrString[message.ENCR_AES_GCM_12] = toString_ENCR_AES_GCM_12
+	encrString[message.ENCR_AES_GCM_16] = toString_ENCR_AES_GCM_16
+	encrString[message.ENCR_CAMELLIA_CBC] = toString_ENCR_CAMELLIA_CBC
+	encrString[message.ENCR_CHACHA20_POLY1305] = toString_ENCR_CHACHA20_POLY1305
 
 	// ENCR Types
 	encrTypes = make(map[string]ENCRType)
 
+	encrTypes[ENCR_3DES] = &Encr3DesCbc{}
 	encrTypes[ENCR_NULL] = &EncrNull{
 	}
 	encrTypes[ENCR_AES_CBC_128] = &EncrAesCbc{
@@ -34,10 +52,66 @@ func init() {
 	encrTypes[ENCR_AES_CBC_256] = &EncrAesCbc{
 		keyLength: 32,
 	}
+	encrTypes[ENCR_AES_CTR_128] = &EncrAesCtr{
+		keyLength: 16,
+	}
+	encrTypes[ENCR_AES_CTR_192] = &EncrAesCtr{
+		keyLength: 24,
+	}
+	encrTypes[ENCR_AES_CTR_256] = &EncrAesCtr{
+		keyLength: 32,
+	}
+	encrTypes[ENCR_AES_GCM_8_128] = &EncrAesGcm{
+		keyLength: 16,
+		icvLength: 8,
+	}
+	encrTypes[ENCR_AES_GCM_8_192] = &EncrAesGcm{
+		keyLength: 24,
+		icvLength: 8,
+	}
+	encrTypes[ENCR_AES_GCM_8_256] = &EncrAesGcm{
+		keyLength: 32,
+		icvLength: 8,
+	}
+	encrTypes[ENCR_AES_GCM_12_128] = &EncrAesGcm{
+		keyLength: 16,
+		icvLength: 12,
+	}
+	encrTypes[ENCR_AES_GCM_12_192] = &EncrAesGcm{
+		keyLength: 24,
+		icvLength: 12,
+	}
+	encrTypes[ENCR_AES_GCM_12_256] = &EncrAesGcm{
+		keyLength: 32,
+		icvLength: 12,
+	}
+	encrTypes[ENCR_AES_GCM_16_128] = &EncrAesGcm{
+		keyLength: 16,
+		icvLength: 16,
+	}
+	encrTypes[ENCR_AES_GCM_16_192] = &EncrAesGcm{
+		keyLength: 24,
+		icvLength: 16,
+	}
+	encrTypes[ENCR_AES_GCM_16_256] = &EncrAesGcm{
+		keyLength: 32,
+		icvLength: 16,
+	}
+	encrTypes[ENCR_CAMELLIA_CBC_128] = &EncrCamelliaCbc{
+		keyLength: 16,
+	}
+	encrTypes[ENCR_CAMELLIA_CBC_192] = &EncrCamelliaCbc{
+		keyLength: 24,
+	}
+	encrTypes[ENCR_CAMELLIA_CBC_256] = &EncrCamelliaCbc{
+		keyLength: 32,
+	}
+	encrTypes[ENCR_CHACHA20_POLY1305] = &EncrChaCha20Poly1305{}
 
 	// ENCR Kernel Types
 	encrKTypes = make(map[string]ENCRKType)
 
+	encrKTypes[ENCR_3DES] = &Encr3DesCbc{}
 	encrKTypes[ENCR_NULL] = &EncrNull{
 	}
 	encrKTypes[ENCR_AES_CBC_128] = &EncrAesCbc{
@@ -49,6 +123,77 @@ func init() {
 	encrKTypes[ENCR_AES_CBC_256] = &EncrAesCbc{
 		keyLength: 32,
 	}
+	encrKTypes[ENCR_AES_CTR_128] = &EncrAesCtr{
+		keyLength: 16,
+	}
+	encrKTypes[ENCR_AES_CTR_192] = &EncrAesCtr{
+		keyLength: 24,
+	}
+	encrKTypes[ENCR_AES_CTR_256] = &EncrAesCtr{
+		keyLength: 32,
+	}
+	encrKTypes[ENCR_AES_GCM_8_128] = &EncrAesGcm{
+		keyLength: 16,
+		icvLength: 8,
+	}
+	encrKTypes[ENCR_AES_GCM_8_192] = &EncrAesGcm{
+		keyLength: 24,
+		icvLength: 8,
+	}
+	encrKTypes[ENCR_AES_GCM_8_256] = &EncrAesGcm{
+		keyLength: 32,
+		icvLength: 8,
+	}
+	encrKTypes[ENCR_AES_GCM_12_128] = &EncrAesGcm{
+		keyLength: 16,
+		icvLength: 12,
+	}
+	encrKTypes[ENCR_AES_GCM_12_192] = &EncrAesGcm{
+		keyLength: 24,
+		icvLength: 12,
+	}
+	encrKTypes[ENCR_AES_GCM_12_256] = &EncrAesGcm{
+		keyLength: 32,
+		icvLength: 12,
+	}
+	encrKTypes[ENCR_AES_GCM_16_128] = &EncrAesGcm{
+		keyLength: 16,
+		icvLength: 16,
+	}
+	encrKTypes[ENCR_AES_GCM_16_192] = &EncrAesGcm{
+		keyLength: 24,
+		icvLength: 16,
+	}
+	encrKTypes[ENCR_AES_GCM_16_256] = &EncrAesGcm{
+		keyLength: 32,
+		icvLength: 16,
+	}
+	encrKTypes[ENCR_CAMELLIA_CBC_128] = &EncrCamelliaCbc{
+		keyLength: 16,
+	}
+	encrKTypes[ENCR_CAMELLIA_CBC_192] = &EncrCamelliaCbc{
+		keyLength: 24,
+	}
+	encrKTypes[ENCR_CAMELLIA_CBC_256] = &EncrCamelliaCbc{
+		keyLength: 32,
+	}
+	encrKTypes[ENCR_CHACHA20_POLY1305] = &EncrChaCha20Poly1305{}
+}
+
+// keyLengthAttribute returns the key length in bits carried by a Key Length
+// attribute, which is a 2 bytes value: any other length sent in the variable
+// length format is rejected
+func keyLengthAttribute(attrType uint16, intValue uint16, bytesValue []byte) (uint16, bool) {
+	if attrType != message.AttributeTypeKeyLength {
+		return 0, false
+	}
+	if bytesValue != nil {
+		if len(bytesValue) != 2 {
+			return 0, false
+		}
+		intValue = binary.BigEndian.Uint16(bytesValue)
+	}
+	return intValue, true
 }
 
 func StrToType(algo string) ENCRType {
@@ -133,15 +278,61 @@ func ToTransformChildSA(encrKType ENCRKType) (*message.Transform, error) {
 	return t, nil
 }
 
+// CipherMode is the mode of operation of an encryption algorithm
+type CipherMode uint8
+
+const (
+	CipherModeNull CipherMode = iota
+	CipherModeCBC
+	CipherModeCTR
+	CipherModeGCM
+	CipherModeCCM
+	CipherModeStream
+)
+
+func (m CipherMode) String() string {
+	switch m {
+	case CipherModeNull:
+		return "NULL"
+	case CipherModeCBC:
+		return "CBC"
+	case CipherModeCTR:
+		return "CTR"
+	case CipherModeGCM:
+		return "GCM"
+	case CipherModeCCM:
+		return "CCM"
+	case CipherModeStream:
+		return "Stream"
+	default:
+		return "Unknown"
+	}
+}
+
 type ENCRType interface {
 	TransformID() uint16
+	Name() string
 	getAttribute() (bool, uint16, uint16, []byte, error)
 	GetKeyLength() int
+	// Length of the IV sent ahead of the ciphertext, in bytes
+	GetIVLength() int
+	// Size the plaintext is padded to a multiple of, in bytes
+	GetBlockSize() int
+	// Length of the ICV of an AEAD cipher, 0 otherwise
+	GetICVLength() int
+	// Whether the cipher also provides integrity, in which case no
+	// integrity algorithm is negotiated
+	IsAEAD() bool
+	Mode() CipherMode
+	// NewCrypto returns an ikeCrypto.IKECryptoAEAD if IsAEAD is true
 	NewCrypto(key []byte) (ikeCrypto.IKECrypto, error)
 }
 
 type ENCRKType interface {
 	TransformID() uint16
+	Name() string
 	getAttribute() (bool, uint16, uint16, []byte, error)
 	GetKeyLength() int
+	IsAEAD() bool
+	Mode() CipherMode
 }
@@ -0,0 +1,84 @@
+package encr
+
+import (
+	"crypto/des" // #nosec G502
+
+	"github.com/pkg/errors"
+
+	"github.com/nathaniel-bennett/ike/message"
+	ikeCrypto "github.com/nathaniel-bennett/ike/security/IKECrypto"
+)
+
+const (
+	ENCR_3DES string = "ENCR_3DES"
+)
+
+// 3DES has a fixed key length, the Key Length attribute must not be sent,
+// RFC 7296 section 3.3.5
+func toString_ENCR_3DES(attrType uint16, intValue uint16, bytesValue []byte) string {
+	if attrType == message.AttributeTypeKeyLength {
+		return ""
+	}
+	return ENCR_3DES
+}
+
+var (
+	_ ENCRType  = &Encr3DesCbc{}
+	_ ENCRKType = &Encr3DesCbc{}
+)
+
+// Encr3DesCbc is triple DES in CBC mode with three independent keys, kept for
+// interoperability with peers offering nothing else. Its 8 bytes blocks make
+// it unfit for large volumes of data under one key.
+type Encr3DesCbc struct{}
+
+func (t *Encr3DesCbc) TransformID() uint16 {
+	return message.ENCR_3DES
+}
+
+func (t *Encr3DesCbc) Name() string {
+	return ENCR_3DES
+}
+
+func (t *Encr3DesCbc) getAttribute() (bool, uint16, uint16, []byte, error) {
+	return false, 0, 0, nil, nil
+}
+
+func (t *Encr3DesCbc) GetKeyLength() int {
+	return 24
+}
+
+func (t *Encr3DesCbc) GetIVLength() int {
+	return des.BlockSize
+}
+
+func (t *Encr3DesCbc) GetBlockSize() int {
+	return des.BlockSize
+}
+
+func (t *Encr3DesCbc) GetICVLength() int {
+	return 0
+}
+
+func (t *Encr3DesCbc) IsAEAD() bool {
+	return false
+}
+
+func (t *Encr3DesCbc) Mode() CipherMode {
+	return CipherModeCBC
+}
+
+func (t *Encr3DesCbc) NewCrypto(key []byte) (ikeCrypto.IKECrypto, error) {
+	var err error
+	encr := new(EncrAesCbcCrypto)
+	if len(key) != t.GetKeyLength() {
+		return nil, errors.Wrapf(ErrKeyLengthMismatch, "Encr3DesCbc: key length %d bytes, expected %d bytes",
+			len(key), t.GetKeyLength())
+	}
+
+	if encr.Block, err = des.NewTripleDESCipher(key); err != nil { // #nosec G405
+		return nil, errors.Wrapf(err, "Encr3DesCbc init: Error occur when create new cipher: ")
+	} else {
+		return encr, nil
+	}
+}
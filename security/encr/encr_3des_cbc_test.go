@@ -0,0 +1,93 @@
+package encr
+
+import (
+	"crypto/aes"
+	"crypto/des" // #nosec G502
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nathaniel-bennett/ike/message"
+)
+
+func TestEncr3DesCbcBlockSize(t *testing.T) {
+	tripleDES := StrToType(ENCR_3DES)
+	require.NotNil(t, tripleDES)
+	require.Equal(t, des.BlockSize, tripleDES.GetBlockSize())
+	require.Equal(t, des.BlockSize, tripleDES.GetIVLength())
+	require.Equal(t, aes.BlockSize, StrToType(ENCR_AES_CBC_128).GetBlockSize())
+
+	transform, err := ToTransform(tripleDES)
+	require.NoError(t, err)
+	require.False(t, transform.AttributePresent)
+	require.Equal(t, tripleDES, DecodeTransform(transform))
+	require.Equal(t, StrToKType(ENCR_3DES), DecodeTransformChildSA(transform))
+
+	// The key length attribute must not be sent
+	transform.AttributeType = message.AttributeTypeKeyLength
+	transform.AttributeValue = 192
+	require.Nil(t, DecodeTransform(transform))
+
+	_, err = tripleDES.NewCrypto(make([]byte, 16))
+	require.Error(t, err)
+}
+
+// TDEA example of NIST SP 800-67 revision 2 appendix B, in ECB mode with
+// three distinct keys
+const (
+	tdeaKey        = "0123456789abcdef" + "23456789abcdef01" + "456789abcdef0123"
+	tdeaPlainText  = "The qufck brown fox jump"
+	tdeaCipherText = "a826fd8ce53b855f" + "cce21c8112256fe6" + "68d5c05dd9b6b900"
+)
+
+func TestEncr3DesCbcKnownAnswer(t *testing.T) {
+	key, err := hex.DecodeString(tdeaKey)
+	require.NoError(t, err)
+	expected, err := hex.DecodeString(tdeaCipherText)
+	require.NoError(t, err)
+	crypto, err := StrToType(ENCR_3DES).NewCrypto(key)
+	require.NoError(t, err)
+	sk := crypto.(*EncrAesCbcCrypto)
+
+	// With a zero IV and every plaintext block XORed with the previous
+	// ciphertext block, CBC gives back the ECB ciphertext of the example.
+	// The last block is passed as the padding, so that no block is added.
+	plainText := []byte(tdeaPlainText)
+	for i := des.BlockSize; i < len(plainText); i++ {
+		plainText[i] ^= expected[i-des.BlockSize]
+	}
+	sk.Iv = make([]byte, des.BlockSize)
+	sk.Padding = plainText[2*des.BlockSize:]
+	cipherText, err := sk.Encrypt(plainText[:2*des.BlockSize])
+	require.NoError(t, err)
+	require.Equal(t, append(make([]byte, des.BlockSize), expected...), cipherText)
+}
+
+func TestEncr3DesCbcRoundTrip(t *testing.T) {
+	tripleDES := StrToType(ENCR_3DES)
+	key := []byte("0123456789abcdefghijklmn")
+	sk, err := tripleDES.NewCrypto(key)
+	require.NoError(t, err)
+	peer, err := tripleDES.NewCrypto(key)
+	require.NoError(t, err)
+
+	for _, length := range []int{0, 1, 7, 8, 20} {
+		plainText := make([]byte, length)
+		for i := range plainText {
+			plainText[i] = byte(i)
+		}
+
+		// The IV and the padded plaintext are aligned on 8 bytes blocks
+		cipherText, err := sk.Encrypt(plainText)
+		require.NoError(t, err)
+		require.Equal(t, des.BlockSize+(length/des.BlockSize+1)*des.BlockSize, len(cipherText), "length %d", length)
+
+		decrypted, err := peer.Decrypt(cipherText)
+		require.NoError(t, err)
+		require.Equal(t, plainText, decrypted)
+	}
+
+	_, err = peer.Decrypt(make([]byte, des.BlockSize+4))
+	require.Error(t, err)
+}
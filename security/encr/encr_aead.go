@@ -0,0 +1,123 @@
+package encr
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrAEADAuthFailed is returned when decrypting with an AEAD cipher if the
+	// ICV does not match, as opposed to malformed input, so that the caller can
+	// tell an integrity failure apart from a parsing error
+	ErrAEADAuthFailed = errors.New("AEAD authentication failed")
+	// ErrRekeyRequired is returned when encrypting once ExplicitIV has reached
+	// MaxEncryptions, or exhausted its IV counter: the key must not be used
+	// again, and the caller must rekey the SA
+	ErrRekeyRequired = errors.New("encryption limit reached, rekey required")
+)
+
+const (
+	// Salt taken from the end of the keymat of the AEAD ciphers, RFC 4106
+	// section 8.1 and RFC 7634 section 2
+	aeadSaltLength = 4
+	// Explicit IV sent ahead of the ciphertext of the counter mode ciphers,
+	// RFC 3686 section 3.1 and RFC 5282 section 3.1
+	explicitIVLength = 8
+	// Invocations of a key allowed by default before a rekey is required,
+	// NIST SP 800-38D section 8.3
+	DefaultMaxEncryptions uint64 = 1 << 32
+)
+
+// ExplicitIV generates the explicit IV of the counter mode ciphers, AES-CTR
+// and the AEAD ciphers, and counts the encryptions made with the key.
+//
+// The explicit IV is random by default. With CounterIV, it is an internal
+// 64-bit counter incremented by every encryption instead, which never repeats
+// and avoids reading the random source per message. Iv forces the explicit
+// IV and is only meant for tests, as reusing it breaks the cipher.
+//
+// Encryption returns ErrRekeyRequired once MaxEncryptions messages have been
+// encrypted with the key, DefaultMaxEncryptions if it is 0.
+//
+// The counters are updated atomically, so that a crypto object encrypting
+// from several goroutines never reuses an IV nor goes past MaxEncryptions.
+// The exported fields must be set before the first encryption.
+type ExplicitIV struct {
+	// Accessed atomically, first in the struct to be 64-bit aligned on
+	// 32-bit platforms
+	counter         uint64
+	encryptionCount uint64
+
+	Iv             []byte
+	CounterIV      bool
+	MaxEncryptions uint64
+}
+
+// EncryptionCount returns the number of messages encrypted with the key
+func (s *ExplicitIV) EncryptionCount() uint64 {
+	return atomic.LoadUint64(&s.encryptionCount)
+}
+
+// nextIV writes the explicit IV of the next message in iv, and counts the
+// encryption
+func (s *ExplicitIV) nextIV(iv []byte) error {
+	maxEncryptions := s.MaxEncryptions
+	if maxEncryptions == 0 {
+		maxEncryptions = DefaultMaxEncryptions
+	}
+	// The encryption is counted first, and uncounted if no IV is generated
+	if _, ok := incrementBelow(&s.encryptionCount, maxEncryptions); !ok {
+		return errors.Wrapf(ErrRekeyRequired, "%d encryptions", maxEncryptions)
+	}
+
+	switch {
+	case s.Iv != nil:
+		copy(iv, s.Iv)
+	case s.CounterIV:
+		counter, ok := incrementBelow(&s.counter, ^uint64(0))
+		if !ok {
+			atomic.AddUint64(&s.encryptionCount, ^uint64(0))
+			return errors.Wrapf(ErrRekeyRequired, "IV counter exhausted")
+		}
+		binary.BigEndian.PutUint64(iv, counter)
+	default:
+		if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+			atomic.AddUint64(&s.encryptionCount, ^uint64(0))
+			return errors.Errorf("Read random initialization vector failed")
+		}
+	}
+	return nil
+}
+
+// incrementBelow atomically increments the value at addr unless it has
+// reached limit, and returns the incremented value, which no other call gets
+func incrementBelow(addr *uint64, limit uint64) (uint64, bool) {
+	for {
+		value := atomic.LoadUint64(addr)
+		if value >= limit {
+			return 0, false
+		}
+		if atomic.CompareAndSwapUint64(addr, value, value+1) {
+			return value + 1, true
+		}
+	}
+}
+
+// aeadNonce returns the salt followed by the explicit IV
+func aeadNonce(salt, iv []byte) []byte {
+	nonce := make([]byte, 0, len(salt)+len(iv))
+	nonce = append(nonce, salt...)
+	return append(nonce, iv...)
+}
+
+// padAEAD appends the pad length byte to plainText: the AEAD ciphers need no
+// padding
+func padAEAD(plainText []byte) []byte {
+	padded := make([]byte, len(plainText)+1)
+	copy(padded, plainText)
+	return padded
+}
@@ -0,0 +1,45 @@
+package encr
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplicitIVMaxEncryptions(t *testing.T) {
+	s := &ExplicitIV{MaxEncryptions: 3}
+	iv := make([]byte, 8)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.nextIV(iv))
+	}
+	require.Equal(t, uint64(3), s.EncryptionCount())
+
+	err := s.nextIV(iv)
+	require.ErrorIs(t, err, ErrRekeyRequired)
+	require.Equal(t, uint64(3), s.EncryptionCount())
+}
+
+func TestExplicitIVCounterIV(t *testing.T) {
+	s := &ExplicitIV{CounterIV: true, MaxEncryptions: 100}
+	iv := make([]byte, 8)
+
+	seen := make(map[uint64]bool)
+	for i := uint64(1); i <= s.MaxEncryptions; i++ {
+		require.NoError(t, s.nextIV(iv))
+
+		counter := binary.BigEndian.Uint64(iv)
+		require.Equal(t, i, counter)
+		require.False(t, seen[counter])
+		seen[counter] = true
+	}
+
+	err := s.nextIV(iv)
+	require.ErrorIs(t, err, ErrRekeyRequired)
+
+	// The counter stops before wrapping around
+	s = &ExplicitIV{CounterIV: true, MaxEncryptions: ^uint64(0), counter: ^uint64(0)}
+	err = s.nextIV(iv)
+	require.ErrorIs(t, err, ErrRekeyRequired)
+}
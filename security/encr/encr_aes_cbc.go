@@ -4,7 +4,9 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"encoding/binary"
 	"io"
+	"sync/atomic"
 
 	"github.com/pkg/errors"
 
@@ -13,6 +15,8 @@ import (
 	"github.com/nathaniel-bennett/ike/security/lib"
 )
 
+var ErrInvalidPadding = errors.New("invalid padding")
+
 const (
 	ENCR_AES_CBC_128 string = "ENCR_AES_CBC_128"
 	ENCR_AES_CBC_192 string = "ENCR_AES_CBC_192"
@@ -20,22 +24,39 @@ const (
 )
 
 func toString_ENCR_AES_CBC(attrType uint16, intValue uint16, bytesValue []byte) string {
-	if attrType == message.AttributeTypeKeyLength {
-		switch intValue {
-		case 128:
-			return ENCR_AES_CBC_128
-		case 192:
-			return ENCR_AES_CBC_192
-		case 256:
-			return ENCR_AES_CBC_256
-		default:
-			return ""
-		}
-	} else {
+	switch keyLength, _ := keyLengthAttribute(attrType, intValue, bytesValue); keyLength {
+	case 128:
+		return ENCR_AES_CBC_128
+	case 192:
+		return ENCR_AES_CBC_192
+	case 256:
+		return ENCR_AES_CBC_256
+	default:
 		return ""
 	}
 }
 
+// validAesKeyLength reports whether keyLength, in bytes, is an AES key length
+func validAesKeyLength(keyLength int) bool {
+	return keyLength == 16 || keyLength == 24 || keyLength == 32
+}
+
+// checkAesCbcKeyLength returns ErrInvalidKeyLength if the Key Length attribute
+// of an ENCR_AES_CBC transform is missing, malformed, or not 128, 192 or 256
+// bits
+func checkAesCbcKeyLength(transform *message.Transform) error {
+	keyLength, ok := keyLengthAttribute(transform.AttributeType, transform.AttributeValue,
+		transform.VariableLengthAttributeValue)
+	if !ok {
+		return errors.Wrapf(ErrInvalidKeyLength, "ENCR_AES_CBC: missing or malformed key length attribute")
+	}
+	if keyLength%8 != 0 || !validAesKeyLength(int(keyLength/8)) {
+		return errors.Wrapf(ErrInvalidKeyLength, "ENCR_AES_CBC: key length %d bits, expected 128, 192 or 256 bits",
+			keyLength)
+	}
+	return nil
+}
+
 var (
 	_ ENCRType  = &EncrAesCbc{}
 	_ ENCRKType = &EncrAesCbc{}
@@ -49,6 +70,10 @@ func (t *EncrAesCbc) TransformID() uint16 {
 	return message.ENCR_AES_CBC
 }
 
+func (t *EncrAesCbc) Name() string {
+	return toString_ENCR_AES_CBC(message.AttributeTypeKeyLength, uint16(t.keyLength*8), nil)
+}
+
 func (t *EncrAesCbc) getAttribute() (bool, uint16, uint16, []byte, error) {
 	keyLengthBits := t.keyLength * 8
 	if keyLengthBits < 0 || keyLengthBits > 0xFFFF {
@@ -61,11 +86,36 @@ func (t *EncrAesCbc) GetKeyLength() int {
 	return t.keyLength
 }
 
+func (t *EncrAesCbc) GetIVLength() int {
+	return aes.BlockSize
+}
+
+func (t *EncrAesCbc) GetBlockSize() int {
+	return aes.BlockSize
+}
+
+func (t *EncrAesCbc) GetICVLength() int {
+	return 0
+}
+
+func (t *EncrAesCbc) IsAEAD() bool {
+	return false
+}
+
+func (t *EncrAesCbc) Mode() CipherMode {
+	return CipherModeCBC
+}
+
 func (t *EncrAesCbc) NewCrypto(key []byte) (ikeCrypto.IKECrypto, error) {
 	var err error
 	encr := new(EncrAesCbcCrypto)
+	if !validAesKeyLength(t.keyLength) {
+		return nil, errors.Wrapf(ErrInvalidKeyLength, "EncrAesCbc: key length %d bytes, expected 16, 24 or 32 bytes",
+			t.keyLength)
+	}
 	if len(key) != t.keyLength {
-		return nil, errors.Errorf("EncrAesCbc init error: Get unexpected key length")
+		return nil, errors.Wrapf(ErrKeyLengthMismatch, "EncrAesCbc: key length %d bytes, expected %d bytes",
+			len(key), t.keyLength)
 	}
 
 	if encr.Block, err = aes.NewCipher(key); err != nil {
@@ -77,18 +127,50 @@ func (t *EncrAesCbc) NewCrypto(key []byte) (ikeCrypto.IKECrypto, error) {
 
 var _ ikeCrypto.IKECrypto = &EncrAesCbcCrypto{}
 
+// EncrAesCbcCrypto holds the AES block cipher built once by NewCrypto and
+// reused by every Encrypt/Decrypt call, so the key is never re-expanded per
+// packet. Nothing else depends on AES: Encr3DesCbc and EncrCamelliaCbc use it
+// with their block ciphers, and the IV, padding and ciphertext follow their
+// block size.
+//
+// MaxPadding enables random length padding: Encrypt adds a random number of
+// extra padding blocks, keeping the padding within MaxPadding bytes, pad
+// length byte included (at most 256). The default 0 keeps minimal padding.
+//
+// Decrypt only trusts the pad length byte by default, as RFC 7296 section 3.14
+// requires. StrictPadding also requires the padding bytes to be 1, 2, 3, ...
+// as in ESP (RFC 4303), and Decrypt returns ErrInvalidPadding otherwise.
+//
+// The IV is random by default. EncryptedCounterIV derives it instead by
+// encrypting a per-message counter with the key, which keeps it unpredictable
+// to anyone without the key, as RFC 7296 section 3.14 requires, without
+// reading the random source per message. The counter is updated atomically,
+// so that encrypting from several goroutines never reuses an IV.
 type EncrAesCbcCrypto struct {
-	Block   cipher.Block
-	Iv      []byte // initializationVector
-	Padding []byte
+	// Accessed atomically, first in the struct to be 64-bit aligned on
+	// 32-bit platforms
+	counter uint64
+
+	Block              cipher.Block
+	Iv                 []byte // initializationVector
+	Padding            []byte
+	MaxPadding         int
+	StrictPadding      bool
+	EncryptedCounterIV bool
 }
 
 func (encr *EncrAesCbcCrypto) Encrypt(plainText []byte) ([]byte, error) {
 	var err error
+	blockSize := encr.Block.BlockSize()
 
 	// Padding message
-	if encr.Padding == nil {
-		plainText, err = lib.PKCS7Padding(plainText, aes.BlockSize)
+	if encr.Padding == nil && encr.MaxPadding > 0 {
+		plainText, err = lib.RandomLengthPadding(plainText, blockSize, encr.MaxPadding)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Encr Encrypt()")
+		}
+	} else if encr.Padding == nil {
+		plainText, err = lib.PKCS7Padding(plainText, blockSize)
 		if err != nil {
 			return nil, errors.Wrapf(err, "Encr Encrypt()")
 		}
@@ -97,43 +179,51 @@ func (encr *EncrAesCbcCrypto) Encrypt(plainText []byte) ([]byte, error) {
 	}
 
 	// Slice
-	cipherText := make([]byte, aes.BlockSize+len(plainText))
+	cipherText := make([]byte, blockSize+len(plainText))
 	var initializationVector []byte
-	if encr.Iv == nil {
-		initializationVector = cipherText[:aes.BlockSize]
+	if encr.Iv == nil && encr.EncryptedCounterIV {
+		initializationVector = cipherText[:blockSize]
+		// IV = E(K, counter)
+		counter := atomic.AddUint64(&encr.counter, 1)
+		binary.BigEndian.PutUint64(initializationVector[blockSize-8:], counter)
+		encr.Block.Encrypt(initializationVector, initializationVector)
+	} else if encr.Iv == nil {
+		initializationVector = cipherText[:blockSize]
 		// IV
 		_, err = io.ReadFull(rand.Reader, initializationVector)
 		if err != nil {
 			return nil, errors.Errorf("Read random initialization vector failed")
 		}
 	} else {
-		copy(cipherText[:aes.BlockSize], encr.Iv)
+		copy(cipherText[:blockSize], encr.Iv)
 		initializationVector = encr.Iv
 	}
 
 	// Encryption
 	cbcBlockMode := cipher.NewCBCEncrypter(encr.Block, initializationVector) // #nosec G407
-	cbcBlockMode.CryptBlocks(cipherText[aes.BlockSize:], plainText)
+	cbcBlockMode.CryptBlocks(cipherText[blockSize:], plainText)
 
 	return cipherText, nil
 }
 
 func (encr *EncrAesCbcCrypto) Decrypt(cipherText []byte) ([]byte, error) {
+	blockSize := encr.Block.BlockSize()
+
 	// Check
-	if len(cipherText) < aes.BlockSize {
+	if len(cipherText) < blockSize {
 		return nil, errors.Errorf("EncrAesCbcCrypto: Length of cipher text is too short to decrypt")
 	}
 
 	var initializationVector []byte
 	if encr.Iv == nil {
-		initializationVector = cipherText[:aes.BlockSize]
+		initializationVector = cipherText[:blockSize]
 	} else {
 		initializationVector = encr.Iv
 	}
 
-	encryptedMessage := cipherText[aes.BlockSize:]
+	encryptedMessage := cipherText[blockSize:]
 
-	if len(encryptedMessage)%aes.BlockSize != 0 {
+	if len(encryptedMessage) == 0 || len(encryptedMessage)%blockSize != 0 {
 		return nil, errors.Errorf("EncrAesCbcCrypto: Cipher text is not a multiple of block size")
 	}
 
@@ -146,10 +236,35 @@ func (encr *EncrAesCbcCrypto) Decrypt(cipherText []byte) ([]byte, error) {
 
 	// fmt.Printf("Decrypted content:\n%s", hex.Dump(plainText))
 	// Remove padding
-	padding := int(plainText[len(plainText)-1]) + 1
-	plainText = plainText[:len(plainText)-padding]
+	plainText, err := removePadding(plainText, encr.StrictPadding)
+	if err != nil {
+		return nil, errors.Wrapf(err, "EncrAesCbcCrypto")
+	}
 
 	// fmt.Printf("Decrypted content with out padding:\n%s", hex.Dump(plainText))
 
 	return plainText, nil
 }
+
+// removePadding strips the padding and pad length byte ending plainText. In
+// strict mode, the padding bytes must be 1, 2, 3, ...
+func removePadding(plainText []byte, strict bool) ([]byte, error) {
+	if len(plainText) == 0 {
+		return nil, errors.Wrapf(ErrInvalidPadding, "no pad length")
+	}
+	padLength := int(plainText[len(plainText)-1])
+	if padLength+1 > len(plainText) {
+		return nil, errors.Wrapf(ErrInvalidPadding, "pad length %d exceeds plain text length %d",
+			padLength, len(plainText)-1)
+	}
+
+	padding := plainText[len(plainText)-1-padLength : len(plainText)-1]
+	if strict {
+		for i, b := range padding {
+			if int(b) != i+1 {
+				return nil, errors.Wrapf(ErrInvalidPadding, "padding byte %d is %d", i, b)
+			}
+		}
+	}
+	return plainText[:len(plainText)-1-padLength], nil
+}
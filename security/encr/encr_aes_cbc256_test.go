@@ -3,6 +3,7 @@ package encr
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -171,3 +172,193 @@ func TestDecrypt_256(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, plainText_256, plain)
 }
+
+func TestEncryptRandomLengthPadding_256(t *testing.T) {
+	block, err := aes.NewCipher(sk_ei_256)
+	require.NoError(t, err)
+
+	sk := EncrAesCbcCrypto{
+		Block:      block,
+		MaxPadding: 256,
+	}
+
+	lengths := make(map[int]bool)
+	for i := 0; i < 64; i++ {
+		cipherText, err := sk.Encrypt(append([]byte(nil), plainText_256...))
+		require.NoError(t, err)
+		require.Zero(t, len(cipherText)%aes.BlockSize)
+		padding := len(cipherText) - aes.BlockSize - len(plainText_256)
+		require.LessOrEqual(t, padding, 256)
+		lengths[len(cipherText)] = true
+
+		plain, err := sk.Decrypt(cipherText)
+		require.NoError(t, err)
+		require.Equal(t, plainText_256, plain)
+	}
+	require.Greater(t, len(lengths), 1)
+
+	// No room for an extra block keeps the minimal padding
+	sk.MaxPadding = aes.BlockSize
+	cipherText, err := sk.Encrypt(append([]byte(nil), plainText_256...))
+	require.NoError(t, err)
+	require.Len(t, cipherText, len(cipherText_256))
+}
+
+func TestEncryptEncryptedCounterIV_256(t *testing.T) {
+	block, err := aes.NewCipher(sk_ei_256)
+	require.NoError(t, err)
+
+	sk := EncrAesCbcCrypto{
+		Block:              block,
+		EncryptedCounterIV: true,
+	}
+
+	ivs := make(map[string]bool)
+	for i := 1; i <= 64; i++ {
+		cipherText, err := sk.Encrypt(append([]byte(nil), plainText_256...))
+		require.NoError(t, err)
+
+		iv := cipherText[:aes.BlockSize]
+		require.False(t, ivs[string(iv)])
+		ivs[string(iv)] = true
+
+		// The IV is the encrypted message counter
+		counter := make([]byte, aes.BlockSize)
+		block.Decrypt(counter, iv)
+		expected := make([]byte, aes.BlockSize)
+		expected[aes.BlockSize-1] = byte(i)
+		require.Equal(t, expected, counter)
+
+		plain, err := sk.Decrypt(cipherText)
+		require.NoError(t, err)
+		require.Equal(t, plainText_256, plain)
+	}
+}
+
+func TestEncryptEncryptedCounterIVConcurrent_256(t *testing.T) {
+	block, err := aes.NewCipher(sk_ei_256)
+	require.NoError(t, err)
+	sk := &EncrAesCbcCrypto{
+		Block:              block,
+		EncryptedCounterIV: true,
+	}
+
+	const goroutines, encryptions = 8, 100
+	ivs := make(chan string, goroutines*encryptions)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < encryptions; i++ {
+				cipherText, err := sk.Encrypt([]byte("IKE payloads"))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				ivs <- string(cipherText[:aes.BlockSize])
+			}
+		}()
+	}
+	wg.Wait()
+	close(ivs)
+
+	// Every message gets its own counter, so its own IV
+	unique := make(map[string]bool)
+	for iv := range ivs {
+		require.False(t, unique[iv])
+		unique[iv] = true
+	}
+	require.Len(t, unique, goroutines*encryptions)
+}
+
+func TestDecryptPaddingModes_256(t *testing.T) {
+	block, err := aes.NewCipher(sk_ei_256)
+	require.NoError(t, err)
+	testcases := []struct {
+		name        string
+		padding     []byte
+		strictValid bool
+	}{
+		{"incrementing padding", []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x07}, true},
+		{"random padding", []byte{0xd3, 0xae, 0xca, 0x6b, 0x2f, 0x91, 0x5f, 0x07}, false},
+		{"no padding", []byte{0x00}, true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			sk := EncrAesCbcCrypto{
+				Block:   block,
+				Padding: tc.padding,
+			}
+			input := append([]byte(nil), plainText_256[:2*aes.BlockSize-len(tc.padding)]...)
+			cipherText, err := sk.Encrypt(input)
+			require.NoError(t, err)
+
+			// Lenient mode only trusts the pad length byte
+			plain, err := sk.Decrypt(cipherText)
+			require.NoError(t, err)
+			require.Equal(t, input, plain)
+
+			sk.StrictPadding = true
+			plain, err = sk.Decrypt(cipherText)
+			if tc.strictValid {
+				require.NoError(t, err)
+				require.Equal(t, input, plain)
+			} else {
+				require.ErrorIs(t, err, ErrInvalidPadding)
+			}
+		})
+	}
+}
+
+func TestDecryptInvalidPadLength_256(t *testing.T) {
+	block, err := aes.NewCipher(sk_ei_256)
+	require.NoError(t, err)
+
+	// A pad length longer than the decrypted block
+	sk := EncrAesCbcCrypto{
+		Block:   block,
+		Padding: []byte{0x01, 0x02, 0xff},
+	}
+	cipherText, err := sk.Encrypt(make([]byte, 13))
+	require.NoError(t, err)
+	_, err = sk.Decrypt(cipherText)
+	require.ErrorIs(t, err, ErrInvalidPadding)
+
+	// IV without any encrypted block
+	_, err = sk.Decrypt(cipherText[:aes.BlockSize])
+	require.Error(t, err)
+}
+
+// The crypto object returned by NewCrypto keeps its cipher.Block, so an SA
+// encrypting many packets only expands the AES key schedule once.
+func BenchmarkEncrypt_256(b *testing.B) {
+	encrType := StrToType(ENCR_AES_CBC_256)
+	sk, err := encrType.NewCrypto(sk_ei_256)
+	require.NoError(b, err)
+
+	b.SetBytes(int64(len(plainText_256)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err = sk.Encrypt(plainText_256); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewCryptoEncrypt_256(b *testing.B) {
+	encrType := StrToType(ENCR_AES_CBC_256)
+
+	b.SetBytes(int64(len(plainText_256)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sk, err := encrType.NewCrypto(sk_ei_256)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err = sk.Encrypt(plainText_256); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package encr
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+
+	"github.com/nathaniel-bennett/ike/message"
+	ikeCrypto "github.com/nathaniel-bennett/ike/security/IKECrypto"
+)
+
+const (
+	ENCR_AES_CTR_128 string = "ENCR_AES_CTR_128"
+	ENCR_AES_CTR_192 string = "ENCR_AES_CTR_192"
+	ENCR_AES_CTR_256 string = "ENCR_AES_CTR_256"
+)
+
+// Nonce taken from the end of the keymat, RFC 3686 section 5.1
+const ctrNonceLength = 4
+
+func toString_ENCR_AES_CTR(attrType uint16, intValue uint16, bytesValue []byte) string {
+	switch keyLength, _ := keyLengthAttribute(attrType, intValue, bytesValue); keyLength {
+	case 128:
+		return ENCR_AES_CTR_128
+	case 192:
+		return ENCR_AES_CTR_192
+	case 256:
+		return ENCR_AES_CTR_256
+	default:
+		return ""
+	}
+}
+
+var (
+	_ ENCRType  = &EncrAesCtr{}
+	_ ENCRKType = &EncrAesCtr{}
+)
+
+// EncrAesCtr is AES-CTR, as defined in RFC 5930 for IKEv2 and RFC 3686 for
+// ESP. Its keymat is the AES key followed by the 4 bytes nonce. CTR provides
+// no integrity, so it is negotiated along with an integrity algorithm.
+type EncrAesCtr struct {
+	keyLength int
+}
+
+func (t *EncrAesCtr) TransformID() uint16 {
+	return message.ENCR_AES_CTR
+}
+
+func (t *EncrAesCtr) Name() string {
+	return toString_ENCR_AES_CTR(message.AttributeTypeKeyLength, uint16(t.keyLength*8), nil)
+}
+
+func (t *EncrAesCtr) getAttribute() (bool, uint16, uint16, []byte, error) {
+	keyLengthBits := t.keyLength * 8
+	if keyLengthBits < 0 || keyLengthBits > 0xFFFF {
+		return false, 0, 0, nil, errors.Errorf("key length exceeds uint16 maximum value: %v", keyLengthBits)
+	}
+	return true, message.AttributeTypeKeyLength, uint16(keyLengthBits), nil, nil
+}
+
+func (t *EncrAesCtr) GetKeyLength() int {
+	return t.keyLength + ctrNonceLength
+}
+
+func (t *EncrAesCtr) GetIVLength() int {
+	return explicitIVLength
+}
+
+// CTR has no alignment requirement, only the pad length byte is added
+func (t *EncrAesCtr) GetBlockSize() int {
+	return 1
+}
+
+func (t *EncrAesCtr) GetICVLength() int {
+	return 0
+}
+
+func (t *EncrAesCtr) IsAEAD() bool {
+	return false
+}
+
+func (t *EncrAesCtr) Mode() CipherMode {
+	return CipherModeCTR
+}
+
+func (t *EncrAesCtr) NewCrypto(key []byte) (ikeCrypto.IKECrypto, error) {
+	if len(key) != t.GetKeyLength() {
+		return nil, errors.Wrapf(ErrKeyLengthMismatch, "EncrAesCtr: key length %d bytes, expected %d bytes",
+			len(key), t.GetKeyLength())
+	}
+
+	block, err := aes.NewCipher(key[:t.keyLength])
+	if err != nil {
+		return nil, errors.Wrapf(err, "EncrAesCtr init: Error occur when create new cipher: ")
+	}
+
+	return &EncrAesCtrCrypto{
+		Block: block,
+		nonce: append([]byte(nil), key[t.keyLength:]...),
+	}, nil
+}
+
+var _ ikeCrypto.IKECrypto = &EncrAesCtrCrypto{}
+
+// EncrAesCtrCrypto encrypts an Encrypted payload with AES-CTR. Its output is
+// the 8 bytes explicit IV, generated by ExplicitIV, followed by the
+// ciphertext. The counter block of each message is the nonce, the explicit
+// IV, then a 32 bits block counter starting at 1, RFC 3686 section 4.
+type EncrAesCtrCrypto struct {
+	ExplicitIV
+	Block cipher.Block
+
+	nonce []byte
+}
+
+func (encr *EncrAesCtrCrypto) Encrypt(plainText []byte) ([]byte, error) {
+	cipherText := make([]byte, explicitIVLength+len(plainText)+1)
+	if err := encr.nextIV(cipherText[:explicitIVLength]); err != nil {
+		return nil, errors.Wrapf(err, "EncrAesCtrCrypto")
+	}
+
+	// The zero pad length byte is the last byte of the plaintext
+	copy(cipherText[explicitIVLength:], plainText)
+	encr.stream(cipherText[:explicitIVLength]).XORKeyStream(cipherText[explicitIVLength:],
+		cipherText[explicitIVLength:])
+	return cipherText, nil
+}
+
+func (encr *EncrAesCtrCrypto) Decrypt(cipherText []byte) ([]byte, error) {
+	if len(cipherText) < explicitIVLength+1 {
+		return nil, errors.Errorf("EncrAesCtrCrypto: Length of cipher text is too short to decrypt")
+	}
+
+	plainText := make([]byte, len(cipherText)-explicitIVLength)
+	encr.stream(cipherText[:explicitIVLength]).XORKeyStream(plainText, cipherText[explicitIVLength:])
+
+	plainText, err := removePadding(plainText, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "EncrAesCtrCrypto")
+	}
+	return plainText, nil
+}
+
+// stream returns the key stream of the message with the explicit IV iv
+func (encr *EncrAesCtrCrypto) stream(iv []byte) cipher.Stream {
+	counterBlock := make([]byte, aes.BlockSize)
+	copy(counterBlock, encr.nonce)
+	copy(counterBlock[ctrNonceLength:], iv)
+	binary.BigEndian.PutUint32(counterBlock[ctrNonceLength+explicitIVLength:], 1)
+	return cipher.NewCTR(encr.Block, counterBlock)
+}
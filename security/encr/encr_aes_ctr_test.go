@@ -0,0 +1,78 @@
+package encr
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAesCtrCrypto(t *testing.T, name string, key []byte) *EncrAesCtrCrypto {
+	encrType := StrToType(name)
+	require.NotNil(t, encrType, name)
+	crypto, err := encrType.NewCrypto(key)
+	require.NoError(t, err)
+	return crypto.(*EncrAesCtrCrypto)
+}
+
+func TestAesCtrKnownAnswer(t *testing.T) {
+	// Test vector #2 of RFC 3686 section 6: the keymat is the AES key
+	// followed by the nonce
+	key, err := hex.DecodeString("7e24067817fae0d743d6ce1f32539163" + "006cb6db")
+	require.NoError(t, err)
+	iv, err := hex.DecodeString("c0543b59da48d90b")
+	require.NoError(t, err)
+	plainText, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	require.NoError(t, err)
+	expected, err := hex.DecodeString("5104a106168a72d9790d41ee8edad388eb2e1efc46da57c8fce630df9141be28")
+	require.NoError(t, err)
+
+	sk := newTestAesCtrCrypto(t, ENCR_AES_CTR_128, key)
+	sk.Iv = iv
+	cipherText, err := sk.Encrypt(plainText)
+	require.NoError(t, err)
+	require.Equal(t, iv, cipherText[:explicitIVLength])
+	require.Equal(t, expected, cipherText[explicitIVLength:len(cipherText)-1])
+	require.Len(t, cipherText, explicitIVLength+len(plainText)+1)
+
+	decrypted, err := sk.Decrypt(cipherText)
+	require.NoError(t, err)
+	require.Equal(t, plainText, decrypted)
+}
+
+func TestAesCtrPerMessageIV(t *testing.T) {
+	key := make([]byte, 32+ctrNonceLength)
+	sk := newTestAesCtrCrypto(t, ENCR_AES_CTR_256, key)
+	peer := newTestAesCtrCrypto(t, ENCR_AES_CTR_256, key)
+	plainText := []byte("IKE_AUTH payloads")
+
+	// Random IVs differ between messages, and so do the key streams
+	first, err := sk.Encrypt(plainText)
+	require.NoError(t, err)
+	second, err := sk.Encrypt(plainText)
+	require.NoError(t, err)
+	require.NotEqual(t, first[:explicitIVLength], second[:explicitIVLength])
+	require.NotEqual(t, first[explicitIVLength:], second[explicitIVLength:])
+
+	// The receiver takes the IV from the message
+	for _, cipherText := range [][]byte{first, second} {
+		decrypted, err := peer.Decrypt(cipherText)
+		require.NoError(t, err)
+		require.Equal(t, plainText, decrypted)
+	}
+
+	sk.CounterIV = true
+	for i := uint64(1); i <= 2; i++ {
+		cipherText, err := sk.Encrypt(plainText)
+		require.NoError(t, err)
+		require.Equal(t, i, binary.BigEndian.Uint64(cipherText[:explicitIVLength]))
+		decrypted, err := peer.Decrypt(cipherText)
+		require.NoError(t, err)
+		require.Equal(t, plainText, decrypted)
+	}
+	require.Equal(t, uint64(4), sk.EncryptionCount())
+
+	_, err = peer.Decrypt(first[:explicitIVLength])
+	require.Error(t, err)
+}
@@ -0,0 +1,241 @@
+package encr
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+
+	"github.com/nathaniel-bennett/ike/message"
+	ikeCrypto "github.com/nathaniel-bennett/ike/security/IKECrypto"
+)
+
+const (
+	ENCR_AES_GCM_8_128  string = "ENCR_AES_GCM_8_128"
+	ENCR_AES_GCM_8_192  string = "ENCR_AES_GCM_8_192"
+	ENCR_AES_GCM_8_256  string = "ENCR_AES_GCM_8_256"
+	ENCR_AES_GCM_12_128 string = "ENCR_AES_GCM_12_128"
+	ENCR_AES_GCM_12_192 string = "ENCR_AES_GCM_12_192"
+	ENCR_AES_GCM_12_256 string = "ENCR_AES_GCM_12_256"
+	ENCR_AES_GCM_16_128 string = "ENCR_AES_GCM_16_128"
+	ENCR_AES_GCM_16_192 string = "ENCR_AES_GCM_16_192"
+	ENCR_AES_GCM_16_256 string = "ENCR_AES_GCM_16_256"
+)
+
+func toString_ENCR_AES_GCM_8(attrType uint16, intValue uint16, bytesValue []byte) string {
+	switch keyLength, _ := keyLengthAttribute(attrType, intValue, bytesValue); keyLength {
+	case 128:
+		return ENCR_AES_GCM_8_128
+	case 192:
+		return ENCR_AES_GCM_8_192
+	case 256:
+		return ENCR_AES_GCM_8_256
+	default:
+		return ""
+	}
+}
+
+func toString_ENCR_AES_GCM_12(attrType uint16, intValue uint16, bytesValue []byte) string {
+	switch keyLength, _ := keyLengthAttribute(attrType, intValue, bytesValue); keyLength {
+	case 128:
+		return ENCR_AES_GCM_12_128
+	case 192:
+		return ENCR_AES_GCM_12_192
+	case 256:
+		return ENCR_AES_GCM_12_256
+	default:
+		return ""
+	}
+}
+
+func toString_ENCR_AES_GCM_16(attrType uint16, intValue uint16, bytesValue []byte) string {
+	switch keyLength, _ := keyLengthAttribute(attrType, intValue, bytesValue); keyLength {
+	case 128:
+		return ENCR_AES_GCM_16_128
+	case 192:
+		return ENCR_AES_GCM_16_192
+	case 256:
+		return ENCR_AES_GCM_16_256
+	default:
+		return ""
+	}
+}
+
+var (
+	_ ENCRType  = &EncrAesGcm{}
+	_ ENCRKType = &EncrAesGcm{}
+)
+
+// EncrAesGcm is AES-GCM with an ICV of icvLength bytes, as defined in
+// RFC 5282 for IKEv2 and RFC 4106 for ESP. Its keymat is the AES key followed
+// by the 4 bytes salt.
+type EncrAesGcm struct {
+	keyLength int
+	icvLength int
+}
+
+func (t *EncrAesGcm) TransformID() uint16 {
+	switch t.icvLength {
+	case 8:
+		return message.ENCR_AES_GCM_8
+	case 12:
+		return message.ENCR_AES_GCM_12
+	default:
+		return message.ENCR_AES_GCM_16
+	}
+}
+
+func (t *EncrAesGcm) Name() string {
+	toString := encrString[t.TransformID()]
+	return toString(message.AttributeTypeKeyLength, uint16(t.keyLength*8), nil)
+}
+
+func (t *EncrAesGcm) getAttribute() (bool, uint16, uint16, []byte, error) {
+	keyLengthBits := t.keyLength * 8
+	if keyLengthBits < 0 || keyLengthBits > 0xFFFF {
+		return false, 0, 0, nil, errors.Errorf("key length exceeds uint16 maximum value: %v", keyLengthBits)
+	}
+	return true, message.AttributeTypeKeyLength, uint16(keyLengthBits), nil, nil
+}
+
+func (t *EncrAesGcm) GetKeyLength() int {
+	return t.keyLength + aeadSaltLength
+}
+
+func (t *EncrAesGcm) GetIVLength() int {
+	return explicitIVLength
+}
+
+// GCM has no alignment requirement, only the pad length byte is added
+func (t *EncrAesGcm) GetBlockSize() int {
+	return 1
+}
+
+func (t *EncrAesGcm) GetICVLength() int {
+	return t.icvLength
+}
+
+func (t *EncrAesGcm) IsAEAD() bool {
+	return true
+}
+
+func (t *EncrAesGcm) Mode() CipherMode {
+	return CipherModeGCM
+}
+
+func (t *EncrAesGcm) NewCrypto(key []byte) (ikeCrypto.IKECrypto, error) {
+	if len(key) != t.GetKeyLength() {
+		return nil, errors.Wrapf(ErrKeyLengthMismatch, "EncrAesGcm: key length %d bytes, expected %d bytes",
+			len(key), t.GetKeyLength())
+	}
+
+	block, err := aes.NewCipher(key[:t.keyLength])
+	if err != nil {
+		return nil, errors.Wrapf(err, "EncrAesGcm init: Error occur when create new cipher: ")
+	}
+	// The standard library only supports tags of 12 to 16 bytes, shorter
+	// ones are truncated from a full tag
+	tagSize := t.icvLength
+	if tagSize < 12 {
+		tagSize = aes.BlockSize
+	}
+	aead, err := cipher.NewGCMWithTagSize(block, tagSize)
+	if err != nil {
+		return nil, errors.Wrapf(err, "EncrAesGcm init")
+	}
+
+	return &EncrAesGcmCrypto{
+		Block:     block,
+		aead:      aead,
+		salt:      append([]byte(nil), key[t.keyLength:]...),
+		icvLength: t.icvLength,
+	}, nil
+}
+
+var _ ikeCrypto.IKECryptoAEAD = &EncrAesGcmCrypto{}
+
+// EncrAesGcmCrypto encrypts and authenticates an Encrypted payload in one
+// pass. Its output is the 8 bytes explicit IV, generated by ExplicitIV, the
+// ciphertext, then the ICV, and the nonce is the salt followed by the
+// explicit IV.
+type EncrAesGcmCrypto struct {
+	ExplicitIV
+	Block cipher.Block
+
+	aead      cipher.AEAD
+	salt      []byte
+	icvLength int
+}
+
+func (encr *EncrAesGcmCrypto) Encrypt(plainText []byte) ([]byte, error) {
+	return encr.Seal(plainText, nil)
+}
+
+func (encr *EncrAesGcmCrypto) Decrypt(cipherText []byte) ([]byte, error) {
+	return encr.Open(cipherText, nil)
+}
+
+// Seal pads plainText with the pad length byte, and encrypts and
+// authenticates it along with the associated data aad
+func (encr *EncrAesGcmCrypto) Seal(plainText, aad []byte) ([]byte, error) {
+	cipherText := make([]byte, explicitIVLength, explicitIVLength+len(plainText)+1+aes.BlockSize)
+	if err := encr.nextIV(cipherText); err != nil {
+		return nil, errors.Wrapf(err, "EncrAesGcmCrypto")
+	}
+
+	cipherText = encr.aead.Seal(cipherText, aeadNonce(encr.salt, cipherText), padAEAD(plainText), aad)
+	return cipherText[:len(cipherText)-encr.aead.Overhead()+encr.icvLength], nil
+}
+
+// Open checks the ICV of cipherText along with the associated data aad, and
+// returns the decrypted plaintext without padding. A wrong ICV returns
+// ErrAEADAuthFailed.
+func (encr *EncrAesGcmCrypto) Open(cipherText, aad []byte) ([]byte, error) {
+	if len(cipherText) < explicitIVLength+encr.icvLength {
+		return nil, errors.Errorf("EncrAesGcmCrypto: Length of cipher text is too short to decrypt")
+	}
+
+	nonce := aeadNonce(encr.salt, cipherText[:explicitIVLength])
+	encrypted := cipherText[explicitIVLength:]
+
+	var plainText []byte
+	var err error
+	if encr.icvLength == encr.aead.Overhead() {
+		plainText, err = encr.aead.Open(nil, nonce, encrypted, aad)
+	} else {
+		plainText, err = encr.openTruncated(nonce, encrypted, aad)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(ErrAEADAuthFailed, "EncrAesGcmCrypto")
+	}
+
+	plainText, err = removePadding(plainText, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "EncrAesGcmCrypto")
+	}
+	return plainText, nil
+}
+
+// openTruncated opens a message whose ICV is a truncated full tag: the
+// ciphertext is decrypted with the GCM counter stream, then sealed again to
+// recompute the full tag, whose prefix is compared with the ICV
+func (encr *EncrAesGcmCrypto) openTruncated(nonce, encrypted, aad []byte) ([]byte, error) {
+	data := encrypted[:len(encrypted)-encr.icvLength]
+	icv := encrypted[len(encrypted)-encr.icvLength:]
+
+	// The first counter block is nonce | 2, the block nonce | 1 being used
+	// for the tag
+	counter := make([]byte, aes.BlockSize)
+	copy(counter, nonce)
+	binary.BigEndian.PutUint32(counter[len(nonce):], 2)
+	plainText := make([]byte, len(data))
+	cipher.NewCTR(encr.Block, counter).XORKeyStream(plainText, data)
+
+	sealed := encr.aead.Seal(nil, nonce, plainText, aad)
+	if subtle.ConstantTimeCompare(sealed[len(data):len(data)+encr.icvLength], icv) != 1 {
+		return nil, errors.New("message authentication failed")
+	}
+	return plainText, nil
+}
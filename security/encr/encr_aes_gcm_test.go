@@ -0,0 +1,192 @@
+package encr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAesGcmCrypto(t *testing.T, name string, key []byte) *EncrAesGcmCrypto {
+	encrType := StrToType(name)
+	require.NotNil(t, encrType, name)
+	crypto, err := encrType.NewCrypto(key)
+	require.NoError(t, err)
+	return crypto.(*EncrAesGcmCrypto)
+}
+
+func TestAesGcmKnownAnswer(t *testing.T) {
+	// Test cases 2 and 14 of "The Galois/Counter Mode of Operation (GCM)",
+	// with a zero salt and explicit IV. The plaintext is 15 zero bytes
+	// followed by the zero pad length byte.
+	testcases := []struct {
+		name       string
+		keyLength  int
+		cipherText string
+		tag        string
+	}{
+		{"AES-128", 16, "0388dace60b6a392f328c2b971b2fe78", "ab6e47d42cec13bdf53a67b21257bddf"},
+		{"AES-256", 32, "cea7403d4d606b6e074ec5d3baf39d18", "d0d1c8a799996bf0265b98b5d48ab919"},
+	}
+
+	for _, tc := range testcases {
+		for _, icvLength := range []int{8, 12, 16} {
+			name := map[int]map[int]string{
+				8:  {16: ENCR_AES_GCM_8_128, 32: ENCR_AES_GCM_8_256},
+				12: {16: ENCR_AES_GCM_12_128, 32: ENCR_AES_GCM_12_256},
+				16: {16: ENCR_AES_GCM_16_128, 32: ENCR_AES_GCM_16_256},
+			}[icvLength][tc.keyLength]
+
+			t.Run(name, func(t *testing.T) {
+				expected, err := hex.DecodeString(tc.cipherText + tc.tag[:2*icvLength])
+				require.NoError(t, err)
+				expected = append(make([]byte, explicitIVLength), expected...)
+
+				sk := newTestAesGcmCrypto(t, name, make([]byte, tc.keyLength+aeadSaltLength))
+				sk.Iv = make([]byte, explicitIVLength)
+				cipherText, err := sk.Encrypt(make([]byte, 15))
+				require.NoError(t, err)
+				require.Equal(t, expected, cipherText)
+
+				plainText, err := sk.Decrypt(cipherText)
+				require.NoError(t, err)
+				require.Equal(t, make([]byte, 15), plainText)
+			})
+		}
+	}
+}
+
+func TestAesGcmTamperDetection(t *testing.T) {
+	aad := []byte("IKE header and Encrypted payload header")
+	plainText := []byte("IKE payloads to protect")
+
+	for _, name := range []string{ENCR_AES_GCM_8_256, ENCR_AES_GCM_12_192, ENCR_AES_GCM_16_128} {
+		t.Run(name, func(t *testing.T) {
+			key := bytes.Repeat([]byte{0x5a}, StrToType(name).GetKeyLength())
+			sk := newTestAesGcmCrypto(t, name, key)
+			peer := newTestAesGcmCrypto(t, name, key)
+
+			cipherText, err := sk.Seal(plainText, aad)
+			require.NoError(t, err)
+			require.Len(t, cipherText, explicitIVLength+len(plainText)+1+StrToType(name).GetICVLength())
+
+			decrypted, err := peer.Open(cipherText, aad)
+			require.NoError(t, err)
+			require.Equal(t, plainText, decrypted)
+
+			for _, offset := range []int{0, explicitIVLength, len(cipherText) - 1} {
+				tampered := append([]byte(nil), cipherText...)
+				tampered[offset] ^= 0x01
+				_, err = peer.Open(tampered, aad)
+				require.ErrorIs(t, err, ErrAEADAuthFailed, "offset %d", offset)
+			}
+
+			_, err = peer.Open(cipherText, []byte("another header"))
+			require.ErrorIs(t, err, ErrAEADAuthFailed)
+
+			// A truncated ICV is malformed input, not an authentication failure
+			_, err = peer.Open(cipherText[:explicitIVLength+2], aad)
+			require.Error(t, err)
+			require.NotErrorIs(t, err, ErrAEADAuthFailed)
+		})
+	}
+}
+
+func TestAesGcmMaxEncryptions(t *testing.T) {
+	sk := newTestAesGcmCrypto(t, ENCR_AES_GCM_16_256, make([]byte, 36))
+	sk.MaxEncryptions = 3
+
+	for i := 0; i < 3; i++ {
+		_, err := sk.Encrypt([]byte{0x01})
+		require.NoError(t, err)
+	}
+	require.Equal(t, uint64(3), sk.EncryptionCount())
+
+	_, err := sk.Encrypt([]byte{0x01})
+	require.ErrorIs(t, err, ErrRekeyRequired)
+	require.Equal(t, uint64(3), sk.EncryptionCount())
+}
+
+func TestAesGcmCounterIV(t *testing.T) {
+	sk := newTestAesGcmCrypto(t, ENCR_AES_GCM_16_128, make([]byte, 20))
+	sk.CounterIV = true
+	sk.MaxEncryptions = 100
+
+	seen := make(map[uint64]bool)
+	for i := uint64(1); i <= sk.MaxEncryptions; i++ {
+		cipherText, err := sk.Encrypt([]byte{0x01, 0x02})
+		require.NoError(t, err)
+
+		iv := binary.BigEndian.Uint64(cipherText[:explicitIVLength])
+		require.Equal(t, i, iv)
+		require.False(t, seen[iv])
+		seen[iv] = true
+
+		plainText, err := sk.Decrypt(cipherText)
+		require.NoError(t, err)
+		require.Equal(t, []byte{0x01, 0x02}, plainText)
+	}
+
+	_, err := sk.Encrypt([]byte{0x01, 0x02})
+	require.ErrorIs(t, err, ErrRekeyRequired)
+}
+
+func TestAesGcmConcurrentCounterIV(t *testing.T) {
+	const goroutines, perGoroutine = 8, 100
+	sk := newTestAesGcmCrypto(t, ENCR_AES_GCM_16_128, make([]byte, 20))
+	sk.CounterIV = true
+	sk.MaxEncryptions = goroutines*perGoroutine - 10
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	seen := make(map[uint64]bool)
+	rekeyRequired := 0
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				cipherText, err := sk.Encrypt([]byte{0x01})
+				mu.Lock()
+				if err != nil {
+					rekeyRequired++
+				} else {
+					seen[binary.BigEndian.Uint64(cipherText[:explicitIVLength])] = true
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every encryption below the limit got its own IV
+	require.Len(t, seen, int(sk.MaxEncryptions))
+	require.Equal(t, 10, rekeyRequired)
+	require.Equal(t, sk.MaxEncryptions, sk.EncryptionCount())
+}
+
+func TestAesGcmICVLengthMismatch(t *testing.T) {
+	key := bytes.Repeat([]byte{0x5a}, 20)
+	sk := newTestAesGcmCrypto(t, ENCR_AES_GCM_16_128, key)
+	cipherText, err := sk.Seal([]byte("IKE payloads"), nil)
+	require.NoError(t, err)
+
+	// The trailing bytes of the negotiated ICV length are the ICV: the
+	// remaining bytes of a longer ICV are taken as ciphertext, and the
+	// check fails
+	for _, name := range []string{ENCR_AES_GCM_8_128, ENCR_AES_GCM_12_128} {
+		peer := newTestAesGcmCrypto(t, name, key)
+		_, err = peer.Open(cipherText, nil)
+		require.ErrorIs(t, err, ErrAEADAuthFailed, name)
+	}
+
+	// An ICV shorter than the negotiated one fails the same way
+	sk = newTestAesGcmCrypto(t, ENCR_AES_GCM_8_128, key)
+	cipherText, err = sk.Seal([]byte("IKE payloads"), nil)
+	require.NoError(t, err)
+	_, err = newTestAesGcmCrypto(t, ENCR_AES_GCM_16_128, key).Open(cipherText, nil)
+	require.ErrorIs(t, err, ErrAEADAuthFailed)
+}
@@ -0,0 +1,94 @@
+package encr
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/nathaniel-bennett/ike/message"
+	ikeCrypto "github.com/nathaniel-bennett/ike/security/IKECrypto"
+	"github.com/nathaniel-bennett/ike/security/encr/camellia"
+)
+
+const (
+	ENCR_CAMELLIA_CBC_128 string = "ENCR_CAMELLIA_CBC_128"
+	ENCR_CAMELLIA_CBC_192 string = "ENCR_CAMELLIA_CBC_192"
+	ENCR_CAMELLIA_CBC_256 string = "ENCR_CAMELLIA_CBC_256"
+)
+
+func toString_ENCR_CAMELLIA_CBC(attrType uint16, intValue uint16, bytesValue []byte) string {
+	switch keyLength, _ := keyLengthAttribute(attrType, intValue, bytesValue); keyLength {
+	case 128:
+		return ENCR_CAMELLIA_CBC_128
+	case 192:
+		return ENCR_CAMELLIA_CBC_192
+	case 256:
+		return ENCR_CAMELLIA_CBC_256
+	default:
+		return ""
+	}
+}
+
+var (
+	_ ENCRType  = &EncrCamelliaCbc{}
+	_ ENCRKType = &EncrCamelliaCbc{}
+)
+
+// EncrCamelliaCbc is Camellia in CBC mode, as defined in RFC 5529. It works
+// as EncrAesCbc, Camellia having the same 16 bytes block size.
+type EncrCamelliaCbc struct {
+	keyLength int
+}
+
+func (t *EncrCamelliaCbc) TransformID() uint16 {
+	return message.ENCR_CAMELLIA_CBC
+}
+
+func (t *EncrCamelliaCbc) Name() string {
+	return toString_ENCR_CAMELLIA_CBC(message.AttributeTypeKeyLength, uint16(t.keyLength*8), nil)
+}
+
+func (t *EncrCamelliaCbc) getAttribute() (bool, uint16, uint16, []byte, error) {
+	keyLengthBits := t.keyLength * 8
+	if keyLengthBits < 0 || keyLengthBits > 0xFFFF {
+		return false, 0, 0, nil, errors.Errorf("key length exceeds uint16 maximum value: %v", keyLengthBits)
+	}
+	return true, message.AttributeTypeKeyLength, uint16(keyLengthBits), nil, nil
+}
+
+func (t *EncrCamelliaCbc) GetKeyLength() int {
+	return t.keyLength
+}
+
+func (t *EncrCamelliaCbc) GetIVLength() int {
+	return camellia.BlockSize
+}
+
+func (t *EncrCamelliaCbc) GetBlockSize() int {
+	return camellia.BlockSize
+}
+
+func (t *EncrCamelliaCbc) GetICVLength() int {
+	return 0
+}
+
+func (t *EncrCamelliaCbc) IsAEAD() bool {
+	return false
+}
+
+func (t *EncrCamelliaCbc) Mode() CipherMode {
+	return CipherModeCBC
+}
+
+func (t *EncrCamelliaCbc) NewCrypto(key []byte) (ikeCrypto.IKECrypto, error) {
+	var err error
+	encr := new(EncrAesCbcCrypto)
+	if len(key) != t.keyLength {
+		return nil, errors.Wrapf(ErrKeyLengthMismatch, "EncrCamelliaCbc: key length %d bytes, expected %d bytes",
+			len(key), t.keyLength)
+	}
+
+	if encr.Block, err = camellia.NewCipher(key); err != nil {
+		return nil, errors.Wrapf(err, "EncrCamelliaCbc init: Error occur when create new cipher: ")
+	} else {
+		return encr, nil
+	}
+}
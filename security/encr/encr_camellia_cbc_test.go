@@ -0,0 +1,79 @@
+package encr
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nathaniel-bennett/ike/security/encr/camellia"
+)
+
+func TestEncrCamelliaCbcKnownAnswer(t *testing.T) {
+	// RFC 5529 has no test vectors of its own: with a zero IV, the first
+	// CBC block is the Camellia encryption of the RFC 3713 appendix A
+	// plaintext
+	testcases := []struct {
+		name       string
+		key        string
+		cipherText string
+	}{
+		{ENCR_CAMELLIA_CBC_128, "0123456789abcdeffedcba9876543210", "67673138549669730857065648eabe43"},
+		{
+			ENCR_CAMELLIA_CBC_192, "0123456789abcdeffedcba98765432100011223344556677",
+			"b4993401b3e996f84ee5cee7d79b09b9",
+		},
+		{
+			ENCR_CAMELLIA_CBC_256, "0123456789abcdeffedcba987654321000112233445566778899aabbccddeeff",
+			"9acc237dff16d76c20ef7c919e3a7509",
+		},
+	}
+	plainText, err := hex.DecodeString("0123456789abcdeffedcba9876543210")
+	require.NoError(t, err)
+
+	for _, tc := range testcases {
+		key, err := hex.DecodeString(tc.key)
+		require.NoError(t, err)
+		expected, err := hex.DecodeString(tc.cipherText)
+		require.NoError(t, err)
+
+		encrType := StrToType(tc.name)
+		require.NotNil(t, encrType, tc.name)
+		crypto, err := encrType.NewCrypto(key)
+		require.NoError(t, err)
+
+		sk := crypto.(*EncrAesCbcCrypto)
+		sk.Iv = make([]byte, camellia.BlockSize)
+		sk.Padding = []byte{}
+		cipherText, err := sk.Encrypt(plainText)
+		require.NoError(t, err)
+		require.Equal(t, append(make([]byte, camellia.BlockSize), expected...), cipherText, tc.name)
+	}
+}
+
+func TestEncrCamelliaCbcRoundTrip(t *testing.T) {
+	for _, name := range []string{ENCR_CAMELLIA_CBC_128, ENCR_CAMELLIA_CBC_192, ENCR_CAMELLIA_CBC_256} {
+		encrType := StrToType(name)
+		key := make([]byte, encrType.GetKeyLength())
+		sk, err := encrType.NewCrypto(key)
+		require.NoError(t, err)
+		peer, err := encrType.NewCrypto(key)
+		require.NoError(t, err)
+
+		// A random IV is prepended to the ciphertext
+		plainText := []byte("IKE_AUTH payloads")
+		first, err := sk.Encrypt(plainText)
+		require.NoError(t, err)
+		second, err := sk.Encrypt(plainText)
+		require.NoError(t, err)
+		require.Len(t, first, 3*camellia.BlockSize)
+		require.NotEqual(t, first[:camellia.BlockSize], second[:camellia.BlockSize])
+
+		decrypted, err := peer.Decrypt(first)
+		require.NoError(t, err)
+		require.Equal(t, plainText, decrypted)
+
+		_, err = encrType.NewCrypto(key[1:])
+		require.Error(t, err)
+	}
+}
@@ -0,0 +1,140 @@
+package encr
+
+import (
+	"crypto/cipher"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/nathaniel-bennett/ike/message"
+	ikeCrypto "github.com/nathaniel-bennett/ike/security/IKECrypto"
+)
+
+const (
+	ENCR_CHACHA20_POLY1305 string = "ENCR_CHACHA20_POLY1305"
+)
+
+// ChaCha20-Poly1305 has a fixed key length, the Key Length attribute must
+// not be sent, RFC 7634 section 3
+func toString_ENCR_CHACHA20_POLY1305(attrType uint16, intValue uint16, bytesValue []byte) string {
+	if attrType == message.AttributeTypeKeyLength {
+		return ""
+	}
+	return ENCR_CHACHA20_POLY1305
+}
+
+var (
+	_ ENCRType  = &EncrChaCha20Poly1305{}
+	_ ENCRKType = &EncrChaCha20Poly1305{}
+)
+
+// EncrChaCha20Poly1305 is ChaCha20-Poly1305, as defined in RFC 7634 for IKEv2
+// and ESP. Its keymat is the 32 bytes key followed by the 4 bytes salt.
+type EncrChaCha20Poly1305 struct{}
+
+func (t *EncrChaCha20Poly1305) TransformID() uint16 {
+	return message.ENCR_CHACHA20_POLY1305
+}
+
+func (t *EncrChaCha20Poly1305) Name() string {
+	return ENCR_CHACHA20_POLY1305
+}
+
+func (t *EncrChaCha20Poly1305) getAttribute() (bool, uint16, uint16, []byte, error) {
+	return false, 0, 0, nil, nil
+}
+
+func (t *EncrChaCha20Poly1305) GetKeyLength() int {
+	return chacha20poly1305.KeySize + aeadSaltLength
+}
+
+func (t *EncrChaCha20Poly1305) GetIVLength() int {
+	return explicitIVLength
+}
+
+// ChaCha20 is a stream cipher, only the pad length byte is added
+func (t *EncrChaCha20Poly1305) GetBlockSize() int {
+	return 1
+}
+
+func (t *EncrChaCha20Poly1305) GetICVLength() int {
+	return chacha20poly1305.Overhead
+}
+
+func (t *EncrChaCha20Poly1305) IsAEAD() bool {
+	return true
+}
+
+func (t *EncrChaCha20Poly1305) Mode() CipherMode {
+	return CipherModeStream
+}
+
+func (t *EncrChaCha20Poly1305) NewCrypto(key []byte) (ikeCrypto.IKECrypto, error) {
+	if len(key) != t.GetKeyLength() {
+		return nil, errors.Wrapf(ErrKeyLengthMismatch, "EncrChaCha20Poly1305: key length %d bytes, expected %d bytes",
+			len(key), t.GetKeyLength())
+	}
+
+	aead, err := chacha20poly1305.New(key[:chacha20poly1305.KeySize])
+	if err != nil {
+		return nil, errors.Wrapf(err, "EncrChaCha20Poly1305 init")
+	}
+
+	return &EncrChaCha20Poly1305Crypto{
+		aead: aead,
+		salt: append([]byte(nil), key[chacha20poly1305.KeySize:]...),
+	}, nil
+}
+
+var _ ikeCrypto.IKECryptoAEAD = &EncrChaCha20Poly1305Crypto{}
+
+// EncrChaCha20Poly1305Crypto encrypts and authenticates an Encrypted payload
+// in one pass. Its output is the 8 bytes explicit IV, generated by ExplicitIV,
+// the ciphertext, then the 16 bytes ICV, and the nonce is the salt followed
+// by the explicit IV.
+type EncrChaCha20Poly1305Crypto struct {
+	ExplicitIV
+
+	aead cipher.AEAD
+	salt []byte
+}
+
+func (encr *EncrChaCha20Poly1305Crypto) Encrypt(plainText []byte) ([]byte, error) {
+	return encr.Seal(plainText, nil)
+}
+
+func (encr *EncrChaCha20Poly1305Crypto) Decrypt(cipherText []byte) ([]byte, error) {
+	return encr.Open(cipherText, nil)
+}
+
+// Seal pads plainText with the pad length byte, and encrypts and
+// authenticates it along with the associated data aad
+func (encr *EncrChaCha20Poly1305Crypto) Seal(plainText, aad []byte) ([]byte, error) {
+	cipherText := make([]byte, explicitIVLength, explicitIVLength+len(plainText)+1+chacha20poly1305.Overhead)
+	if err := encr.nextIV(cipherText); err != nil {
+		return nil, errors.Wrapf(err, "EncrChaCha20Poly1305Crypto")
+	}
+
+	return encr.aead.Seal(cipherText, aeadNonce(encr.salt, cipherText), padAEAD(plainText), aad), nil
+}
+
+// Open checks the ICV of cipherText along with the associated data aad, and
+// returns the plaintext without its padding. A mismatching ICV returns
+// ErrAEADAuthFailed.
+func (encr *EncrChaCha20Poly1305Crypto) Open(cipherText, aad []byte) ([]byte, error) {
+	if len(cipherText) < explicitIVLength+1+chacha20poly1305.Overhead {
+		return nil, errors.Errorf("EncrChaCha20Poly1305Crypto: Length of cipher text is too short to decrypt")
+	}
+
+	nonce := aeadNonce(encr.salt, cipherText[:explicitIVLength])
+	plainText, err := encr.aead.Open(nil, nonce, cipherText[explicitIVLength:], aad)
+	if err != nil {
+		return nil, errors.Wrapf(ErrAEADAuthFailed, "EncrChaCha20Poly1305Crypto")
+	}
+
+	plainText, err = removePadding(plainText, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "EncrChaCha20Poly1305Crypto")
+	}
+	return plainText, nil
+}
@@ -0,0 +1,180 @@
+package encr
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nathaniel-bennett/ike/message"
+)
+
+// AEAD example of RFC 8439 section 2.8.2, which RFC 7634 builds on: the
+// nonce is the salt 07000000 followed by the explicit IV 4041424344454647
+const (
+	chachaKey       = "808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f" + "07000000"
+	chachaIV        = "4041424344454647"
+	chachaAAD       = "50515253c0c1c2c3c4c5c6c7"
+	chachaPlainText = "Ladies and Gentlemen of the class of '99: If I could offer you only one tip for the future," +
+		" sunscreen would be it."
+	chachaCipherText = "d31a8d34648e60db7b86afbc53ef7ec2a4aded51296e08fea9e2b5a736ee62d63dbea45e8ca9671282fafb69da92728b" +
+		"1a71de0a9e060b2905d6a5b67ecd3b3692ddbd7f2d778b8c9803aee328091b58fab324e4fad675945585808b4831d7bc" +
+		"3ff4def08e4b7a9de576d26586cec64b6116"
+	chachaTag = "1ae10b594f09e26a7e902ecbd0600691"
+)
+
+// Examples of RFC 7634 appendices A and B, which share the keymat and the
+// explicit IV: the nonce is the salt a0a1a2a3 followed by 1011121314151617
+const (
+	rfc7634Keymat = "808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9f" + "a0a1a2a3"
+	rfc7634IV     = "1011121314151617"
+
+	// Appendix A: IPv4 ICMP echo request in a tunnel mode ESP packet, with
+	// the ESP trailer 01 02 (padding), 02 (pad length) and 04 (next header).
+	// The associated data is the SPI 01020304 and the sequence number 5.
+	rfc7634ESPAAD       = "0102030400000005"
+	rfc7634ESPPlainText = "45000054a6f200004001e778c6336405c000020508005b7a3a080000553bec100007362708090a0b0c0d0e0f" +
+		"101112131415161718191a1b1c1d1e1f202122232425262728292a2b2c2d2e2f3031323334353637" + "01020204"
+	rfc7634ESPCipherText = "24039428b97f417e3c13753a4f05087b67c352e6a7fab1b982d466ef407ae5c614ee8099d52844eb61aa95df" +
+		"ab4c02f72aa71e7c4c4f64c9befe2facc638e8f3cbec163fac469b502773f6fb94e664da9165b82829f641e0"
+	rfc7634ESPTag = "76aaa8266b7fb0f7b11b369907e1ad43"
+
+	// Appendix B: INFORMATIONAL request holding a SET_WINDOW_SIZE notify and
+	// the pad length 00. The associated data is the IKE header followed by
+	// the Encrypted payload header.
+	rfc7634IKEAAD        = "c0c1c2c3c4c5c6c7d0d1d2d3d4d5d6d72e202500000000090000004529000029"
+	rfc7634IKEPlainText  = "0000000c000040010000000a"
+	rfc7634IKECipherText = "610394701f8d017f7c12924889"
+	rfc7634IKETag        = "6b71bfe25236efd7cdc67066906315b2"
+)
+
+func newTestChaCha20Poly1305Crypto(t *testing.T, keymat string) *EncrChaCha20Poly1305Crypto {
+	encrType := StrToType(ENCR_CHACHA20_POLY1305)
+	require.NotNil(t, encrType)
+	key, err := hex.DecodeString(keymat)
+	require.NoError(t, err)
+	crypto, err := encrType.NewCrypto(key)
+	require.NoError(t, err)
+	return crypto.(*EncrChaCha20Poly1305Crypto)
+}
+
+func TestChaCha20Poly1305Transform(t *testing.T) {
+	encrType := StrToType(ENCR_CHACHA20_POLY1305)
+	require.NotNil(t, encrType)
+	require.Equal(t, 36, encrType.GetKeyLength())
+	require.Equal(t, 16, encrType.GetICVLength())
+	require.True(t, encrType.IsAEAD())
+
+	transform, err := ToTransform(encrType)
+	require.NoError(t, err)
+	require.False(t, transform.AttributePresent)
+	require.Equal(t, encrType, DecodeTransform(transform))
+
+	// The key length attribute must not be sent
+	transform.AttributePresent = true
+	transform.AttributeType = message.AttributeTypeKeyLength
+	transform.AttributeValue = 256
+	require.Nil(t, DecodeTransform(transform))
+}
+
+func TestChaCha20Poly1305Open(t *testing.T) {
+	sk := newTestChaCha20Poly1305Crypto(t, chachaKey)
+	cipherText, err := hex.DecodeString(chachaIV + chachaCipherText + chachaTag)
+	require.NoError(t, err)
+	aad, err := hex.DecodeString(chachaAAD)
+	require.NoError(t, err)
+
+	// The last plaintext byte '.' (0x2e) is taken as the pad length
+	plainText, err := sk.Open(cipherText, aad)
+	require.NoError(t, err)
+	require.Equal(t, []byte(chachaPlainText[:len(chachaPlainText)-0x2e-1]), plainText)
+
+	// Any change to the associated data, ciphertext or ICV is detected
+	aad[0] ^= 1
+	_, err = sk.Open(cipherText, aad)
+	require.ErrorIs(t, err, ErrAEADAuthFailed)
+	aad[0] ^= 1
+	for _, i := range []int{0, explicitIVLength, len(cipherText) - 1} {
+		cipherText[i] ^= 1
+		_, err = sk.Open(cipherText, aad)
+		require.ErrorIs(t, err, ErrAEADAuthFailed)
+		cipherText[i] ^= 1
+	}
+
+	_, err = sk.Open(cipherText[:explicitIVLength+16], aad)
+	require.Error(t, err)
+}
+
+func TestChaCha20Poly1305Seal(t *testing.T) {
+	// Same inputs as the RFC 8439 example, with the zero pad length byte
+	// appended to the plaintext: the ciphertext gains the byte 0c and the
+	// ICV is recomputed over it
+	expected, err := hex.DecodeString(chachaIV + chachaCipherText + "0c" + "1b3e8e84ed75b503947d8cb3535268dd")
+	require.NoError(t, err)
+	aad, err := hex.DecodeString(chachaAAD)
+	require.NoError(t, err)
+
+	sk := newTestChaCha20Poly1305Crypto(t, chachaKey)
+	sk.Iv, err = hex.DecodeString(chachaIV)
+	require.NoError(t, err)
+	cipherText, err := sk.Seal([]byte(chachaPlainText), aad)
+	require.NoError(t, err)
+	require.Equal(t, expected, cipherText)
+
+	plainText, err := sk.Open(cipherText, aad)
+	require.NoError(t, err)
+	require.Equal(t, []byte(chachaPlainText), plainText)
+	require.Equal(t, uint64(1), sk.EncryptionCount())
+}
+
+func TestChaCha20Poly1305RFC7634ESP(t *testing.T) {
+	sk := newTestChaCha20Poly1305Crypto(t, rfc7634Keymat)
+	require.Equal(t, []byte{0xa0, 0xa1, 0xa2, 0xa3}, sk.salt)
+	iv, err := hex.DecodeString(rfc7634IV)
+	require.NoError(t, err)
+	aad, err := hex.DecodeString(rfc7634ESPAAD)
+	require.NoError(t, err)
+	plainText, err := hex.DecodeString(rfc7634ESPPlainText)
+	require.NoError(t, err)
+	expected, err := hex.DecodeString(rfc7634ESPCipherText + rfc7634ESPTag)
+	require.NoError(t, err)
+
+	// ESP brings its own trailer, so the AEAD is checked with the nonce built
+	// from the salt and the explicit IV
+	nonce := aeadNonce(sk.salt, iv)
+	require.Equal(t, []byte{0xa0, 0xa1, 0xa2, 0xa3, 0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17}, nonce)
+	require.Equal(t, expected, sk.aead.Seal(nil, nonce, plainText, aad))
+
+	// Open takes the explicit IV from the packet, and the next header 04
+	// as the pad length of the IKE padding
+	decrypted, err := sk.Open(append(iv, expected...), aad)
+	require.NoError(t, err)
+	require.Equal(t, plainText[:len(plainText)-1-4], decrypted)
+}
+
+func TestChaCha20Poly1305RFC7634IKEv2(t *testing.T) {
+	sk := newTestChaCha20Poly1305Crypto(t, rfc7634Keymat)
+	expected, err := hex.DecodeString(rfc7634IV + rfc7634IKECipherText + rfc7634IKETag)
+	require.NoError(t, err)
+	aad, err := hex.DecodeString(rfc7634IKEAAD)
+	require.NoError(t, err)
+	plainText, err := hex.DecodeString(rfc7634IKEPlainText)
+	require.NoError(t, err)
+
+	// Seal appends the pad length 00 and sends the explicit IV ahead of the
+	// ciphertext
+	sk.Iv, err = hex.DecodeString(rfc7634IV)
+	require.NoError(t, err)
+	cipherText, err := sk.Seal(plainText, aad)
+	require.NoError(t, err)
+	require.Equal(t, expected, cipherText)
+
+	decrypted, err := sk.Open(expected, aad)
+	require.NoError(t, err)
+	require.Equal(t, plainText, decrypted)
+
+	// The explicit IV is part of the nonce
+	expected[0] ^= 1
+	_, err = sk.Open(expected, aad)
+	require.ErrorIs(t, err, ErrAEADAuthFailed)
+}
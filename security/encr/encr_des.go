@@ -0,0 +1,33 @@
+package encr
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/nathaniel-bennett/ike/message"
+)
+
+var ErrInsecureAlgorithmRejected = errors.New("insecure encryption algorithm rejected")
+
+// Single DES uses a 56-bit key, which is broken by exhaustive search. These
+// transforms are deliberately not implemented, and CheckTransform reports them
+// with ErrInsecureAlgorithmRejected instead of a generic unsupported error.
+var insecureEncrString = map[uint16]string{
+	message.ENCR_DES_IV64: "ENCR_DES_IV64",
+	message.ENCR_DES:      "ENCR_DES",
+	message.ENCR_DES_IV32: "ENCR_DES_IV32",
+}
+
+// CheckTransform returns ErrInsecureAlgorithmRejected if transform is a
+// single DES encryption algorithm, ErrInvalidKeyLength if it is ENCR_AES_CBC
+// with a key length other than 128, 192 or 256 bits, and nil otherwise. It
+// tells why DecodeTransform or DecodeTransformChildSA returned nil.
+func CheckTransform(transform *message.Transform) error {
+	if name, ok := insecureEncrString[transform.TransformID]; ok {
+		return errors.Wrapf(ErrInsecureAlgorithmRejected,
+			"%s: single DES is cryptographically broken and intentionally not implemented", name)
+	}
+	if transform.TransformID == message.ENCR_AES_CBC {
+		return checkAesCbcKeyLength(transform)
+	}
+	return nil
+}
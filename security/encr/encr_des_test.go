@@ -0,0 +1,27 @@
+package encr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nathaniel-bennett/ike/message"
+)
+
+func TestSingleDESRejected(t *testing.T) {
+	for _, id := range []uint16{message.ENCR_DES_IV64, message.ENCR_DES, message.ENCR_DES_IV32} {
+		transform := &message.Transform{
+			TransformType: message.TypeEncryptionAlgorithm,
+			TransformID:   id,
+		}
+		require.Nil(t, DecodeTransform(transform))
+		require.Nil(t, DecodeTransformChildSA(transform))
+		err := CheckTransform(transform)
+		require.ErrorIs(t, err, ErrInsecureAlgorithmRejected)
+		require.Contains(t, err.Error(), "single DES")
+	}
+
+	transform, err := ToTransform(StrToType(ENCR_AES_CBC_256))
+	require.NoError(t, err)
+	require.NoError(t, CheckTransform(transform))
+}
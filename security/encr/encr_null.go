@@ -25,6 +25,10 @@ func (t *EncrNull) TransformID() uint16 {
 	return message.ENCR_NULL
 }
 
+func (t *EncrNull) Name() string {
+	return ENCR_NULL
+}
+
 func (t *EncrNull) getAttribute() (bool, uint16, uint16, []byte, error) {
 	return true, message.AttributeTypeKeyLength, uint16(0), nil, nil
 }
@@ -33,6 +37,26 @@ func (t *EncrNull) GetKeyLength() int {
 	return 0
 }
 
+func (t *EncrNull) GetIVLength() int {
+	return 0
+}
+
+func (t *EncrNull) GetBlockSize() int {
+	return 1
+}
+
+func (t *EncrNull) GetICVLength() int {
+	return 0
+}
+
+func (t *EncrNull) IsAEAD() bool {
+	return false
+}
+
+func (t *EncrNull) Mode() CipherMode {
+	return CipherModeNull
+}
+
 func (t *EncrNull) NewCrypto(key []byte) (ikeCrypto.IKECrypto, error) {
 	encr := new(EncrNullCrypto)
 	return encr, nil
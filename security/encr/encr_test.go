@@ -0,0 +1,191 @@
+package encr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nathaniel-bennett/ike/message"
+)
+
+func TestDecodeTransformVariableLengthKeyLength(t *testing.T) {
+	transform := &message.Transform{
+		TransformType:    message.TypeEncryptionAlgorithm,
+		TransformID:      message.ENCR_AES_CBC,
+		AttributePresent: true,
+		AttributeFormat:  message.AttributeFormatUseTLV,
+		AttributeType:    message.AttributeTypeKeyLength,
+	}
+
+	transform.VariableLengthAttributeValue = []byte{0x01, 0x00}
+	require.Equal(t, StrToType(ENCR_AES_CBC_256), DecodeTransform(transform))
+	require.Equal(t, StrToKType(ENCR_AES_CBC_256), DecodeTransformChildSA(transform))
+
+	for _, value := range [][]byte{
+		{},
+		{0x01},
+		{0x00, 0x01, 0x00},
+		make([]byte, 0xfff4),
+	} {
+		transform.VariableLengthAttributeValue = value
+		require.Nil(t, DecodeTransform(transform), "length %d", len(value))
+		require.Nil(t, DecodeTransformChildSA(transform), "length %d", len(value))
+	}
+}
+
+// Expected keymat length of every registered cipher. For AEAD and counter
+// mode ciphers it includes the salt or nonce taken from the keymat.
+var expectedKeyLength = map[string]int{
+	ENCR_3DES:           24,
+	ENCR_NULL:           0,
+	ENCR_AES_CBC_128:    16,
+	ENCR_AES_CBC_192:    24,
+	ENCR_AES_CBC_256:    32,
+	ENCR_AES_CTR_128:    20,
+	ENCR_AES_CTR_192:    28,
+	ENCR_AES_CTR_256:    36,
+	ENCR_AES_GCM_8_128:  20,
+	ENCR_AES_GCM_8_192:  28,
+	ENCR_AES_GCM_8_256:  36,
+	ENCR_AES_GCM_12_128: 20,
+	ENCR_AES_GCM_12_192: 28,
+	ENCR_AES_GCM_12_256: 36,
+	ENCR_AES_GCM_16_128: 20,
+	ENCR_AES_GCM_16_192: 28,
+	ENCR_AES_GCM_16_256: 36,
+
+	ENCR_CAMELLIA_CBC_128:  16,
+	ENCR_CAMELLIA_CBC_192:  24,
+	ENCR_CAMELLIA_CBC_256:  32,
+	ENCR_CHACHA20_POLY1305: 36,
+}
+
+func TestGetKeyLength(t *testing.T) {
+	for name, encrType := range encrTypes {
+		expected, ok := expectedKeyLength[name]
+		require.True(t, ok, "%s missing from expected key lengths", name)
+		require.Equal(t, expected, encrType.GetKeyLength(), name)
+	}
+	for name, encrKType := range encrKTypes {
+		expected, ok := expectedKeyLength[name]
+		require.True(t, ok, "%s missing from expected key lengths", name)
+		require.Equal(t, expected, encrKType.GetKeyLength(), name)
+	}
+}
+
+func TestDecodeTransformOutOfRangeKeyLength(t *testing.T) {
+	transform := &message.Transform{
+		TransformType:    message.TypeEncryptionAlgorithm,
+		TransformID:      message.ENCR_AES_CBC,
+		AttributePresent: true,
+		AttributeFormat:  message.AttributeFormatUseTV,
+		AttributeType:    message.AttributeTypeKeyLength,
+	}
+
+	for _, keyLength := range []uint16{0, 64, 127, 129, 512, 65535} {
+		transform.AttributeValue = keyLength
+		require.Nil(t, DecodeTransform(transform), "key length %d", keyLength)
+		require.Nil(t, DecodeTransformChildSA(transform), "key length %d", keyLength)
+		require.ErrorIs(t, CheckTransform(transform), ErrInvalidKeyLength, "key length %d", keyLength)
+	}
+
+	// Key length attribute missing
+	transform.AttributePresent = false
+	transform.AttributeType = 0
+	transform.AttributeValue = 0
+	require.Nil(t, DecodeTransform(transform))
+	require.ErrorIs(t, CheckTransform(transform), ErrInvalidKeyLength)
+}
+
+func TestAesCbcInvalidKeyLength(t *testing.T) {
+	// A 20-byte key is no AES key
+	transform := &message.Transform{
+		TransformType:    message.TypeEncryptionAlgorithm,
+		TransformID:      message.ENCR_AES_CBC,
+		AttributePresent: true,
+		AttributeFormat:  message.AttributeFormatUseTV,
+		AttributeType:    message.AttributeTypeKeyLength,
+		AttributeValue:   160,
+	}
+	require.Nil(t, DecodeTransform(transform))
+	require.Nil(t, DecodeTransformChildSA(transform))
+	err := CheckTransform(transform)
+	require.ErrorIs(t, err, ErrInvalidKeyLength)
+	require.Contains(t, err.Error(), "160 bits")
+
+	for _, keyLength := range []uint16{128, 192, 256} {
+		transform.AttributeValue = keyLength
+		require.NotNil(t, DecodeTransform(transform))
+		require.NoError(t, CheckTransform(transform))
+	}
+
+	encrType := &EncrAesCbc{keyLength: 20}
+	_, err = encrType.NewCrypto(make([]byte, 20))
+	require.ErrorIs(t, err, ErrInvalidKeyLength)
+}
+
+var expectedMode = map[string]CipherMode{
+	ENCR_3DES:              CipherModeCBC,
+	ENCR_NULL:              CipherModeNull,
+	ENCR_AES_CBC_128:       CipherModeCBC,
+	ENCR_AES_CBC_192:       CipherModeCBC,
+	ENCR_AES_CBC_256:       CipherModeCBC,
+	ENCR_AES_CTR_128:       CipherModeCTR,
+	ENCR_AES_CTR_192:       CipherModeCTR,
+	ENCR_AES_CTR_256:       CipherModeCTR,
+	ENCR_AES_GCM_8_128:     CipherModeGCM,
+	ENCR_AES_GCM_8_192:     CipherModeGCM,
+	ENCR_AES_GCM_8_256:     CipherModeGCM,
+	ENCR_AES_GCM_12_128:    CipherModeGCM,
+	ENCR_AES_GCM_12_192:    CipherModeGCM,
+	ENCR_AES_GCM_12_256:    CipherModeGCM,
+	ENCR_AES_GCM_16_128:    CipherModeGCM,
+	ENCR_AES_GCM_16_192:    CipherModeGCM,
+	ENCR_AES_GCM_16_256:    CipherModeGCM,
+	ENCR_CAMELLIA_CBC_128:  CipherModeCBC,
+	ENCR_CAMELLIA_CBC_192:  CipherModeCBC,
+	ENCR_CAMELLIA_CBC_256:  CipherModeCBC,
+	ENCR_CHACHA20_POLY1305: CipherModeStream,
+}
+
+func TestMode(t *testing.T) {
+	for name, encrType := range encrTypes {
+		expected, ok := expectedMode[name]
+		require.True(t, ok, "%s missing from expected modes", name)
+		require.Equal(t, expected, encrType.Mode(), name)
+	}
+	for name, encrKType := range encrKTypes {
+		expected, ok := expectedMode[name]
+		require.True(t, ok, "%s missing from expected modes", name)
+		require.Equal(t, expected, encrKType.Mode(), name)
+	}
+	require.Equal(t, "GCM", CipherModeGCM.String())
+}
+
+func TestName(t *testing.T) {
+	for name, encrType := range encrTypes {
+		require.Equal(t, name, encrType.Name())
+	}
+	for name, encrKType := range encrKTypes {
+		require.Equal(t, name, encrKType.Name())
+	}
+}
+
+func TestNewCryptoKeyLengthMismatch(t *testing.T) {
+	// A 16 bytes key for a 256 bits AES-CBC instance
+	_, err := StrToType(ENCR_AES_CBC_256).NewCrypto(make([]byte, 16))
+	require.ErrorIs(t, err, ErrKeyLengthMismatch)
+	require.Contains(t, err.Error(), "key length 16 bytes, expected 32 bytes")
+
+	for name, encrType := range encrTypes {
+		if name == ENCR_NULL {
+			continue
+		}
+		for _, length := range []int{0, encrType.GetKeyLength() - 1, encrType.GetKeyLength() + 1} {
+			_, err = encrType.NewCrypto(make([]byte, length))
+			require.ErrorIs(t, err, ErrKeyLengthMismatch, "%s: %d bytes", name, length)
+		}
+		_, err = encrType.NewCrypto(make([]byte, encrType.GetKeyLength()))
+		require.NoError(t, err, name)
+	}
+}
@@ -0,0 +1,42 @@
+package security
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+
+	"github.com/nathaniel-bennett/ike/message"
+)
+
+// Length of the generic payload header of the Encrypted payload
+const encryptedPayloadHeaderLength = 4
+
+// EncryptedNextPayload returns the Next Payload value of an Encrypted payload
+// carrying inner: the type of the first inner payload, or NoNext if there is
+// none. The Encrypted payload is the last payload of a message, so its Next
+// Payload field is not the type of a following payload but chains to the
+// payloads it holds once decrypted, RFC 7296 section 3.14.
+func EncryptedNextPayload(inner message.IKEPayloadContainer) message.IKEPayloadType {
+	if len(inner) == 0 {
+		return message.NoNext
+	}
+	return inner[0].Type()
+}
+
+// EncryptedPayloadHeader returns the generic payload header of an Encrypted
+// payload carrying inner, whose IV, ciphertext and ICV are
+// encryptedDataLength bytes long: the Next Payload from EncryptedNextPayload,
+// the Critical bit cleared, and the payload length including the header. With
+// an AEAD cipher, this header ends the associated data, which follows the IKE
+// header, RFC 5282 section 5.1.
+func EncryptedPayloadHeader(inner message.IKEPayloadContainer, encryptedDataLength int) ([]byte, error) {
+	payloadLength := encryptedPayloadHeaderLength + encryptedDataLength
+	if encryptedDataLength <= 0 || payloadLength > 0xFFFF {
+		return nil, errors.Errorf("EncryptedPayloadHeader : invalid encrypted data length %d", encryptedDataLength)
+	}
+
+	header := make([]byte, encryptedPayloadHeaderLength)
+	header[0] = uint8(EncryptedNextPayload(inner))
+	binary.BigEndian.PutUint16(header[2:4], uint16(payloadLength))
+	return header, nil
+}
@@ -0,0 +1,55 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nathaniel-bennett/ike/message"
+)
+
+func TestEncryptedPayloadHeader(t *testing.T) {
+	var inner message.IKEPayloadContainer
+	inner.BuildIdentificationInitiator(message.ID_FQDN, []byte("ike.example"))
+	inner.BuildAuthentication(message.SharedKeyMesageIntegrityCode, make([]byte, 20))
+	require.Equal(t, message.TypeIDi, EncryptedNextPayload(inner))
+	require.Equal(t, message.NoNext, EncryptedNextPayload(nil))
+
+	// Next Payload, Critical bit and reserved, then the payload length
+	header, err := EncryptedPayloadHeader(inner, 0x0134)
+	require.NoError(t, err)
+	require.Equal(t, []byte{uint8(message.TypeIDi), 0x00, 0x01, 0x38}, header)
+
+	header, err = EncryptedPayloadHeader(nil, 28)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x00, 0x00, 0x00, 0x20}, header)
+
+	// The header is the one encoded ahead of the encrypted data, so that the
+	// associated data computed from it matches the message
+	encryptedData := make([]byte, 60)
+	ikeMsg := &message.IKEMessage{
+		IKEHeader: &message.IKEHeader{
+			InitiatorSPI: 0x123,
+			ResponderSPI: 0x456,
+			MajorVersion: 2,
+			ExchangeType: message.IKE_AUTH,
+			Flags:        message.InitiatorBitCheck,
+			MessageID:    1,
+		},
+	}
+	ikeMsg.Payloads.BuildEncrypted(EncryptedNextPayload(inner), encryptedData)
+	b, err := ikeMsg.Encode()
+	require.NoError(t, err)
+	header, err = EncryptedPayloadHeader(inner, len(encryptedData))
+	require.NoError(t, err)
+	aad := b[:len(b)-len(encryptedData)]
+	require.Len(t, aad, message.IKE_HEADER_LEN+len(header))
+	require.Equal(t, header, aad[message.IKE_HEADER_LEN:])
+
+	for _, length := range []int{0, -1, 0xFFFF - 3} {
+		_, err = EncryptedPayloadHeader(inner, length)
+		require.Error(t, err, length)
+	}
+	_, err = EncryptedPayloadHeader(inner, 0xFFFF-4)
+	require.NoError(t, err)
+}
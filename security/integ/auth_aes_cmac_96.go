@@ -0,0 +1,56 @@
+package integ
+
+import (
+	"hash"
+
+	"github.com/nathaniel-bennett/ike/message"
+	"github.com/nathaniel-bennett/ike/security/lib/cmac"
+)
+
+func toString_AUTH_AES_CMAC_96(attrType uint16, intValue uint16, bytesValue []byte) string {
+	return AUTH_AES_CMAC_96
+}
+
+var (
+	_ INTEGType  = &AuthAesCmac96{}
+	_ INTEGKType = &AuthAesCmac96{}
+)
+
+// AuthAesCmac96 is AES-CMAC-96, RFC 4494: Init returns the full 128-bit
+// AES-CMAC, truncated to 96 bits as the other integrity algorithms
+type AuthAesCmac96 struct {
+	keyLength    int
+	outputLength int
+}
+
+func (t *AuthAesCmac96) TransformID() uint16 {
+	return message.AUTH_AES_CMAC_96
+}
+
+func (t *AuthAesCmac96) Name() string {
+	return AUTH_AES_CMAC_96
+}
+
+func (t *AuthAesCmac96) getAttribute() (bool, uint16, uint16, []byte) {
+	return false, 0, 0, nil
+}
+
+func (t *AuthAesCmac96) GetKeyLength() int {
+	return t.keyLength
+}
+
+func (t *AuthAesCmac96) GetOutputLength() int {
+	return t.outputLength
+}
+
+func (t *AuthAesCmac96) Family() IntegFamily {
+	return IntegFamilyCMAC
+}
+
+func (t *AuthAesCmac96) Init(key []byte) hash.Hash {
+	if h, err := cmac.New(key); err == nil {
+		return h
+	} else {
+		return nil
+	}
+}
@@ -0,0 +1,56 @@
+package integ
+
+import (
+	"hash"
+
+	"github.com/nathaniel-bennett/ike/message"
+	"github.com/nathaniel-bennett/ike/security/lib/xcbc"
+)
+
+func toString_AUTH_AES_XCBC_96(attrType uint16, intValue uint16, bytesValue []byte) string {
+	return AUTH_AES_XCBC_96
+}
+
+var (
+	_ INTEGType  = &AuthAesXcbc96{}
+	_ INTEGKType = &AuthAesXcbc96{}
+)
+
+// AuthAesXcbc96 is AES-XCBC-MAC-96, RFC 3566: Init returns the full 128-bit
+// AES-XCBC-MAC, truncated to 96 bits as the other integrity algorithms
+type AuthAesXcbc96 struct {
+	keyLength    int
+	outputLength int
+}
+
+func (t *AuthAesXcbc96) TransformID() uint16 {
+	return message.AUTH_AES_XCBC_96
+}
+
+func (t *AuthAesXcbc96) Name() string {
+	return AUTH_AES_XCBC_96
+}
+
+func (t *AuthAesXcbc96) getAttribute() (bool, uint16, uint16, []byte) {
+	return false, 0, 0, nil
+}
+
+func (t *AuthAesXcbc96) GetKeyLength() int {
+	return t.keyLength
+}
+
+func (t *AuthAesXcbc96) GetOutputLength() int {
+	return t.outputLength
+}
+
+func (t *AuthAesXcbc96) Family() IntegFamily {
+	return IntegFamilyXCBC
+}
+
+func (t *AuthAesXcbc96) Init(key []byte) hash.Hash {
+	if h, err := xcbc.New(key); err == nil {
+		return h
+	} else {
+		return nil
+	}
+}
@@ -26,6 +26,10 @@ func (t *AuthHmacMd5_95) TransformID() uint16 {
 	return message.AUTH_HMAC_MD5_96
 }
 
+func (t *AuthHmacMd5_95) Name() string {
+	return AUTH_HMAC_MD5_96
+}
+
 func (t *AuthHmacMd5_95) getAttribute() (bool, uint16, uint16, []byte) {
 	return false, 0, 0, nil
 }
@@ -38,6 +42,10 @@ func (t *AuthHmacMd5_95) GetOutputLength() int {
 	return t.outputLength
 }
 
+func (t *AuthHmacMd5_95) Family() IntegFamily {
+	return IntegFamilyHMAC
+}
+
 func (t *AuthHmacMd5_95) Init(key []byte) hash.Hash {
 	if len(key) == 16 {
 		return hmac.New(md5.New, key)
@@ -26,6 +26,10 @@ func (t *AuthHmacSha1_96) TransformID() uint16 {
 	return message.AUTH_HMAC_SHA1_96
 }
 
+func (t *AuthHmacSha1_96) Name() string {
+	return AUTH_HMAC_SHA1_96
+}
+
 func (t *AuthHmacSha1_96) getAttribute() (bool, uint16, uint16, []byte) {
 	return false, 0, 0, nil
 }
@@ -38,6 +42,10 @@ func (t *AuthHmacSha1_96) GetOutputLength() int {
 	return t.outputLength
 }
 
+func (t *AuthHmacSha1_96) Family() IntegFamily {
+	return IntegFamilyHMAC
+}
+
 func (t *AuthHmacSha1_96) Init(key []byte) hash.Hash {
 	if len(key) == 20 {
 		return hmac.New(sha1.New, key)
@@ -26,6 +26,10 @@ func (t *AuthHmacSha2_256_128) TransformID() uint16 {
 	return message.AUTH_HMAC_SHA2_256_128
 }
 
+func (t *AuthHmacSha2_256_128) Name() string {
+	return AUTH_HMAC_SHA2_256_128
+}
+
 func (t *AuthHmacSha2_256_128) getAttribute() (bool, uint16, uint16, []byte) {
 	return false, 0, 0, nil
 }
@@ -38,6 +42,10 @@ func (t *AuthHmacSha2_256_128) GetOutputLength() int {
 	return t.outputLength
 }
 
+func (t *AuthHmacSha2_256_128) Family() IntegFamily {
+	return IntegFamilyHMAC
+}
+
 func (t *AuthHmacSha2_256_128) Init(key []byte) hash.Hash {
 	if len(key) == 32 {
 		return hmac.New(sha256.New, key)
@@ -10,6 +10,10 @@ const (
 	AUTH_HMAC_MD5_96       string = "AUTH_HMAC_MD5_96"
 	AUTH_HMAC_SHA1_96      string = "AUTH_HMAC_SHA1_96"
 	AUTH_HMAC_SHA2_256_128 string = "AUTH_HMAC_SHA2_256_128"
+	AUTH_HMAC_SHA2_384_192 string = "AUTH_HMAC_SHA2_384_192"
+	AUTH_HMAC_SHA2_512_256 string = "AUTH_HMAC_SHA2_512_256"
+	AUTH_AES_XCBC_96       string = "AUTH_AES_XCBC_96"
+	AUTH_AES_CMAC_96       string = "AUTH_AES_CMAC_96"
 )
 
 var integString map[uint16]func(uint16, uint16, []byte) string
@@ -25,6 +29,10 @@ func init() {
 	integString[message.AUTH_HMAC_MD5_96] = toString_AUTH_HMAC_MD5_96
 	integString[message.AUTH_HMAC_SHA1_96] = toString_AUTH_HMAC_SHA1_96
 	integString[message.AUTH_HMAC_SHA2_256_128] = toString_AUTH_HMAC_SHA2_256_128
+	integString[message.AUTH_HMAC_SHA2_384_192] = toString_AUTH_HMAC_SHA2_384_192
+	integString[message.AUTH_HMAC_SHA2_512_256] = toString_AUTH_HMAC_SHA2_512_256
+	integString[message.AUTH_AES_XCBC_96] = toString_AUTH_AES_XCBC_96
+	integString[message.AUTH_AES_CMAC_96] = toString_AUTH_AES_CMAC_96
 
 	// INTEG Types
 	integTypes = make(map[string]INTEGType)
@@ -41,6 +49,22 @@ func init() {
 		keyLength:    32,
 		outputLength: 16,
 	}
+	integTypes[AUTH_HMAC_SHA2_384_192] = &AuthHmacSha2_384_192{
+		keyLength:    48,
+		outputLength: 24,
+	}
+	integTypes[AUTH_HMAC_SHA2_512_256] = &AuthHmacSha2_512_256{
+		keyLength:    64,
+		outputLength: 32,
+	}
+	integTypes[AUTH_AES_XCBC_96] = &AuthAesXcbc96{
+		keyLength:    16,
+		outputLength: 12,
+	}
+	integTypes[AUTH_AES_CMAC_96] = &AuthAesCmac96{
+		keyLength:    16,
+		outputLength: 12,
+	}
 
 	// INTEG Kernel Types
 	integKTypes = make(map[string]INTEGKType)
@@ -57,6 +81,22 @@ func init() {
 		keyLength:    32,
 		outputLength: 16,
 	}
+	integKTypes[AUTH_HMAC_SHA2_384_192] = &AuthHmacSha2_384_192{
+		keyLength:    48,
+		outputLength: 24,
+	}
+	integKTypes[AUTH_HMAC_SHA2_512_256] = &AuthHmacSha2_512_256{
+		keyLength:    64,
+		outputLength: 32,
+	}
+	integKTypes[AUTH_AES_XCBC_96] = &AuthAesXcbc96{
+		keyLength:    16,
+		outputLength: 12,
+	}
+	integKTypes[AUTH_AES_CMAC_96] = &AuthAesCmac96{
+		keyLength:    16,
+		outputLength: 12,
+	}
 }
 
 func StrToType(algo string) INTEGType {
@@ -131,16 +171,45 @@ func ToTransformChildSA(integKType INTEGKType) *message.Transform {
 	return t
 }
 
+// IntegFamily is the construction an integrity algorithm is built on
+type IntegFamily uint8
+
+const (
+	IntegFamilyHMAC IntegFamily = iota
+	IntegFamilyCMAC
+	IntegFamilyXCBC
+)
+
+func (f IntegFamily) String() string {
+	switch f {
+	case IntegFamilyHMAC:
+		return "HMAC"
+	case IntegFamilyCMAC:
+		return "CMAC"
+	case IntegFamilyXCBC:
+		return "XCBC"
+	default:
+		return "Unknown"
+	}
+}
+
 type INTEGType interface {
 	TransformID() uint16
+	Name() string
 	getAttribute() (bool, uint16, uint16, []byte)
 	GetKeyLength() int
+	// GetOutputLength returns the length of the ICV, to which the output of
+	// the hash returned by Init is truncated
 	GetOutputLength() int
+	Family() IntegFamily
 	Init(key []byte) hash.Hash
 }
 
 type INTEGKType interface {
 	TransformID() uint16
+	Name() string
 	getAttribute() (bool, uint16, uint16, []byte)
 	GetKeyLength() int
+	GetOutputLength() int
+	Family() IntegFamily
 }
@@ -0,0 +1,194 @@
+package integ
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Expected truncated output (ICV) length of every registered integrity
+// algorithm
+var expectedOutputLength = map[string]int{
+	AUTH_HMAC_MD5_96:       12,
+	AUTH_HMAC_SHA1_96:      12,
+	AUTH_HMAC_SHA2_256_128: 16,
+	AUTH_HMAC_SHA2_384_192: 24,
+	AUTH_HMAC_SHA2_512_256: 32,
+	AUTH_AES_XCBC_96:       12,
+	AUTH_AES_CMAC_96:       12,
+}
+
+func TestGetOutputLength(t *testing.T) {
+	for name, integType := range integTypes {
+		expected, ok := expectedOutputLength[name]
+		require.True(t, ok, "%s missing from expected output lengths", name)
+		require.Equal(t, expected, integType.GetOutputLength(), name)
+
+		// The ICV is the truncated output of the hash
+		h := integType.Init(make([]byte, integType.GetKeyLength()))
+		require.Greater(t, h.Size(), expected, name)
+	}
+	for name, integKType := range integKTypes {
+		expected, ok := expectedOutputLength[name]
+		require.True(t, ok, "%s missing from expected output lengths", name)
+		require.Equal(t, expected, integKType.GetOutputLength(), name)
+	}
+}
+
+var expectedFamily = map[string]IntegFamily{
+	AUTH_HMAC_MD5_96:       IntegFamilyHMAC,
+	AUTH_HMAC_SHA1_96:      IntegFamilyHMAC,
+	AUTH_HMAC_SHA2_256_128: IntegFamilyHMAC,
+	AUTH_HMAC_SHA2_384_192: IntegFamilyHMAC,
+	AUTH_HMAC_SHA2_512_256: IntegFamilyHMAC,
+	AUTH_AES_XCBC_96:       IntegFamilyXCBC,
+	AUTH_AES_CMAC_96:       IntegFamilyCMAC,
+}
+
+func TestFamily(t *testing.T) {
+	for name, integType := range integTypes {
+		expected, ok := expectedFamily[name]
+		require.True(t, ok, "%s missing from expected families", name)
+		require.Equal(t, expected, integType.Family(), name)
+	}
+	for name, integKType := range integKTypes {
+		expected, ok := expectedFamily[name]
+		require.True(t, ok, "%s missing from expected families", name)
+		require.Equal(t, expected, integKType.Family(), name)
+	}
+	require.Equal(t, "CMAC", IntegFamilyCMAC.String())
+}
+
+func TestName(t *testing.T) {
+	for name, integType := range integTypes {
+		require.Equal(t, name, integType.Name())
+	}
+	for name, integKType := range integKTypes {
+		require.Equal(t, name, integKType.Name())
+	}
+}
+
+type hmacTestCase struct {
+	key  []byte
+	data []byte
+	hmac string
+}
+
+// hmacTestCases returns test cases 1 to 4 of RFC 4868 section 2.7.2, whose
+// keys are keyLength bytes long, along with the expected full HMACs
+func hmacTestCases(keyLength int, hmacs [4]string) []hmacTestCase {
+	key4 := make([]byte, keyLength)
+	for i := range key4 {
+		key4[i] = byte(i + 1)
+	}
+	return []hmacTestCase{
+		{bytes.Repeat([]byte{0x0b}, keyLength), []byte("Hi There"), hmacs[0]},
+		{bytes.Repeat([]byte("Jefe"), keyLength/4), []byte("what do ya want for nothing?"), hmacs[1]},
+		{bytes.Repeat([]byte{0xaa}, keyLength), bytes.Repeat([]byte{0xdd}, 50), hmacs[2]},
+		{key4, bytes.Repeat([]byte{0xcd}, 50), hmacs[3]},
+	}
+}
+
+// testHmacVectors checks that the hash of the integrity algorithm gives the
+// full HMAC of each test case, and that the ICV is its first outputLength
+// bytes
+func testHmacVectors(t *testing.T, name string, keyLength, outputLength int, testcases []hmacTestCase) {
+	integType := StrToType(name)
+	require.Equal(t, keyLength, integType.GetKeyLength())
+	require.Equal(t, outputLength, integType.GetOutputLength())
+	require.Equal(t, keyLength, StrToKType(name).GetKeyLength())
+	require.Equal(t, outputLength, StrToKType(name).GetOutputLength())
+
+	for i, tc := range testcases {
+		expected, err := hex.DecodeString(tc.hmac)
+		require.NoError(t, err)
+
+		h := integType.Init(tc.key)
+		require.Equal(t, len(expected), h.Size())
+		_, err = h.Write(tc.data)
+		require.NoError(t, err)
+		sum := h.Sum(nil)
+		require.Equal(t, expected, sum, "test case %d", i+1)
+		require.Equal(t, expected[:outputLength], sum[:integType.GetOutputLength()], "test case %d", i+1)
+	}
+
+	require.Nil(t, integType.Init(make([]byte, 16)))
+}
+
+func TestAuthHmacSha2_256_128(t *testing.T) {
+	testHmacVectors(t, AUTH_HMAC_SHA2_256_128, 32, 16, hmacTestCases(32, [4]string{
+		"198a607eb44bfbc69903a0f1cf2bbdc5ba0aa3f3d9ae3c1c7a3b1696a0b68cf7",
+		"167f928588c5cc2eef8e3093caa0e87c9ff566a14794aa61648d81621a2a40c6",
+		"cdcb1220d1ecccea91e53aba3092f962e549fe6ce9ed7fdc43191fbde45c30b0",
+		"372efcf9b40b35c2115b1346903d2ef42fced46f0846e7257bb156d3d7b30d3f",
+	}))
+}
+
+func TestAuthHmacSha2_384_192(t *testing.T) {
+	testHmacVectors(t, AUTH_HMAC_SHA2_384_192, 48, 24, hmacTestCases(48, [4]string{
+		"b6a8d5636f5c6a7224f9977dcf7ee6c7fb6d0c48cbdee9737a959796489bddbc4c5df61d5b3297b4fb68dab9f1b582c2",
+		"2c7353974f1842fd66d53c452ca42122b28c0b594cfb184da86a368e9b8e16f5349524ca4e82400cbde0686d403371c9",
+		"809f439be00274321d4a538652164b53554a508184a0c3160353e3428597003d35914a18770f9443987054944b7c4b4a",
+		"e8909051b8fe1a37966eec37a868389185d9da142bc5f75542169b86fc1efb427cd1cacf23f8afb18daf17d93a1de03e",
+	}))
+}
+
+func TestAuthHmacSha2_512_256(t *testing.T) {
+	testHmacVectors(t, AUTH_HMAC_SHA2_512_256, 64, 32, hmacTestCases(64, [4]string{
+		"637edc6e01dce7e6742a99451aae82df23da3e92439e590e43e761b33e910fb8" +
+			"ac2878ebd5803f6f0b61dbce5e251ff8789a4722c1be65aea45fd464e89f8f5b",
+		"cb370917ae8a7ce28cfd1d8f4705d6141c173b2a9362c15df235dfb251b15454" +
+			"6aa334ae9fb9afc2184932d8695e397bfa0ffb93466cfcceaae38c833b7dba38",
+		"2ee7acd783624ca9398710f3ee05ae41b9f9b0510c87e49e586cc9bf961733d8" +
+			"623c7b55cebefccf02d5581acc1c9d5fb1ff68a1de45509fbe4da9a433922655",
+		"5e6688e5a3daec826ca32eaea224eff5e700628947470e13ad01302561bab108" +
+			"b8c48cbc6b807dcfbd850521a685babc7eae4a2a2e660dc0e86b931d65503fd2",
+	}))
+}
+
+func TestAuthAesXcbc96(t *testing.T) {
+	integType := StrToType(AUTH_AES_XCBC_96)
+	require.Equal(t, 16, integType.GetKeyLength())
+	require.Equal(t, 12, integType.GetOutputLength())
+
+	key, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+
+	// Test case 4 of RFC 3566 section 4.6: 20 bytes message 00010203...13
+	expected, err := hex.DecodeString("47f51b4564966215b8985c63")
+	require.NoError(t, err)
+
+	h := integType.Init(key)
+	_, err = h.Write(key)
+	require.NoError(t, err)
+	_, err = h.Write([]byte{0x10, 0x11, 0x12, 0x13})
+	require.NoError(t, err)
+	require.Equal(t, expected, h.Sum(nil)[:integType.GetOutputLength()])
+
+	require.Nil(t, integType.Init(make([]byte, 20)))
+}
+
+func TestAuthAesCmac96(t *testing.T) {
+	integType := StrToType(AUTH_AES_CMAC_96)
+	require.Equal(t, 16, integType.GetKeyLength())
+	require.Equal(t, 12, integType.GetOutputLength())
+
+	key, err := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3c")
+	require.NoError(t, err)
+	message, err := hex.DecodeString("6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e51" +
+		"30c81c46a35ce411e5fbc1191a0a52ef")
+	require.NoError(t, err)
+
+	// Test case 3 of RFC 4494 section 4: 40 bytes message
+	expected, err := hex.DecodeString("dfa66747de9ae63030ca3261")
+	require.NoError(t, err)
+
+	h := integType.Init(key)
+	_, err = h.Write(message[:40])
+	require.NoError(t, err)
+	require.Equal(t, expected, h.Sum(nil)[:integType.GetOutputLength()])
+
+	require.Nil(t, integType.Init(make([]byte, 20)))
+}
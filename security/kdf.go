@@ -0,0 +1,41 @@
+package security
+
+import (
+	"hash"
+
+	"github.com/pkg/errors"
+
+	"github.com/nathaniel-bennett/ike/security/lib"
+)
+
+// KDF expands a keyed PRF and a seed into length bytes of keying material.
+// The IKE SA and child SA keys are sliced from its output.
+type KDF interface {
+	Expand(prf hash.Hash, seed []byte, length int) ([]byte, error)
+}
+
+// PrfPlusKDF is the prf+ function defined in RFC 7296 section 2.13, used
+// unless the WithKDF option is given
+var PrfPlusKDF KDF = prfPlusKDF{}
+
+type prfPlusKDF struct{}
+
+func (prfPlusKDF) Expand(prf hash.Hash, seed []byte, length int) ([]byte, error) {
+	keyStream := lib.PrfPlus(prf, seed, length)
+	if keyStream == nil {
+		return nil, errors.Errorf("Error happened in PrfPlus")
+	}
+	return keyStream, nil
+}
+
+// expand runs kdf and checks it returned the requested length
+func expand(kdf KDF, prf hash.Hash, seed []byte, length int) ([]byte, error) {
+	keyStream, err := kdf.Expand(prf, seed, length)
+	if err != nil {
+		return nil, err
+	}
+	if len(keyStream) != length {
+		return nil, errors.Errorf("KDF returned %d bytes, expected %d", len(keyStream), length)
+	}
+	return keyStream, nil
+}
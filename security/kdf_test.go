@@ -0,0 +1,148 @@
+package security
+
+import (
+	"hash"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nathaniel-bennett/ike/security/dh"
+	"github.com/nathaniel-bennett/ike/security/encr"
+	"github.com/nathaniel-bennett/ike/security/integ"
+	"github.com/nathaniel-bennett/ike/security/prf"
+)
+
+// counterKDF fills the keymat with a counter, ignoring the PRF and seed
+type counterKDF struct {
+	calls  int
+	length int
+}
+
+func (k *counterKDF) Expand(prf hash.Hash, seed []byte, length int) ([]byte, error) {
+	k.calls++
+	k.length += length
+	stream := make([]byte, length)
+	for i := range stream {
+		stream[i] = byte(i)
+	}
+	return stream, nil
+}
+
+type shortKDF struct{}
+
+func (shortKDF) Expand(prf hash.Hash, seed []byte, length int) ([]byte, error) {
+	return make([]byte, length-1), nil
+}
+
+type failingKDF struct{}
+
+func (failingKDF) Expand(prf hash.Hash, seed []byte, length int) ([]byte, error) {
+	return nil, errors.New("expand failed")
+}
+
+func TestGenerateKeyWithKDF(t *testing.T) {
+	// Default and explicit prf+ derive the standard keys
+	standard := newTestLabelIKESAKey(t)
+	explicit := newTestLabelIKESAKey(t, WithKDF(PrfPlusKDF))
+	require.Equal(t, standard.SK_d, explicit.SK_d)
+	require.Equal(t, standard.SK_pr, explicit.SK_pr)
+
+	kdf := new(counterKDF)
+	ikesaKey := newTestLabelIKESAKey(t, WithKDF(kdf))
+	require.Equal(t, 1, kdf.calls)
+	// SK_d is the first 20 bytes of the keymat, SK_ai the next 20
+	require.Equal(t, []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19},
+		ikesaKey.SK_d)
+	require.Equal(t, byte(20), ikesaKey.SK_ai[0])
+
+	childsaKey := &ChildSAKey{
+		EncrKInfo:  encr.StrToKType("ENCR_AES_CBC_128"),
+		IntegKInfo: integ.StrToKType("AUTH_HMAC_SHA1_96"),
+	}
+	err := childsaKey.GenerateKeyForChildSA(standard, []byte{0x01, 0x02, 0x03, 0x04}, WithKDF(kdf))
+	require.NoError(t, err)
+	require.Equal(t, 2, kdf.calls)
+	require.Equal(t, byte(0), childsaKey.InitiatorToResponderEncryptionKey[0])
+	require.Equal(t, byte(16), childsaKey.InitiatorToResponderIntegrityKey[0])
+
+	// A KDF returning an error or a short keymat fails the derivation
+	for _, kdf := range []KDF{failingKDF{}, shortKDF{}} {
+		ikesaKey := &IKESAKey{
+			DhInfo:    standard.DhInfo,
+			EncrInfo:  standard.EncrInfo,
+			IntegInfo: standard.IntegInfo,
+			PrfInfo:   standard.PrfInfo,
+		}
+		err = ikesaKey.GenerateKeyForIKESA([]byte{0x01, 0x02, 0x03, 0x04},
+			[]byte{0x05, 0x06, 0x07, 0x08}, 0x456, 0x123, WithKDF(kdf))
+		require.Error(t, err)
+		require.Nil(t, ikesaKey.SK_d)
+
+		childsaKey := &ChildSAKey{
+			EncrKInfo: encr.StrToKType("ENCR_AES_CBC_128"),
+		}
+		err = childsaKey.GenerateKeyForChildSA(standard, []byte{0x01, 0x02, 0x03, 0x04}, WithKDF(kdf))
+		require.Error(t, err)
+	}
+}
+
+// oddKeyLengthEncr is a NULL cipher reporting a key length that no standard
+// transform has
+type oddKeyLengthEncr struct {
+	encr.ENCRType
+}
+
+func (oddKeyLengthEncr) GetKeyLength() int {
+	return 17
+}
+
+// oddKeyLengthInteg is HMAC-SHA2-256 reporting a non-standard key length
+type oddKeyLengthInteg struct {
+	integ.INTEGType
+}
+
+func (oddKeyLengthInteg) GetKeyLength() int {
+	return 21
+}
+
+func TestGenerateKeyOddKeyLengths(t *testing.T) {
+	ikesaKey := &IKESAKey{
+		EncrInfo:  oddKeyLengthEncr{encr.StrToType("ENCR_NULL")},
+		IntegInfo: oddKeyLengthInteg{integ.StrToType("AUTH_HMAC_SHA2_256_128")},
+		PrfInfo:   prf.StrToType("PRF_HMAC_SHA1"),
+		DhInfo:    dh.StrToType("DH_2048_BIT_MODP"),
+	}
+	kdf := new(counterKDF)
+	err := ikesaKey.GenerateKeyForIKESA([]byte{0x01, 0x02, 0x03, 0x04}, []byte{0x05, 0x06},
+		0x123, 0x456, WithKDF(kdf))
+	require.NoError(t, err)
+
+	// The keymat is exactly SK_d | SK_ai | SK_ar | SK_ei | SK_er | SK_pi | SK_pr
+	require.Equal(t, 20+21+21+17+17+20+20, kdf.length)
+	offset := 0
+	for _, key := range []struct {
+		name   string
+		key    []byte
+		length int
+	}{
+		{"SK_d", ikesaKey.SK_d, 20},
+		{"SK_ai", ikesaKey.SK_ai, 21},
+		{"SK_ar", ikesaKey.SK_ar, 21},
+		{"SK_ei", ikesaKey.SK_ei, 17},
+		{"SK_er", ikesaKey.SK_er, 17},
+		{"SK_pi", ikesaKey.SK_pi, 20},
+		{"SK_pr", ikesaKey.SK_pr, 20},
+	} {
+		require.Len(t, key.key, key.length, key.name)
+		require.Equal(t, key.length, cap(key.key), key.name)
+		require.Equal(t, byte(offset), key.key[0], key.name)
+		require.Equal(t, byte(offset+key.length-1), key.key[key.length-1], key.name)
+		offset += key.length
+	}
+	require.Equal(t, kdf.length, offset)
+
+	// Growing a key does not overwrite the next one
+	_ = append(ikesaKey.SK_d, 0xff)
+	require.Equal(t, byte(20), ikesaKey.SK_ai[0])
+}
@@ -0,0 +1,160 @@
+package security
+
+import (
+	"hash"
+
+	"github.com/pkg/errors"
+
+	ikeCrypto "github.com/nathaniel-bennett/ike/security/IKECrypto"
+	"github.com/nathaniel-bennett/ike/security/encr"
+	"github.com/nathaniel-bennett/ike/security/integ"
+	"github.com/nathaniel-bennett/ike/security/prf"
+)
+
+// KeyHandle identifies a key held by a KeyStore
+type KeyHandle string
+
+// KeyStore stores the keys derived for an IKE SA outside of the process
+// memory, e.g. in an HSM, and builds the security objects of the IKE SA from
+// the handles referring to them.
+type KeyStore interface {
+	// Store stores key under label and returns the handle referring to it
+	Store(label string, key []byte) (KeyHandle, error)
+	// Delete destroys the key referred to by handle
+	Delete(handle KeyHandle) error
+	// NewPRF returns prfType keyed by the key referred to by handle
+	NewPRF(handle KeyHandle, prfType prf.PRFType) (hash.Hash, error)
+	// NewInteg returns integType keyed by the key referred to by handle
+	NewInteg(handle KeyHandle, integType integ.INTEGType) (hash.Hash, error)
+	// NewCrypto returns encrType keyed by the key referred to by handle
+	NewCrypto(handle KeyHandle, encrType encr.ENCRType) (ikeCrypto.IKECrypto, error)
+}
+
+// Labels the IKE SA keys are stored under
+const (
+	KeyLabelSK_d  = "SK_d"
+	KeyLabelSK_ai = "SK_ai"
+	KeyLabelSK_ar = "SK_ar"
+	KeyLabelSK_ei = "SK_ei"
+	KeyLabelSK_er = "SK_er"
+	KeyLabelSK_pi = "SK_pi"
+	KeyLabelSK_pr = "SK_pr"
+)
+
+// storeKeys stores every key of the IKE SA in keyStore, sets up the security
+// objects from the returned handles, then wipes the keys so that only their
+// handles are left. Empty keys, such as SK_ai and SK_ar with an AEAD cipher,
+// are not stored. On error, the keys already stored are left to Wipe to
+// delete.
+func (ikesaKey *IKESAKey) storeKeys(keyStore KeyStore) error {
+	ikesaKey.keyStore = keyStore
+	ikesaKey.KeyHandles = make(map[string]KeyHandle)
+	for _, key := range []struct {
+		label string
+		key   []byte
+	}{
+		{KeyLabelSK_d, ikesaKey.SK_d},
+		{KeyLabelSK_ai, ikesaKey.SK_ai},
+		{KeyLabelSK_ar, ikesaKey.SK_ar},
+		{KeyLabelSK_ei, ikesaKey.SK_ei},
+		{KeyLabelSK_er, ikesaKey.SK_er},
+		{KeyLabelSK_pi, ikesaKey.SK_pi},
+		{KeyLabelSK_pr, ikesaKey.SK_pr},
+	} {
+		if len(key.key) == 0 {
+			continue
+		}
+		handle, err := keyStore.Store(key.label, key.key)
+		if err != nil {
+			return err
+		}
+		ikesaKey.KeyHandles[key.label] = handle
+	}
+
+	var err error
+	handles := ikesaKey.KeyHandles
+	if ikesaKey.Prf_d, err = keyStore.NewPRF(handles[KeyLabelSK_d], ikesaKey.PrfInfo); err != nil {
+		return err
+	}
+	if !ikesaKey.EncrInfo.IsAEAD() {
+		if ikesaKey.Integ_i, err = keyStore.NewInteg(handles[KeyLabelSK_ai], ikesaKey.IntegInfo); err != nil {
+			return err
+		}
+		if ikesaKey.Integ_r, err = keyStore.NewInteg(handles[KeyLabelSK_ar], ikesaKey.IntegInfo); err != nil {
+			return err
+		}
+	}
+	if ikesaKey.Encr_i, err = keyStore.NewCrypto(handles[KeyLabelSK_ei], ikesaKey.EncrInfo); err != nil {
+		return err
+	}
+	if ikesaKey.Encr_r, err = keyStore.NewCrypto(handles[KeyLabelSK_er], ikesaKey.EncrInfo); err != nil {
+		return err
+	}
+	if ikesaKey.Prf_i, err = keyStore.NewPRF(handles[KeyLabelSK_pi], ikesaKey.PrfInfo); err != nil {
+		return err
+	}
+	if ikesaKey.Prf_r, err = keyStore.NewPRF(handles[KeyLabelSK_pr], ikesaKey.PrfInfo); err != nil {
+		return err
+	}
+
+	ikesaKey.wipeKeys()
+	return nil
+}
+
+// replaceStoredSKd stores skd as the new SK_d of the IKE SA, sets up Prf_d
+// from it and deletes the previous SK_d from the key store. skd is wiped.
+func (ikesaKey *IKESAKey) replaceStoredSKd(skd []byte) error {
+	defer wipeBytes(skd)
+
+	handle, err := ikesaKey.keyStore.Store(KeyLabelSK_d, skd)
+	if err != nil {
+		return err
+	}
+	prfD, err := ikesaKey.keyStore.NewPRF(handle, ikesaKey.PrfInfo)
+	if err != nil {
+		_ = ikesaKey.keyStore.Delete(handle)
+		return err
+	}
+	if err = ikesaKey.keyStore.Delete(ikesaKey.KeyHandles[KeyLabelSK_d]); err != nil {
+		_ = ikesaKey.keyStore.Delete(handle)
+		return err
+	}
+	ikesaKey.KeyHandles[KeyLabelSK_d] = handle
+	ikesaKey.Prf_d = prfD
+	return nil
+}
+
+// keyedPRF returns the PRF of the IKE SA keyed by the key of label, built by
+// the key store holding it if any, or else by newPRF from key. It returns
+// an error if the IKE SA has no such key.
+func (ikesaKey *IKESAKey) keyedPRF(
+	label string,
+	key []byte,
+	newPRF func(prf.PRFType, []byte) hash.Hash,
+) (hash.Hash, error) {
+	if handle, ok := ikesaKey.KeyHandles[label]; ok && ikesaKey.keyStore != nil {
+		return ikesaKey.keyStore.NewPRF(handle, ikesaKey.PrfInfo)
+	}
+	if len(key) == 0 {
+		return nil, errors.Errorf("No %s", label)
+	}
+	return newPRF(ikesaKey.PrfInfo, key), nil
+}
+
+// hasSKd reports whether the IKE SA holds an SK_d, either itself or in a key
+// store
+func (ikesaKey *IKESAKey) hasSKd() bool {
+	_, stored := ikesaKey.KeyHandles[KeyLabelSK_d]
+	return len(ikesaKey.SK_d) != 0 || (stored && ikesaKey.keyStore != nil)
+}
+
+// deleteStoredKeys deletes the keys of the IKE SA from its key store. It is
+// best effort: a key the store fails to delete is left behind.
+func (ikesaKey *IKESAKey) deleteStoredKeys() {
+	if ikesaKey.keyStore == nil {
+		return
+	}
+	for _, handle := range ikesaKey.KeyHandles {
+		_ = ikesaKey.keyStore.Delete(handle)
+	}
+}
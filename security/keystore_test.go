@@ -0,0 +1,196 @@
+package security
+
+import (
+	"fmt"
+	"hash"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	ikeCrypto "github.com/nathaniel-bennett/ike/security/IKECrypto"
+	"github.com/nathaniel-bennett/ike/security/encr"
+	"github.com/nathaniel-bennett/ike/security/integ"
+	"github.com/nathaniel-bennett/ike/security/prf"
+)
+
+type mockKeyStore struct {
+	keys   map[KeyHandle][]byte
+	stored int
+	failOn string
+}
+
+func newMockKeyStore() *mockKeyStore {
+	return &mockKeyStore{keys: make(map[KeyHandle][]byte)}
+}
+
+func (s *mockKeyStore) Store(label string, key []byte) (KeyHandle, error) {
+	if label == s.failOn {
+		return "", errors.New("store failed")
+	}
+	s.stored++
+	handle := KeyHandle(fmt.Sprintf("hsm:%s:%d", label, s.stored))
+	s.keys[handle] = append([]byte(nil), key...)
+	return handle, nil
+}
+
+func (s *mockKeyStore) Delete(handle KeyHandle) error {
+	if _, ok := s.keys[handle]; !ok {
+		return errors.New("unknown handle")
+	}
+	delete(s.keys, handle)
+	return nil
+}
+
+func (s *mockKeyStore) key(handle KeyHandle) ([]byte, error) {
+	key, ok := s.keys[handle]
+	if !ok {
+		return nil, errors.New("unknown handle")
+	}
+	return key, nil
+}
+
+func (s *mockKeyStore) NewPRF(handle KeyHandle, prfType prf.PRFType) (hash.Hash, error) {
+	key, err := s.key(handle)
+	if err != nil {
+		return nil, err
+	}
+	return prfType.Init(key), nil
+}
+
+func (s *mockKeyStore) NewInteg(handle KeyHandle, integType integ.INTEGType) (hash.Hash, error) {
+	key, err := s.key(handle)
+	if err != nil {
+		return nil, err
+	}
+	return integType.Init(key), nil
+}
+
+func (s *mockKeyStore) NewCrypto(handle KeyHandle, encrType encr.ENCRType) (ikeCrypto.IKECrypto, error) {
+	key, err := s.key(handle)
+	if err != nil {
+		return nil, err
+	}
+	return encrType.NewCrypto(key)
+}
+
+func TestGenerateKeyForIKESAWithKeyStore(t *testing.T) {
+	// No key store by default
+	standard := newTestLabelIKESAKey(t)
+	require.Nil(t, standard.KeyHandles)
+
+	keyStore := newMockKeyStore()
+	ikesaKey := newTestLabelIKESAKey(t, WithKeyStore(keyStore))
+	require.Len(t, ikesaKey.KeyHandles, 7)
+	require.Len(t, keyStore.keys, 7)
+
+	for label, key := range map[string][]byte{
+		KeyLabelSK_d:  standard.SK_d,
+		KeyLabelSK_ai: standard.SK_ai,
+		KeyLabelSK_ar: standard.SK_ar,
+		KeyLabelSK_ei: standard.SK_ei,
+		KeyLabelSK_er: standard.SK_er,
+		KeyLabelSK_pi: standard.SK_pi,
+		KeyLabelSK_pr: standard.SK_pr,
+	} {
+		handle, ok := ikesaKey.KeyHandles[label]
+		require.True(t, ok, label)
+		require.Equal(t, key, keyStore.keys[handle], label)
+	}
+
+	// Only the handles are left, and the security objects built from them
+	// match the standard ones
+	for _, key := range [][]byte{
+		ikesaKey.SK_d, ikesaKey.SK_ai, ikesaKey.SK_ar, ikesaKey.SK_ei,
+		ikesaKey.SK_er, ikesaKey.SK_pi, ikesaKey.SK_pr,
+	} {
+		require.Nil(t, key)
+	}
+	for _, pair := range [][2]hash.Hash{
+		{standard.Prf_d, ikesaKey.Prf_d},
+		{standard.Integ_i, ikesaKey.Integ_i},
+		{standard.Integ_r, ikesaKey.Integ_r},
+		{standard.Prf_i, ikesaKey.Prf_i},
+		{standard.Prf_r, ikesaKey.Prf_r},
+	} {
+		require.Equal(t, pair[0].Sum(nil), pair[1].Sum(nil))
+	}
+	cipherText, err := ikesaKey.Encr_i.Encrypt([]byte("IKE payloads"))
+	require.NoError(t, err)
+	plainText, err := standard.Encr_i.Decrypt(cipherText)
+	require.NoError(t, err)
+	require.Equal(t, []byte("IKE payloads"), plainText)
+
+	// Child SA keys and EAP AUTH without MSK are derived through the key
+	// store
+	childsaKey := &ChildSAKey{EncrKInfo: encr.StrToKType("ENCR_AES_CBC_256")}
+	require.NoError(t, childsaKey.GenerateKeyForChildSA(ikesaKey, []byte{0x01, 0x02}))
+	standardChildsaKey := &ChildSAKey{EncrKInfo: encr.StrToKType("ENCR_AES_CBC_256")}
+	require.NoError(t, standardChildsaKey.GenerateKeyForChildSA(standard, []byte{0x01, 0x02}))
+	require.Equal(t, standardChildsaKey.InitiatorToResponderEncryptionKey,
+		childsaKey.InitiatorToResponderEncryptionKey)
+
+	auth, err := ikesaKey.ComputeEAPAuth(nil, []byte("signed octets"), true)
+	require.NoError(t, err)
+	standardAuth, err := standard.ComputeEAPAuth(nil, []byte("signed octets"), true)
+	require.NoError(t, err)
+	require.Equal(t, standardAuth, auth)
+
+	// Wipe deletes the keys from the key store
+	ikesaKey.Wipe()
+	require.Nil(t, ikesaKey.KeyHandles)
+	require.Empty(t, keyStore.keys)
+
+	// A store failure fails the derivation, and deletes the keys already
+	// stored
+	keyStore = newMockKeyStore()
+	keyStore.failOn = KeyLabelSK_er
+	ikesaKey = &IKESAKey{
+		DhInfo:    standard.DhInfo,
+		EncrInfo:  standard.EncrInfo,
+		IntegInfo: standard.IntegInfo,
+		PrfInfo:   standard.PrfInfo,
+	}
+	err = ikesaKey.GenerateKeyForIKESA([]byte{0x01, 0x02, 0x03, 0x04},
+		[]byte{0x05, 0x06, 0x07, 0x08}, 0x456, 0x123, WithKeyStore(keyStore))
+	require.Error(t, err)
+	require.Nil(t, ikesaKey.KeyHandles)
+	require.Nil(t, ikesaKey.SK_d)
+	require.Empty(t, keyStore.keys)
+}
+
+func TestKeyStoreRekeyAndBindTranscript(t *testing.T) {
+	proposal := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA2_256_128")
+	nonce := []byte{0x01, 0x02, 0x03, 0x04}
+
+	keyStore := newMockKeyStore()
+	ikesaKey, _, err := NewIKESAKey(proposal, []byte{0x05, 0x06, 0x07, 0x08}, nonce, 0x123, 0x456,
+		WithKeyStore(keyStore))
+	require.NoError(t, err)
+
+	// The rekeyed IKE SA keeps its keys in the same key store
+	rekeyed, _, err := ikesaKey.RekeyIKESAKey(proposal, []byte{0x09, 0x0a}, nonce, 0x789, 0xabc)
+	require.NoError(t, err)
+	require.Nil(t, rekeyed.SK_d)
+	require.Len(t, rekeyed.KeyHandles, 7)
+	require.Len(t, keyStore.keys, 14)
+
+	// Binding the transcript replaces SK_d in the key store
+	transcriptHash := []byte{0x0a, 0x0b}
+	oldHandle := rekeyed.KeyHandles[KeyLabelSK_d]
+	expected := rekeyed.PrfInfo.Init(keyStore.keys[oldHandle])
+	_, err = expected.Write(transcriptHash)
+	require.NoError(t, err)
+	expectedSK_d := expected.Sum(nil)
+
+	require.NoError(t, rekeyed.BindTranscript(transcriptHash))
+	require.Nil(t, rekeyed.SK_d)
+	require.NotContains(t, keyStore.keys, oldHandle)
+	require.Equal(t, expectedSK_d, keyStore.keys[rekeyed.KeyHandles[KeyLabelSK_d]])
+	require.Equal(t, rekeyed.PrfInfo.Init(expectedSK_d).Sum(nil), rekeyed.Prf_d.Sum(nil))
+	require.Len(t, keyStore.keys, 14)
+
+	ikesaKey.Wipe()
+	rekeyed.Wipe()
+	require.Empty(t, keyStore.keys)
+}
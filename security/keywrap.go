@@ -0,0 +1,83 @@
+package security
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+var ErrKeyUnwrapFailed = errors.New("key unwrap integrity check failed")
+
+// Default initial value defined in RFC 3394 section 2.2.3.1
+var keyWrapIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// WrapKey wraps key with the key encryption key kek using the AES key wrap
+// algorithm defined in RFC 3394. key must be at least 16 bytes long and a
+// multiple of 8 bytes, and the wrapped key is 8 bytes longer than key.
+func WrapKey(kek, key []byte) ([]byte, error) {
+	if len(key) < 16 || len(key)%8 != 0 {
+		return nil, errors.Errorf("WrapKey : invalid key length %d", len(key))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, errors.Wrapf(err, "WrapKey")
+	}
+
+	n := len(key) / 8
+	wrapped := make([]byte, 8+len(key))
+	copy(wrapped[8:], key)
+
+	var b [aes.BlockSize]byte
+	copy(b[:8], keyWrapIV)
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(b[8:], wrapped[i*8:(i+1)*8])
+			block.Encrypt(b[:], b[:])
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(b[:8])^t)
+			copy(wrapped[i*8:(i+1)*8], b[8:])
+		}
+	}
+	copy(wrapped[:8], b[:8])
+
+	return wrapped, nil
+}
+
+// UnwrapKey unwraps wrapped with the key encryption key kek using the AES key
+// unwrap algorithm defined in RFC 3394, and returns ErrKeyUnwrapFailed if the
+// integrity check fails.
+func UnwrapKey(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, errors.Errorf("UnwrapKey : invalid wrapped key length %d", len(wrapped))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, errors.Wrapf(err, "UnwrapKey")
+	}
+
+	n := len(wrapped)/8 - 1
+	key := make([]byte, len(wrapped)-8)
+	copy(key, wrapped[8:])
+
+	var b [aes.BlockSize]byte
+	copy(b[:8], wrapped[:8])
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(b[:8])^t)
+			copy(b[8:], key[(i-1)*8:i*8])
+			block.Decrypt(b[:], b[:])
+			copy(key[(i-1)*8:i*8], b[8:])
+		}
+	}
+
+	if subtle.ConstantTimeCompare(b[:8], keyWrapIV) != 1 {
+		for i := range key {
+			key[i] = 0
+		}
+		return nil, errors.WithStack(ErrKeyUnwrapFailed)
+	}
+	return key, nil
+}
@@ -0,0 +1,94 @@
+package security
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyWrap(t *testing.T) {
+	// RFC 3394 section 4
+	testcases := []struct {
+		name    string
+		kek     string
+		key     string
+		wrapped string
+	}{
+		{
+			"128 bits of key data with a 128-bit KEK",
+			"000102030405060708090A0B0C0D0E0F",
+			"00112233445566778899AABBCCDDEEFF",
+			"1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5",
+		},
+		{
+			"128 bits of key data with a 192-bit KEK",
+			"000102030405060708090A0B0C0D0E0F1011121314151617",
+			"00112233445566778899AABBCCDDEEFF",
+			"96778B25AE6CA435F92B5B97C050AED2468AB8A17AD84E5D",
+		},
+		{
+			"128 bits of key data with a 256-bit KEK",
+			"000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F",
+			"00112233445566778899AABBCCDDEEFF",
+			"64E8C3F9CE0F5BA263E9777905818A2A93C8191E7D6E8AE7",
+		},
+		{
+			"192 bits of key data with a 192-bit KEK",
+			"000102030405060708090A0B0C0D0E0F1011121314151617",
+			"00112233445566778899AABBCCDDEEFF0001020304050607",
+			"031D33264E15D33268F24EC260743EDCE1C6C7DDEE725A936BA814915C6762D2",
+		},
+		{
+			"192 bits of key data with a 256-bit KEK",
+			"000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F",
+			"00112233445566778899AABBCCDDEEFF0001020304050607",
+			"A8F9BC1612C68B3FF6E6F4FBE30E71E4769C8B80A32CB8958CD5D17D6B254DA1",
+		},
+		{
+			"256 bits of key data with a 256-bit KEK",
+			"000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F",
+			"00112233445566778899AABBCCDDEEFF000102030405060708090A0B0C0D0E0F",
+			"28C9F404C4B810F4CBCCB35CFB87F8263F5786E2D80ED326CBC7F0E71A99F43BFB988B9B7A02DD21",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			kek, err := hex.DecodeString(tc.kek)
+			require.NoError(t, err)
+			key, err := hex.DecodeString(tc.key)
+			require.NoError(t, err)
+			expected, err := hex.DecodeString(tc.wrapped)
+			require.NoError(t, err)
+
+			wrapped, err := WrapKey(kek, key)
+			require.NoError(t, err)
+			require.Equal(t, expected, wrapped)
+
+			unwrapped, err := UnwrapKey(kek, wrapped)
+			require.NoError(t, err)
+			require.Equal(t, key, unwrapped)
+
+			wrapped[len(wrapped)-1] ^= 0x01
+			_, err = UnwrapKey(kek, wrapped)
+			require.ErrorIs(t, err, ErrKeyUnwrapFailed)
+		})
+	}
+}
+
+func TestKeyWrapInvalidLength(t *testing.T) {
+	kek := make([]byte, 16)
+
+	_, err := WrapKey(kek, make([]byte, 8))
+	require.Error(t, err)
+	_, err = WrapKey(kek, make([]byte, 20))
+	require.Error(t, err)
+	_, err = WrapKey(make([]byte, 5), make([]byte, 16))
+	require.Error(t, err)
+
+	_, err = UnwrapKey(kek, make([]byte, 16))
+	require.Error(t, err)
+	_, err = UnwrapKey(kek, make([]byte, 25))
+	require.Error(t, err)
+}
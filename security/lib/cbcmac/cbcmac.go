@@ -0,0 +1,92 @@
+// Package cbcmac implements the CBC-MAC shared by AES-CMAC, RFC 4493, and
+// AES-XCBC-MAC, RFC 3566: the blocks are chained with the cipher, and the
+// last one is XORed with a subkey before being encrypted, one subkey for a
+// complete block and another for a block padded with 10*. The two MACs only
+// differ in how the cipher and the subkeys are derived from their key.
+package cbcmac
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"hash"
+)
+
+// Size is the size of the MAC, a 128-bit block
+const Size = aes.BlockSize
+
+var _ hash.Hash = &digest{}
+
+// digest holds the chaining value and the last block written, which is only
+// processed by Sum since it is the one XORed with a subkey
+type digest struct {
+	block             cipher.Block
+	complete, partial [Size]byte
+	x                 [Size]byte
+	buf               [Size]byte
+	n                 int
+}
+
+// New returns a hash computing the CBC-MAC of block, a 128-bit block cipher.
+// The last block is XORed with complete if it is full, and padded then XORed
+// with partial otherwise. Sum appends the full Size bytes output.
+func New(block cipher.Block, complete, partial []byte) hash.Hash {
+	d := &digest{block: block}
+	copy(d.complete[:], complete)
+	copy(d.partial[:], partial)
+	return d
+}
+
+func (d *digest) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		// A full buffered block is only processed once more data follows
+		if d.n == Size {
+			xorBlock(d.x[:], d.x[:], d.buf[:])
+			d.block.Encrypt(d.x[:], d.x[:])
+			d.n = 0
+		}
+		copied := copy(d.buf[d.n:], p)
+		d.n += copied
+		p = p[copied:]
+	}
+	return written, nil
+}
+
+// Sum appends the MAC of the data written so far to in, without changing the
+// state of the hash
+func (d *digest) Sum(in []byte) []byte {
+	var last [Size]byte
+	copy(last[:], d.buf[:d.n])
+	if d.n == Size {
+		xorBlock(last[:], last[:], d.complete[:])
+	} else {
+		last[d.n] = 0x80
+		xorBlock(last[:], last[:], d.partial[:])
+	}
+
+	var mac [Size]byte
+	xorBlock(mac[:], d.x[:], last[:])
+	d.block.Encrypt(mac[:], mac[:])
+	return append(in, mac[:]...)
+}
+
+func (d *digest) Reset() {
+	d.x = [Size]byte{}
+	d.buf = [Size]byte{}
+	d.n = 0
+}
+
+func (d *digest) Size() int {
+	return Size
+}
+
+func (d *digest) BlockSize() int {
+	return Size
+}
+
+// xorBlock sets dst to a XOR b, all being blocks
+func xorBlock(dst, a, b []byte) {
+	for i := 0; i < Size; i++ {
+		dst[i] = a[i] ^ b[i]
+	}
+}
@@ -0,0 +1,44 @@
+package cbcmac
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCBCMAC(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	require.NoError(t, err)
+	message := make([]byte, 3*Size)
+	for i := range message {
+		message[i] = byte(i)
+	}
+
+	// With zero subkeys and complete blocks, the MAC is the last block of
+	// the CBC encryption under a zero IV
+	cipherText := make([]byte, len(message))
+	cipher.NewCBCEncrypter(block, make([]byte, Size)).CryptBlocks(cipherText, message)
+	h := New(block, make([]byte, Size), make([]byte, Size))
+	_, err = h.Write(message)
+	require.NoError(t, err)
+	require.Equal(t, cipherText[2*Size:], h.Sum(nil))
+
+	// A partial last block is padded with 10* and XORed with the partial
+	// subkey, here the padding itself so that they cancel out
+	partial := make([]byte, Size)
+	partial[Size-1] = 0x80
+	h = New(block, make([]byte, Size), partial)
+	_, err = h.Write(message[:3*Size-1])
+	require.NoError(t, err)
+	padded := append(append([]byte(nil), message[:3*Size-1]...), 0)
+	cipher.NewCBCEncrypter(block, make([]byte, Size)).CryptBlocks(cipherText, padded)
+	require.Equal(t, cipherText[2*Size:], h.Sum(nil))
+
+	// Reset starts a new MAC
+	h.Reset()
+	_, err = h.Write(message[:3*Size-1])
+	require.NoError(t, err)
+	require.Equal(t, cipherText[2*Size:], h.Sum(nil))
+}
@@ -0,0 +1,81 @@
+// Package cmac implements the AES-CMAC algorithm, as defined in RFC 4493,
+// and the AES-CMAC-PRF-128 pseudorandom function built on it in RFC 4615.
+//
+// The MAC is exposed as a hash.Hash, so that it can be used wherever an HMAC
+// is, such as the integrity objects of an IKE SA or prf+.
+package cmac
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"hash"
+
+	"github.com/pkg/errors"
+
+	"github.com/nathaniel-bennett/ike/security/lib/cbcmac"
+)
+
+const (
+	// KeySize is the size of the AES-CMAC key
+	KeySize = 16
+	// Size is the size of the full AES-CMAC output, which AES-CMAC-96
+	// truncates to 12 bytes
+	Size = cbcmac.Size
+)
+
+// Rb of RFC 4493 section 2.3, for a 128-bit block
+const rb = 0x87
+
+// New returns a hash computing the AES-CMAC of RFC 4493 keyed by key, which
+// must be KeySize bytes long. Sum appends the full Size bytes output.
+func New(key []byte) (hash.Hash, error) {
+	if len(key) != KeySize {
+		return nil, errors.Errorf("cmac: bad key length %d, expected %d", len(key), KeySize)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cmac")
+	}
+
+	k1, k2 := subkeys(block)
+	return cbcmac.New(block, k1[:], k2[:]), nil
+}
+
+// NewPRF returns a hash computing AES-CMAC-PRF-128, RFC 4615 section 3. The
+// key may have any length: a key that is not KeySize bytes long is replaced
+// by its AES-CMAC under the all-zero key.
+func NewPRF(key []byte) (hash.Hash, error) {
+	if len(key) == KeySize {
+		return New(key)
+	}
+
+	h, err := New(make([]byte, KeySize))
+	if err != nil {
+		return nil, err
+	}
+	if _, err = h.Write(key); err != nil {
+		return nil, errors.Wrapf(err, "cmac")
+	}
+	return New(h.Sum(nil))
+}
+
+// subkeys returns K1 and K2 of RFC 4493 section 2.3: L = AES-128(K, 0),
+// K1 = L << 1 and K2 = K1 << 1, each XORed with Rb when the shifted out bit
+// is set
+func subkeys(block cipher.Block) (k1, k2 [aes.BlockSize]byte) {
+	var l [aes.BlockSize]byte
+	block.Encrypt(l[:], l[:])
+	double(k1[:], l[:])
+	double(k2[:], k1[:])
+	return k1, k2
+}
+
+// double sets dst to the doubling of src in GF(2^128)
+func double(dst, src []byte) {
+	msb := src[0] >> 7
+	for i := 0; i < aes.BlockSize-1; i++ {
+		dst[i] = src[i]<<1 | src[i+1]>>7
+	}
+	dst[aes.BlockSize-1] = src[aes.BlockSize-1]<<1 ^ rb*msb
+}
@@ -0,0 +1,84 @@
+package cmac
+
+import (
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+func TestSubkeys(t *testing.T) {
+	// RFC 4493 section 4, subkey generation
+	block, err := aes.NewCipher(decode(t, "2b7e151628aed2a6abf7158809cf4f3c"))
+	require.NoError(t, err)
+	k1, k2 := subkeys(block)
+	require.Equal(t, decode(t, "fbeed618357133667c85e08f7236a8de"), k1[:])
+	require.Equal(t, decode(t, "f7ddac306ae266ccf90bc11ee46d513b"), k2[:])
+}
+
+func TestMAC(t *testing.T) {
+	// Examples 1 to 4 of RFC 4493 section 4
+	message := decode(t, "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e51"+
+		"30c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710")
+	testcases := []struct {
+		length int
+		mac    string
+	}{
+		{0, "bb1d6929e95937287fa37d129b756746"},
+		{16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{40, "dfa66747de9ae63030ca32611497c827"},
+		{64, "51f0bebf7e3b9d92fc49741779363cfe"},
+	}
+
+	h, err := New(decode(t, "2b7e151628aed2a6abf7158809cf4f3c"))
+	require.NoError(t, err)
+	for i, tc := range testcases {
+		expected := decode(t, tc.mac)
+
+		h.Reset()
+		_, err = h.Write(message[:tc.length])
+		require.NoError(t, err)
+		require.Equal(t, expected, h.Sum(nil), "example %d", i+1)
+		// Sum does not change the state
+		require.Equal(t, expected, h.Sum(nil), "example %d", i+1)
+
+		// Writing byte by byte gives the same MAC
+		h.Reset()
+		for _, b := range message[:tc.length] {
+			_, err = h.Write([]byte{b})
+			require.NoError(t, err)
+		}
+		require.Equal(t, expected, h.Sum(nil), "example %d", i+1)
+	}
+
+	_, err = New(make([]byte, 20))
+	require.Error(t, err)
+}
+
+func TestPRF(t *testing.T) {
+	// Test vectors of RFC 4615 section 4, with the message 00010203...13
+	message := decode(t, "000102030405060708090a0b0c0d0e0f10111213")
+	testcases := []struct {
+		key string
+		prf string
+	}{
+		{"000102030405060708090a0b0c0d0e0fedcb", "84a348a4a45d235babfffc0d2b4da09a"},
+		{"000102030405060708090a0b0c0d0e0f", "980ae87b5f4c9c5214f5b6a8455e4c2d"},
+		{"00010203040506070809", "290d9e112edb09ee141fcf64c0b72f3d"},
+	}
+
+	for i, tc := range testcases {
+		h, err := NewPRF(decode(t, tc.key))
+		require.NoError(t, err)
+		_, err = h.Write(message)
+		require.NoError(t, err)
+		require.Equal(t, decode(t, tc.prf), h.Sum(nil), "test case %d", i+1)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"hash"
 	"math"
+	"math/big"
 
 	"github.com/pkg/errors"
 )
@@ -28,7 +29,60 @@ func PKCS7Padding(plainText []byte, blockSize int) ([]byte, error) {
 	return append(plainText, paddingText...), nil
 }
 
+// Maximum padding length of the Encrypted payload, including the pad length
+// byte, which holds at most 255
+const maxPaddingLength = 256
+
+// RandomLengthPadding pads plainText like PKCS7Padding, then adds a random
+// number of extra blocks of random padding, keeping the padding, pad length
+// byte included, within maxPadding bytes. It hides the length of the
+// plaintext for traffic flow confidentiality. maxPadding is capped to 256,
+// and the minimal padding is used when it leaves no room for an extra block.
+func RandomLengthPadding(plainText []byte, blockSize, maxPadding int) ([]byte, error) {
+	padding := blockSize - (len(plainText) % blockSize)
+	if padding == 0 {
+		padding = blockSize
+	}
+	if maxPadding > maxPaddingLength {
+		maxPadding = maxPaddingLength
+	}
+
+	if maxExtraBlocks := (maxPadding - padding) / blockSize; maxExtraBlocks > 0 {
+		extraBlocks, err := rand.Int(rand.Reader, big.NewInt(int64(maxExtraBlocks+1)))
+		if err != nil {
+			return nil, errors.Wrapf(err, "RandomLengthPadding()")
+		}
+		padding += int(extraBlocks.Int64()) * blockSize
+	}
+
+	paddingText := make([]byte, padding)
+	if _, err := rand.Read(paddingText); err != nil {
+		return nil, errors.Wrapf(err, "RandomLengthPadding()")
+	}
+	paddingText[len(paddingText)-1] = byte(padding - 1)
+	return append(plainText, paddingText...), nil
+}
+
+// Maximum number of prf+ iterations, the counter being a single octet
+const maxPrfPlusIterations = 255
+
+// PrfPlusIterations returns the number of PRF invocations prf+ needs to
+// produce totalLength bytes with a PRF of prfOutputLen bytes output
+func PrfPlusIterations(prfOutputLen, totalLength int) int {
+	if prfOutputLen <= 0 || totalLength <= 0 {
+		return 0
+	}
+	return (totalLength + prfOutputLen - 1) / prfOutputLen
+}
+
+// PrfPlus returns streamLen bytes of prf+ as defined in RFC 7296 section
+// 2.13, or nil if it would take more than 255 iterations, where the counter
+// octet would wrap
 func PrfPlus(prf hash.Hash, s []byte, streamLen int) []byte {
+	if PrfPlusIterations(prf.Size(), streamLen) > maxPrfPlusIterations {
+		return nil
+	}
+
 	var stream, block []byte
 	for i := 1; len(stream) < streamLen; i++ {
 		prf.Reset()
@@ -0,0 +1,61 @@
+package lib
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrfPlusIterations(t *testing.T) {
+	testcases := []struct {
+		prfOutputLen int
+		totalLength  int
+		iterations   int
+	}{
+		{32, 1, 1},
+		{32, 31, 1},
+		{32, 32, 1},
+		{32, 33, 2},
+		{20, 100, 5},
+		{20, 101, 6},
+		{32, 255 * 32, 255},
+		{32, 0, 0},
+		{0, 32, 0},
+	}
+
+	for _, tc := range testcases {
+		require.Equal(t, tc.iterations, PrfPlusIterations(tc.prfOutputLen, tc.totalLength),
+			"%d bytes from %d bytes output", tc.totalLength, tc.prfOutputLen)
+	}
+}
+
+func TestPrfPlusSHA512(t *testing.T) {
+	prf := hmac.New(sha512.New, []byte("key"))
+
+	// T1 | T2 with T1 = prf(K, S | 0x01) and T2 = prf(K, T1 | S | 0x02)
+	expected, err := hex.DecodeString("173a6224816997fa5b2129f1c4d26d629028846663e6e27dd28d35d0de375705" +
+		"6e7da6c4214436a07fa827ba4329baf9097b2eddf4c1ea2b89caf65189987801" +
+		"bbb7a7125bce5d65d77bfd4a0705ef9bcb45f76a33f0ed70f5f7ff0f53eaa8c0089d68f8")
+	require.NoError(t, err)
+
+	stream := PrfPlus(prf, []byte("seed"), 100)
+	require.Len(t, stream, 100)
+	require.Equal(t, expected, stream)
+
+	// A shorter stream is a prefix of the longer one
+	require.Equal(t, expected[:64], PrfPlus(prf, []byte("seed"), 64))
+	require.Equal(t, expected[:10], PrfPlus(prf, []byte("seed"), 10))
+}
+
+func TestPrfPlusIterationLimit(t *testing.T) {
+	prf := hmac.New(sha256.New, []byte("key"))
+
+	stream := PrfPlus(prf, []byte("seed"), 255*32)
+	require.Len(t, stream, 255*32)
+
+	require.Nil(t, PrfPlus(prf, []byte("seed"), 255*32+1))
+}
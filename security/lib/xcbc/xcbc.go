@@ -0,0 +1,72 @@
+// Package xcbc implements the AES-XCBC-MAC algorithm, as defined in RFC 3566,
+// and the AES-XCBC-PRF-128 pseudorandom function built on it in RFC 4434.
+//
+// The MAC is exposed as a hash.Hash, so that it can be used wherever an HMAC
+// is, such as the integrity objects of an IKE SA or prf+.
+package xcbc
+
+import (
+	"crypto/aes"
+	"hash"
+
+	"github.com/pkg/errors"
+
+	"github.com/nathaniel-bennett/ike/security/lib/cbcmac"
+)
+
+const (
+	// KeySize is the size of the AES-XCBC-MAC key
+	KeySize = 16
+	// Size is the size of the full AES-XCBC-MAC output, which AES-XCBC-MAC-96
+	// truncates to 12 bytes
+	Size = cbcmac.Size
+)
+
+// New returns a hash computing the AES-XCBC-MAC of RFC 3566 keyed by key,
+// which must be KeySize bytes long. Sum appends the full Size bytes output.
+func New(key []byte) (hash.Hash, error) {
+	if len(key) != KeySize {
+		return nil, errors.Errorf("xcbc: bad key length %d, expected %d", len(key), KeySize)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "xcbc")
+	}
+
+	// K1 = 0x01010101..., K2 = 0x02020202..., K3 = 0x03030303... encrypted
+	// with the key. The blocks are chained with K1, and the last one is
+	// XORed with K2 or K3.
+	var k1, k2, k3 [aes.BlockSize]byte
+	for i := 0; i < aes.BlockSize; i++ {
+		k1[i], k2[i], k3[i] = 0x01, 0x02, 0x03
+	}
+	block.Encrypt(k1[:], k1[:])
+	block.Encrypt(k2[:], k2[:])
+	block.Encrypt(k3[:], k3[:])
+
+	if block, err = aes.NewCipher(k1[:]); err != nil {
+		return nil, errors.Wrapf(err, "xcbc")
+	}
+	return cbcmac.New(block, k2[:], k3[:]), nil
+}
+
+// NewPRF returns a hash computing AES-XCBC-PRF-128, RFC 4434 section 2. The
+// key may have any length: a shorter key is padded with zeros, and a longer
+// one is replaced by its AES-XCBC-MAC under the all-zero key.
+func NewPRF(key []byte) (hash.Hash, error) {
+	k := make([]byte, KeySize)
+	if len(key) <= KeySize {
+		copy(k, key)
+	} else {
+		h, err := New(k)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = h.Write(key); err != nil {
+			return nil, errors.Wrapf(err, "xcbc")
+		}
+		k = h.Sum(k[:0])
+	}
+	return New(k)
+}
@@ -0,0 +1,82 @@
+package xcbc
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sequence(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+func TestMAC(t *testing.T) {
+	// Test cases 1 to 7 of RFC 3566 section 4.6
+	testcases := []struct {
+		message []byte
+		mac     string
+	}{
+		{nil, "75f0251d528ac01c4573dfd584d79f29"},
+		{sequence(3), "5b376580ae2f19afe7219ceef172756f"},
+		{sequence(16), "d2a246fa349b68a79998a4394ff7a263"},
+		{sequence(20), "47f51b4564966215b8985c63055ed308"},
+		{sequence(32), "f54f0ec8d2b9f3d36807734bd5283fd4"},
+		{sequence(34), "becbb3bccdb518a30677d5481fb6b4d8"},
+		{make([]byte, 1000), "f0dafee895db30253761103b5d84528f"},
+	}
+
+	h, err := New(sequence(KeySize))
+	require.NoError(t, err)
+	for i, tc := range testcases {
+		expected, err := hex.DecodeString(tc.mac)
+		require.NoError(t, err)
+
+		h.Reset()
+		_, err = h.Write(tc.message)
+		require.NoError(t, err)
+		require.Equal(t, expected, h.Sum(nil), "test case %d", i+1)
+		// Sum does not change the state
+		require.Equal(t, expected, h.Sum(nil), "test case %d", i+1)
+
+		// Writing byte by byte gives the same MAC
+		h.Reset()
+		for _, b := range tc.message {
+			_, err = h.Write([]byte{b})
+			require.NoError(t, err)
+		}
+		require.Equal(t, expected, h.Sum(nil), "test case %d", i+1)
+	}
+
+	_, err = New(sequence(20))
+	require.Error(t, err)
+}
+
+func TestPRF(t *testing.T) {
+	// Test cases of RFC 4434 section 5, with the message 00010203...13
+	testcases := []struct {
+		key string
+		prf string
+	}{
+		{"000102030405060708090a0b0c0d0e0f", "47f51b4564966215b8985c63055ed308"},
+		{"00010203040506070809", "0fa087af7d866e7653434e602fdde835"},
+		{"000102030405060708090a0b0c0d0e0fedcb", "8cd3c93ae598a9803006ffb67c40e9e4"},
+	}
+
+	for i, tc := range testcases {
+		key, err := hex.DecodeString(tc.key)
+		require.NoError(t, err)
+		expected, err := hex.DecodeString(tc.prf)
+		require.NoError(t, err)
+
+		h, err := NewPRF(key)
+		require.NoError(t, err)
+		_, err = h.Write(sequence(20))
+		require.NoError(t, err)
+		require.Equal(t, expected, h.Sum(nil), "test case %d", i+1)
+	}
+}
@@ -0,0 +1,46 @@
+package security
+
+import (
+	"time"
+)
+
+// lifetime holds the limits after which an IKE SA must be rekeyed, and the
+// usage counted against them. A zero limit means no limit.
+type lifetime struct {
+	maxBytes    uint64
+	maxDuration time.Duration
+	start       time.Time
+	bytes       uint64
+}
+
+// SetLifetime sets the byte and time lifetimes of the IKE SA, after which
+// NeedsRekey reports that it must be rekeyed. A zero value means no limit,
+// which is the default. The byte count and the clock restart from zero.
+// Lifetime tracking is not safe for concurrent use.
+func (ikesaKey *IKESAKey) SetLifetime(bytes uint64, d time.Duration) {
+	ikesaKey.lifetime = lifetime{
+		maxBytes:    bytes,
+		maxDuration: d,
+		start:       time.Now(),
+	}
+}
+
+// RecordBytes counts n bytes protected by the IKE SA against its byte
+// lifetime
+func (ikesaKey *IKESAKey) RecordBytes(n uint64) {
+	if ikesaKey.lifetime.bytes+n < ikesaKey.lifetime.bytes {
+		ikesaKey.lifetime.bytes = ^uint64(0)
+		return
+	}
+	ikesaKey.lifetime.bytes += n
+}
+
+// NeedsRekey reports whether the IKE SA has reached its byte or time
+// lifetime set by SetLifetime
+func (ikesaKey *IKESAKey) NeedsRekey() bool {
+	l := &ikesaKey.lifetime
+	if l.maxBytes != 0 && l.bytes >= l.maxBytes {
+		return true
+	}
+	return l.maxDuration != 0 && time.Since(l.start) >= l.maxDuration
+}
@@ -0,0 +1,36 @@
+package security
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNeedsRekey(t *testing.T) {
+	// No limit by default
+	ikesaKey := new(IKESAKey)
+	ikesaKey.RecordBytes(1 << 40)
+	require.False(t, ikesaKey.NeedsRekey())
+
+	// Byte lifetime
+	ikesaKey.SetLifetime(1000, 0)
+	ikesaKey.RecordBytes(600)
+	require.False(t, ikesaKey.NeedsRekey())
+	ikesaKey.RecordBytes(399)
+	require.False(t, ikesaKey.NeedsRekey())
+	ikesaKey.RecordBytes(1)
+	require.True(t, ikesaKey.NeedsRekey())
+
+	// The count saturates instead of wrapping around
+	ikesaKey.RecordBytes(^uint64(0))
+	require.True(t, ikesaKey.NeedsRekey())
+
+	// SetLifetime restarts the count
+	ikesaKey.SetLifetime(1000, time.Hour)
+	require.False(t, ikesaKey.NeedsRekey())
+
+	// Time lifetime
+	ikesaKey.lifetime.start = time.Now().Add(-2 * time.Hour)
+	require.True(t, ikesaKey.NeedsRekey())
+}
@@ -0,0 +1,35 @@
+package security
+
+import (
+	"crypto/sha1" // #nosec G505
+	"crypto/subtle"
+	"encoding/binary"
+	"net"
+)
+
+// ComputeNATDetectionHash returns the notification data of a
+// NAT_DETECTION_SOURCE_IP or NAT_DETECTION_DESTINATION_IP notify, as defined
+// in RFC 7296 section 2.23:
+// SHA-1(SPIi | SPIr | IP | Port)
+// The hash is always SHA-1, whatever PRF was negotiated. responderSPI is 0 in
+// the IKE_SA_INIT request.
+func ComputeNATDetectionHash(initiatorSPI, responderSPI uint64, ip net.IP, port uint16) []byte {
+	data := concatenateNonceAndSPI(nil, initiatorSPI, responderSPI)
+	if ipv4 := ip.To4(); ipv4 != nil {
+		data = append(data, ipv4...)
+	} else {
+		data = append(data, ip.To16()...)
+	}
+	portBytes := make([]byte, 2)
+	binary.BigEndian.PutUint16(portBytes, port)
+	data = append(data, portBytes...)
+
+	hash := sha1.Sum(data) // #nosec G401
+	return hash[:]
+}
+
+// EqualNATDetectionHash compares a received NAT detection hash with a
+// computed one in constant time.
+func EqualNATDetectionHash(received, computed []byte) bool {
+	return subtle.ConstantTimeCompare(received, computed) == 1
+}
@@ -0,0 +1,36 @@
+package security
+
+import (
+	"encoding/hex"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeNATDetectionHash(t *testing.T) {
+	expected, err := hex.DecodeString("d798d986143f878f70765e0e869c80bbc375f701")
+	require.NoError(t, err)
+
+	hash := ComputeNATDetectionHash(0x0102030405060708, 0x1112131415161718,
+		net.ParseIP("192.0.2.1"), 500)
+	require.Equal(t, expected, hash)
+	require.True(t, EqualNATDetectionHash(expected, hash))
+
+	// The 4 bytes form of an IPv4 address gives the same hash
+	hash = ComputeNATDetectionHash(0x0102030405060708, 0x1112131415161718,
+		net.IPv4(192, 0, 2, 1).To4(), 500)
+	require.Equal(t, expected, hash)
+
+	// Another port behind a NAT
+	hash = ComputeNATDetectionHash(0x0102030405060708, 0x1112131415161718,
+		net.ParseIP("192.0.2.1"), 4500)
+	require.False(t, EqualNATDetectionHash(expected, hash))
+	require.False(t, EqualNATDetectionHash(expected, expected[:10]))
+
+	// IPv6 in the IKE_SA_INIT request, where SPIr is 0
+	expected, err = hex.DecodeString("98c75483db80168697635aa7844504963e87de33")
+	require.NoError(t, err)
+	hash = ComputeNATDetectionHash(0x0102030405060708, 0, net.ParseIP("2001:db8::1"), 4500)
+	require.Equal(t, expected, hash)
+}
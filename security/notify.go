@@ -0,0 +1,41 @@
+package security
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/nathaniel-bennett/ike/message"
+	"github.com/nathaniel-bennett/ike/security/dh"
+	"github.com/nathaniel-bennett/ike/security/encr"
+)
+
+// NotifyTypeForError returns the notify message type a peer should be sent
+// for an error returned by this package, or 0 if the error has no
+// corresponding error notification.
+func NotifyTypeForError(err error) uint16 {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, ErrAuthenticationFailed),
+		errors.Is(err, ErrInvalidCertificateChain),
+		errors.Is(err, ErrIdentityMismatch):
+		return message.AUTHENTICATION_FAILED
+	case errors.Is(err, ErrNoProposalChosen),
+		errors.Is(err, encr.ErrInsecureAlgorithmRejected),
+		errors.Is(err, encr.ErrInvalidKeyLength),
+		errors.Is(err, ErrMissingESNTransform),
+		errors.Is(err, ErrNoPRF),
+		errors.Is(err, ErrUnsupportedPRF):
+		return message.NO_PROPOSAL_CHOSEN
+	case errors.Is(err, ErrUnsupportedDHGroup):
+		return message.INVALID_KE_PAYLOAD
+	case errors.Is(err, ErrEmptyKeyExchangeData),
+		errors.Is(err, dh.ErrInvalidPublicValue),
+		errors.Is(err, ErrTransformTypeMismatch),
+		errors.Is(err, ErrUnexpectedTransformType),
+		errors.Is(err, ErrInvalidChildSPI),
+		errors.Is(err, message.ErrMalformedTransformChain):
+		return message.INVALID_SYNTAX
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,91 @@
+package security
+
+import (
+	"hash"
+
+	"github.com/nathaniel-bennett/ike/security/prf"
+)
+
+// Option customises the key derivation of GenerateKeyForIKESA, NewIKESAKey,
+// RekeyIKESAKey and GenerateKeyForChildSA, and the proposal decoding of
+// NewChildSAKeyByProposal. Only WithKDF applies to GenerateKeyForChildSA,
+// which expands SK_d with the Prf_d the IKE SA was derived with.
+// Without any option the behavior is the one defined in RFC 7296.
+type Option func(*options)
+
+type options struct {
+	label         []byte
+	permissiveESN bool
+	kdf           KDF
+	keyStore      KeyStore
+	prfFactory    func(key []byte) hash.Hash
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		kdf: PrfPlusKDF,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// initPRF returns the PRF of prfType keyed by key, built by the PRF factory
+// if one is set
+func (o *options) initPRF(prfType prf.PRFType, key []byte) hash.Hash {
+	if o.prfFactory != nil {
+		return o.prfFactory(key)
+	}
+	return prfType.Init(key)
+}
+
+// WithLabel appends label to the prf+ seed (Ni | Nr | SPIi | SPIr), mixing a
+// context label into the keymat as some proprietary extensions do. A nil or
+// empty label keeps the RFC 7296 derivation.
+func WithLabel(label []byte) Option {
+	return func(o *options) {
+		o.label = label
+	}
+}
+
+// WithPermissiveESN lets NewChildSAKeyByProposal accept a proposal without
+// the ESN transform, which RFC 7296 requires, as if it offered ESN_DISABLE.
+// It is meant for interoperating with peers omitting the transform.
+func WithPermissiveESN() Option {
+	return func(o *options) {
+		o.permissiveESN = true
+	}
+}
+
+// WithKDF replaces prf+ with kdf to expand SKEYSEED into the IKE SA keys and
+// SK_d into the child SA keys. A nil kdf keeps prf+.
+func WithKDF(kdf KDF) Option {
+	return func(o *options) {
+		if kdf != nil {
+			o.kdf = kdf
+		}
+	}
+}
+
+// WithKeyStore stores every IKE SA key in keyStore once derived, and records
+// the returned handles in the KeyHandles of the IKESAKey. The security objects
+// are then built by keyStore from the handles, and the SK_* keys of the
+// IKESAKey are wiped. Wipe deletes the keys from keyStore.
+func WithKeyStore(keyStore KeyStore) Option {
+	return func(o *options) {
+		o.keyStore = keyStore
+	}
+}
+
+// WithPRFFactory builds every PRF instance used by the IKE SA key derivation
+// with factory instead of the PrfInfo of the IKE SA, such as a PRF computed
+// inside an HSM. This covers SKEYSEED, the prf+ expansion and the Prf_d,
+// Prf_i and Prf_r of the IKE SA, unless a KeyStore builds the latter.
+// factory must implement the negotiated PRF.
+// A nil factory keeps PrfInfo.Init.
+func WithPRFFactory(factory func(key []byte) hash.Hash) Option {
+	return func(o *options) {
+		o.prfFactory = factory
+	}
+}
@@ -0,0 +1,127 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" // #nosec G505
+	"encoding/hex"
+	"hash"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nathaniel-bennett/ike/security/dh"
+	"github.com/nathaniel-bennett/ike/security/encr"
+	"github.com/nathaniel-bennett/ike/security/integ"
+	"github.com/nathaniel-bennett/ike/security/prf"
+)
+
+func newTestLabelIKESAKey(t *testing.T, opts ...Option) *IKESAKey {
+	ikesaKey := &IKESAKey{
+		DhInfo:    dh.StrToType("DH_2048_BIT_MODP"),
+		EncrInfo:  encr.StrToType("ENCR_AES_CBC_256"),
+		IntegInfo: integ.StrToType("AUTH_HMAC_SHA1_96"),
+		PrfInfo:   prf.StrToType("PRF_HMAC_SHA1"),
+	}
+	err := ikesaKey.GenerateKeyForIKESA([]byte{0x01, 0x02, 0x03, 0x04},
+		[]byte{0x05, 0x06, 0x07, 0x08}, 0x456, 0x123, opts...)
+	require.NoError(t, err)
+	return ikesaKey
+}
+
+func TestGenerateKeyForIKESAWithLabel(t *testing.T) {
+	// Same keys as TestGenerateKeyForIKESA
+	expectedSK_d, err := hex.DecodeString("276e1a8f0d65dae5309da66277ff7c82d39a8956")
+	require.NoError(t, err)
+
+	standard := newTestLabelIKESAKey(t)
+	require.Equal(t, expectedSK_d, standard.SK_d)
+
+	nilLabel := newTestLabelIKESAKey(t, WithLabel(nil))
+	require.Equal(t, standard.SK_d, nilLabel.SK_d)
+	require.Equal(t, standard.SK_ei, nilLabel.SK_ei)
+	require.Equal(t, standard.SK_pr, nilLabel.SK_pr)
+
+	labeled := newTestLabelIKESAKey(t, WithLabel([]byte("label")))
+	require.NotEqual(t, standard.SK_d, labeled.SK_d)
+	require.NotEqual(t, standard.SK_ei, labeled.SK_ei)
+	require.NotEqual(t, standard.SK_pr, labeled.SK_pr)
+
+	again := newTestLabelIKESAKey(t, WithLabel([]byte("label")))
+	require.Equal(t, labeled.SK_d, again.SK_d)
+	require.Equal(t, labeled.SK_ei, again.SK_ei)
+	require.Equal(t, labeled.SK_pr, again.SK_pr)
+
+	other := newTestLabelIKESAKey(t, WithLabel([]byte("other")))
+	require.NotEqual(t, labeled.SK_d, other.SK_d)
+}
+
+// recordingPRFFactory builds HMAC-SHA1 instances and records their keys
+type recordingPRFFactory struct {
+	keys [][]byte
+}
+
+func (f *recordingPRFFactory) new(key []byte) hash.Hash {
+	f.keys = append(f.keys, append([]byte(nil), key...))
+	return hmac.New(sha1.New, key)
+}
+
+func TestGenerateKeyForIKESAWithPRFFactory(t *testing.T) {
+	standard := newTestLabelIKESAKey(t)
+	nilFactory := newTestLabelIKESAKey(t, WithPRFFactory(nil))
+	require.Equal(t, standard.SK_d, nilFactory.SK_d)
+
+	factory := new(recordingPRFFactory)
+	ikesaKey := newTestLabelIKESAKey(t, WithPRFFactory(factory.new))
+	require.Equal(t, standard.SK_d, ikesaKey.SK_d)
+	require.Equal(t, standard.SK_ei, ikesaKey.SK_ei)
+	require.Equal(t, standard.SK_pr, ikesaKey.SK_pr)
+
+	// SKEYSEED keyed by Ni | Nr, prf+ keyed by SKEYSEED, then Prf_d, Prf_i
+	// and Prf_r
+	require.Len(t, factory.keys, 5)
+	require.Equal(t, []byte{0x01, 0x02, 0x03, 0x04}, factory.keys[0])
+	skeyseed := hmac.New(sha1.New, factory.keys[0])
+	_, err := skeyseed.Write([]byte{0x05, 0x06, 0x07, 0x08})
+	require.NoError(t, err)
+	require.Equal(t, skeyseed.Sum(nil), factory.keys[1])
+	require.Equal(t, ikesaKey.SK_d, factory.keys[2])
+	require.Equal(t, ikesaKey.SK_pi, factory.keys[3])
+	require.Equal(t, ikesaKey.SK_pr, factory.keys[4])
+
+	// The rekeyed IKE SA uses the factory as well
+	proposal, err := ikesaKey.ToProposal()
+	require.NoError(t, err)
+	peerPublicValue := make([]byte, 256)
+	peerPublicValue[255] = 0x02
+	_, _, err = ikesaKey.RekeyIKESAKey(proposal, peerPublicValue, []byte{0x01, 0x02, 0x03, 0x04},
+		0x789, 0xabc, WithPRFFactory(factory.new))
+	require.NoError(t, err)
+	require.Len(t, factory.keys, 10)
+	require.Equal(t, ikesaKey.SK_d, factory.keys[5])
+}
+
+func TestGenerateKeyForChildSAOptions(t *testing.T) {
+	ikesaKey := newTestLabelIKESAKey(t)
+	deriveChildKeys := func(opts ...Option) *ChildSAKey {
+		childsaKey := &ChildSAKey{
+			EncrKInfo:  encr.StrToKType("ENCR_AES_CBC_128"),
+			IntegKInfo: integ.StrToKType("AUTH_HMAC_SHA1_96"),
+		}
+		require.NoError(t, childsaKey.GenerateKeyForChildSA(ikesaKey, []byte{0x01, 0x02, 0x03, 0x04}, opts...))
+		return childsaKey
+	}
+	standard := deriveChildKeys()
+
+	// Only WithKDF applies: the label, PRF factory and key store options
+	// are for the IKE SA derivation, and leave the child SA keys unchanged
+	factory := new(recordingPRFFactory)
+	keyStore := newMockKeyStore()
+	childsaKey := deriveChildKeys(WithLabel([]byte("label")), WithPRFFactory(factory.new), WithKeyStore(keyStore))
+	require.Equal(t, standard.InitiatorToResponderEncryptionKey, childsaKey.InitiatorToResponderEncryptionKey)
+	require.Equal(t, standard.ResponderToInitiatorIntegrityKey, childsaKey.ResponderToInitiatorIntegrityKey)
+	require.Empty(t, factory.keys)
+	require.Empty(t, keyStore.keys)
+
+	childsaKey = deriveChildKeys(WithKDF(new(counterKDF)))
+	require.NotEqual(t, standard.InitiatorToResponderEncryptionKey, childsaKey.InitiatorToResponderEncryptionKey)
+}
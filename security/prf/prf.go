@@ -10,6 +10,10 @@ const (
 	PRF_HMAC_MD5      string = "PRF_HMAC_MD5"
 	PRF_HMAC_SHA1     string = "PRF_HMAC_SHA1"
 	PRF_HMAC_SHA2_256 string = "PRF_HMAC_SHA2_256"
+	PRF_HMAC_SHA2_384 string = "PRF_HMAC_SHA2_384"
+	PRF_HMAC_SHA2_512 string = "PRF_HMAC_SHA2_512"
+	PRF_AES128_XCBC   string = "PRF_AES128_XCBC"
+	PRF_AES128_CMAC   string = "PRF_AES128_CMAC"
 )
 
 var (
@@ -23,6 +27,10 @@ func init() {
 	prfString[message.PRF_HMAC_MD5] = toString_PRF_HMAC_MD5
 	prfString[message.PRF_HMAC_SHA1] = toString_PRF_HMAC_SHA1
 	prfString[message.PRF_HMAC_SHA2_256] = toString_PRF_HMAC_SHA2_256
+	prfString[message.PRF_HMAC_SHA2_384] = toString_PRF_HMAC_SHA2_384
+	prfString[message.PRF_HMAC_SHA2_512] = toString_PRF_HMAC_SHA2_512
+	prfString[message.PRF_AES128_XCBC] = toString_PRF_AES128_XCBC
+	prfString[message.PRF_AES128_CMAC] = toString_PRF_AES128_CMAC
 
 	// PRF Types
 	prfTypes = make(map[string]PRFType)
@@ -39,6 +47,22 @@ func init() {
 		keyLength:    32,
 		outputLength: 32,
 	}
+	prfTypes[PRF_HMAC_SHA2_384] = &PrfHmacSha2_384{
+		keyLength:    48,
+		outputLength: 48,
+	}
+	prfTypes[PRF_HMAC_SHA2_512] = &PrfHmacSha2_512{
+		keyLength:    64,
+		outputLength: 64,
+	}
+	prfTypes[PRF_AES128_XCBC] = &PrfAes128Xcbc{
+		keyLength:    16,
+		outputLength: 16,
+	}
+	prfTypes[PRF_AES128_CMAC] = &PrfAes128Cmac{
+		keyLength:    16,
+		outputLength: 16,
+	}
 }
 
 func StrToType(algo string) PRFType {
@@ -79,8 +103,12 @@ func ToTransform(prfType PRFType) *message.Transform {
 
 type PRFType interface {
 	TransformID() uint16
+	Name() string
 	getAttribute() (bool, uint16, uint16, []byte)
 	GetKeyLength() int
 	GetOutputLength() int
+	// IsFixedKeyLength reports whether the PRF takes a key of exactly
+	// GetKeyLength bytes when computing SKEYSEED, RFC 7296 section 2.14
+	IsFixedKeyLength() bool
 	Init(key []byte) hash.Hash
 }
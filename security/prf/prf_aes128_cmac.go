@@ -0,0 +1,54 @@
+package prf
+
+import (
+	"hash"
+
+	"github.com/nathaniel-bennett/ike/message"
+	"github.com/nathaniel-bennett/ike/security/lib/cmac"
+)
+
+func toString_PRF_AES128_CMAC(attrType uint16, intValue uint16, bytesValue []byte) string {
+	return PRF_AES128_CMAC
+}
+
+var _ PRFType = &PrfAes128Cmac{}
+
+// PrfAes128Cmac is AES-CMAC-PRF-128, RFC 4615. Init accepts a key of any
+// length, but SKEYSEED is keyed by Ni[0..63] | Nr[0..63] like with
+// AES-XCBC-PRF-128, as RFC 7296 section 2.14 requires.
+type PrfAes128Cmac struct {
+	keyLength    int
+	outputLength int
+}
+
+func (t *PrfAes128Cmac) TransformID() uint16 {
+	return message.PRF_AES128_CMAC
+}
+
+func (t *PrfAes128Cmac) Name() string {
+	return PRF_AES128_CMAC
+}
+
+func (t *PrfAes128Cmac) getAttribute() (bool, uint16, uint16, []byte) {
+	return false, 0, 0, nil
+}
+
+func (t *PrfAes128Cmac) GetKeyLength() int {
+	return t.keyLength
+}
+
+func (t *PrfAes128Cmac) GetOutputLength() int {
+	return t.outputLength
+}
+
+func (t *PrfAes128Cmac) IsFixedKeyLength() bool {
+	return true
+}
+
+func (t *PrfAes128Cmac) Init(key []byte) hash.Hash {
+	if h, err := cmac.NewPRF(key); err == nil {
+		return h
+	} else {
+		return nil
+	}
+}
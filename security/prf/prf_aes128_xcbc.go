@@ -0,0 +1,54 @@
+package prf
+
+import (
+	"hash"
+
+	"github.com/nathaniel-bennett/ike/message"
+	"github.com/nathaniel-bennett/ike/security/lib/xcbc"
+)
+
+func toString_PRF_AES128_XCBC(attrType uint16, intValue uint16, bytesValue []byte) string {
+	return PRF_AES128_XCBC
+}
+
+var _ PRFType = &PrfAes128Xcbc{}
+
+// PrfAes128Xcbc is AES-XCBC-PRF-128, RFC 4434. Init accepts a key of any
+// length, but SKEYSEED is keyed by Ni[0..63] | Nr[0..63] as RFC 7296 section
+// 2.14 requires for this PRF: the full nonces are only used by prf+.
+type PrfAes128Xcbc struct {
+	keyLength    int
+	outputLength int
+}
+
+func (t *PrfAes128Xcbc) TransformID() uint16 {
+	return message.PRF_AES128_XCBC
+}
+
+func (t *PrfAes128Xcbc) Name() string {
+	return PRF_AES128_XCBC
+}
+
+func (t *PrfAes128Xcbc) getAttribute() (bool, uint16, uint16, []byte) {
+	return false, 0, 0, nil
+}
+
+func (t *PrfAes128Xcbc) GetKeyLength() int {
+	return t.keyLength
+}
+
+func (t *PrfAes128Xcbc) GetOutputLength() int {
+	return t.outputLength
+}
+
+func (t *PrfAes128Xcbc) IsFixedKeyLength() bool {
+	return true
+}
+
+func (t *PrfAes128Xcbc) Init(key []byte) hash.Hash {
+	if h, err := xcbc.NewPRF(key); err == nil {
+		return h
+	} else {
+		return nil
+	}
+}
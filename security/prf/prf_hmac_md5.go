@@ -23,6 +23,10 @@ func (t *PrfHmacMd5) TransformID() uint16 {
 	return message.PRF_HMAC_MD5
 }
 
+func (t *PrfHmacMd5) Name() string {
+	return PRF_HMAC_MD5
+}
+
 func (t *PrfHmacMd5) getAttribute() (bool, uint16, uint16, []byte) {
 	return false, 0, 0, nil
 }
@@ -35,6 +39,10 @@ func (t *PrfHmacMd5) GetOutputLength() int {
 	return t.outputLength
 }
 
+func (t *PrfHmacMd5) IsFixedKeyLength() bool {
+	return false
+}
+
 func (t *PrfHmacMd5) Init(key []byte) hash.Hash {
 	return hmac.New(md5.New, key)
 }
@@ -23,6 +23,10 @@ func (t *PrfHmacSha1) TransformID() uint16 {
 	return message.PRF_HMAC_SHA1
 }
 
+func (t *PrfHmacSha1) Name() string {
+	return PRF_HMAC_SHA1
+}
+
 func (t *PrfHmacSha1) getAttribute() (bool, uint16, uint16, []byte) {
 	return false, 0, 0, nil
 }
@@ -35,6 +39,10 @@ func (t *PrfHmacSha1) GetOutputLength() int {
 	return t.outputLength
 }
 
+func (t *PrfHmacSha1) IsFixedKeyLength() bool {
+	return false
+}
+
 func (t *PrfHmacSha1) Init(key []byte) hash.Hash {
 	return hmac.New(sha1.New, key)
 }
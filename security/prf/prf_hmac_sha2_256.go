@@ -23,6 +23,10 @@ func (t *PrfHmacSha2_256) TransformID() uint16 {
 	return message.PRF_HMAC_SHA2_256
 }
 
+func (t *PrfHmacSha2_256) Name() string {
+	return PRF_HMAC_SHA2_256
+}
+
 func (t *PrfHmacSha2_256) getAttribute() (bool, uint16, uint16, []byte) {
 	return false, 0, 0, nil
 }
@@ -35,6 +39,10 @@ func (t *PrfHmacSha2_256) GetOutputLength() int {
 	return t.outputLength
 }
 
+func (t *PrfHmacSha2_256) IsFixedKeyLength() bool {
+	return false
+}
+
 func (t *PrfHmacSha2_256) Init(key []byte) hash.Hash {
 	return hmac.New(sha256.New, key)
 }
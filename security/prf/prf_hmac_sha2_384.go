@@ -0,0 +1,48 @@
+package prf
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"hash"
+
+	"github.com/nathaniel-bennett/ike/message"
+)
+
+func toString_PRF_HMAC_SHA2_384(attrType uint16, intValue uint16, bytesValue []byte) string {
+	return PRF_HMAC_SHA2_384
+}
+
+var _ PRFType = &PrfHmacSha2_384{}
+
+type PrfHmacSha2_384 struct {
+	keyLength    int
+	outputLength int
+}
+
+func (t *PrfHmacSha2_384) TransformID() uint16 {
+	return message.PRF_HMAC_SHA2_384
+}
+
+func (t *PrfHmacSha2_384) Name() string {
+	return PRF_HMAC_SHA2_384
+}
+
+func (t *PrfHmacSha2_384) getAttribute() (bool, uint16, uint16, []byte) {
+	return false, 0, 0, nil
+}
+
+func (t *PrfHmacSha2_384) GetKeyLength() int {
+	return t.keyLength
+}
+
+func (t *PrfHmacSha2_384) GetOutputLength() int {
+	return t.outputLength
+}
+
+func (t *PrfHmacSha2_384) IsFixedKeyLength() bool {
+	return false
+}
+
+func (t *PrfHmacSha2_384) Init(key []byte) hash.Hash {
+	return hmac.New(sha512.New384, key)
+}
@@ -0,0 +1,480 @@
+package security
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/nathaniel-bennett/ike/message"
+	"github.com/nathaniel-bennett/ike/security/dh"
+	"github.com/nathaniel-bennett/ike/security/encr"
+	"github.com/nathaniel-bennett/ike/security/integ"
+	"github.com/nathaniel-bennett/ike/security/prf"
+)
+
+var (
+	ErrNoProposalChosen        = errors.New("no proposal chosen")
+	ErrTransformTypeMismatch   = errors.New("transform type does not match its slot")
+	ErrIncompatibleProposal    = errors.New("proposal incompatible with IKE SA")
+	ErrUnexpectedTransformType = errors.New("transform type not allowed for the protocol")
+)
+
+// Preference holds the transforms a responder accepts, ranked from the most
+// to the least preferred for each transform type.
+type Preference struct {
+	EncryptionAlgorithm     message.TransformContainer
+	PseudorandomFunction    message.TransformContainer
+	IntegrityAlgorithm      message.TransformContainer
+	DiffieHellmanGroup      message.TransformContainer
+	ExtendedSequenceNumbers message.TransformContainer
+}
+
+// PreferenceFromProposal returns the Preference listed by the transforms of
+// local, in the order they appear in it.
+func PreferenceFromProposal(local *message.Proposal) Preference {
+	return Preference{
+		EncryptionAlgorithm:     local.EncryptionAlgorithm,
+		PseudorandomFunction:    local.PseudorandomFunction,
+		IntegrityAlgorithm:      local.IntegrityAlgorithm,
+		DiffieHellmanGroup:      local.DiffieHellmanGroup,
+		ExtendedSequenceNumbers: local.ExtendedSequenceNumbers,
+	}
+}
+
+// ProposalScore returns how much the responder likes p. For each transform
+// type, the best ranked transform of p scores the number of transforms ranked
+// below it in preference plus one, and the scores of all types are added up.
+// A transform type p does not carry scores 0. ProposalScore returns -1 if p
+// is not acceptable, that is if p carries a transform type without any
+// transform listed in preference.
+func ProposalScore(p *message.Proposal, preference Preference) int {
+	if p == nil {
+		return -1
+	}
+
+	score := 0
+	for _, types := range [][2]message.TransformContainer{
+		{p.EncryptionAlgorithm, preference.EncryptionAlgorithm},
+		{p.PseudorandomFunction, preference.PseudorandomFunction},
+		{p.IntegrityAlgorithm, preference.IntegrityAlgorithm},
+		{p.DiffieHellmanGroup, preference.DiffieHellmanGroup},
+		{p.ExtendedSequenceNumbers, preference.ExtendedSequenceNumbers},
+	} {
+		if len(types[0]) == 0 {
+			continue
+		}
+		rank, _ := rankTransform(types[0], types[1])
+		if rank < 0 {
+			return -1
+		}
+		score += len(types[1]) - rank
+	}
+	return score
+}
+
+// SelectProposal picks the offered proposal that the local proposal can
+// satisfy and that scores the highest with ProposalScore, and returns a copy
+// of it holding a single transform of each type. The transforms of each type
+// in local are listed in order of preference, so the most preferred local
+// transform that the peer also offered is chosen, and the responder
+// preference wins over the order of the offered proposals. Between proposals
+// scoring the same, the first offered one is chosen. The proposal number,
+// protocol ID and SPI of the offered proposal are kept. A proposal pairing
+// an AEAD cipher with an integrity algorithm, or choosing another cipher
+// without any, is not acceptable.
+func SelectProposal(offered message.ProposalContainer, local *message.Proposal) (*message.Proposal, error) {
+	if local == nil {
+		return nil, errors.Errorf("SelectProposal : local proposal is nil")
+	}
+
+	preference := PreferenceFromProposal(local)
+	var best *message.Proposal
+	bestScore := -1
+	for _, proposal := range offered {
+		chosen := matchProposal(proposal, local)
+		if chosen == nil {
+			continue
+		}
+		if score := ProposalScore(chosen, preference); score > bestScore {
+			best, bestScore = chosen, score
+		}
+	}
+	if best == nil {
+		return nil, errors.Wrapf(ErrNoProposalChosen, "SelectProposal")
+	}
+	return best, nil
+}
+
+func matchProposal(proposal, local *message.Proposal) *message.Proposal {
+	if proposal == nil || proposal.ProtocolID != local.ProtocolID {
+		return nil
+	}
+	if checkTransformTypes(proposal) != nil || checkUnexpectedTransforms(proposal) != nil {
+		return nil
+	}
+
+	chosen := new(message.Proposal)
+	chosen.ProposalNumber = proposal.ProposalNumber
+	chosen.ProtocolID = proposal.ProtocolID
+	chosen.SPI = append(chosen.SPI, proposal.SPI...)
+
+	var ok bool
+	if chosen.EncryptionAlgorithm, ok = matchTransform(
+		proposal.EncryptionAlgorithm, local.EncryptionAlgorithm); !ok {
+		return nil
+	}
+	if chosen.PseudorandomFunction, ok = matchTransform(
+		proposal.PseudorandomFunction, local.PseudorandomFunction); !ok {
+		return nil
+	}
+	if chosen.IntegrityAlgorithm, ok = matchTransform(
+		proposal.IntegrityAlgorithm, local.IntegrityAlgorithm); !ok {
+		return nil
+	}
+	if chosen.DiffieHellmanGroup, ok = matchTransform(
+		proposal.DiffieHellmanGroup, local.DiffieHellmanGroup); !ok {
+		return nil
+	}
+	if chosen.ExtendedSequenceNumbers, ok = matchTransform(
+		proposal.ExtendedSequenceNumbers, local.ExtendedSequenceNumbers); !ok {
+		return nil
+	}
+	if !combinedModeAllowed(proposal, chosen) {
+		return nil
+	}
+	return chosen
+}
+
+// combinedModeAllowed applies RFC 5282 section 8 to the encryption algorithm
+// chosen from proposal: an AEAD cipher is only accepted from a proposal
+// without any integrity algorithm other than NONE, while another cipher
+// needs an integrity algorithm to be chosen
+func combinedModeAllowed(proposal, chosen *message.Proposal) bool {
+	if len(chosen.EncryptionAlgorithm) == 0 {
+		return true
+	}
+	if isAEADTransform(chosen.EncryptionAlgorithm[0], chosen.ProtocolID) {
+		return checkAEADIntegrity(proposal) == nil
+	}
+	return len(chosen.IntegrityAlgorithm) != 0 &&
+		chosen.IntegrityAlgorithm[0].TransformID != message.AUTH_NONE
+}
+
+// isAEADTransform tells if t is a supported AEAD cipher, decoded for an IKE
+// SA or for a child SA according to protocolID
+func isAEADTransform(t *message.Transform, protocolID uint8) bool {
+	if protocolID == message.TypeIKE {
+		encrType := encr.DecodeTransform(t)
+		return encrType != nil && encrType.IsAEAD()
+	}
+	encrKType := encr.DecodeTransformChildSA(t)
+	return encrKType != nil && encrKType.IsAEAD()
+}
+
+// TransformTypeName returns the name of transform type t as listed in the
+// IANA IKEv2 Transform Types registry, or "Unknown" for another value
+func TransformTypeName(t uint8) string {
+	switch t {
+	case message.TypeEncryptionAlgorithm:
+		return "Encryption Algorithm"
+	case message.TypePseudorandomFunction:
+		return "Pseudorandom Function"
+	case message.TypeIntegrityAlgorithm:
+		return "Integrity Algorithm"
+	case message.TypeDiffieHellmanGroup:
+		return "Diffie-Hellman Group"
+	case message.TypeExtendedSequenceNumbers:
+		return "Extended Sequence Numbers"
+	default:
+		return "Unknown"
+	}
+}
+
+// missingTransformError returns the error for a proposal without any
+// transform of type t
+func missingTransformError(t uint8) error {
+	return errors.Errorf("missing transform type %s (%d)", TransformTypeName(t), t)
+}
+
+// checkTransformTypes returns ErrTransformTypeMismatch if a transform of p
+// is held in the slot of another transform type
+func checkTransformTypes(p *message.Proposal) error {
+	for _, slot := range []struct {
+		transformType uint8
+		transforms    message.TransformContainer
+	}{
+		{message.TypeEncryptionAlgorithm, p.EncryptionAlgorithm},
+		{message.TypePseudorandomFunction, p.PseudorandomFunction},
+		{message.TypeIntegrityAlgorithm, p.IntegrityAlgorithm},
+		{message.TypeDiffieHellmanGroup, p.DiffieHellmanGroup},
+		{message.TypeExtendedSequenceNumbers, p.ExtendedSequenceNumbers},
+	} {
+		for _, t := range slot.transforms {
+			if t != nil && t.TransformType != slot.transformType {
+				return errors.Wrapf(ErrTransformTypeMismatch, "transform type %s (%d) in slot of type %s (%d)",
+					TransformTypeName(t.TransformType), t.TransformType,
+					TransformTypeName(slot.transformType), slot.transformType)
+			}
+		}
+	}
+	return nil
+}
+
+// checkUnexpectedTransforms returns ErrUnexpectedTransformType if p carries a
+// transform type that does not apply to its protocol, as listed in RFC 7296
+// section 3.3.3: an IKE proposal carries no ESN transform, an ESP proposal no
+// PRF, and an AH proposal neither encryption algorithm nor PRF
+func checkUnexpectedTransforms(p *message.Proposal) error {
+	var unexpected []uint8
+	switch p.ProtocolID {
+	case message.TypeIKE:
+		if len(p.ExtendedSequenceNumbers) != 0 {
+			unexpected = append(unexpected, message.TypeExtendedSequenceNumbers)
+		}
+	case message.TypeESP:
+		if len(p.PseudorandomFunction) != 0 {
+			unexpected = append(unexpected, message.TypePseudorandomFunction)
+		}
+	case message.TypeAH:
+		if len(p.EncryptionAlgorithm) != 0 {
+			unexpected = append(unexpected, message.TypeEncryptionAlgorithm)
+		}
+		if len(p.PseudorandomFunction) != 0 {
+			unexpected = append(unexpected, message.TypePseudorandomFunction)
+		}
+	}
+	if len(unexpected) != 0 {
+		return errors.Wrapf(ErrUnexpectedTransformType, "transform type %s (%d) in proposal of protocol %d",
+			TransformTypeName(unexpected[0]), unexpected[0], p.ProtocolID)
+	}
+	return nil
+}
+
+// matchTransform returns the first local transform that is also offered. A
+// transform type the peer did not offer is skipped, while an offered type
+// without any acceptable transform makes the whole proposal unacceptable.
+func matchTransform(offered, local message.TransformContainer) (message.TransformContainer, bool) {
+	if len(offered) == 0 {
+		return nil, true
+	}
+	if rank, o := rankTransform(offered, local); rank >= 0 {
+		t := *o
+		return message.TransformContainer{&t}, true
+	}
+	return nil, false
+}
+
+// rankTransform returns the index in local of the first local transform that
+// is also offered, along with the offered transform, or -1 if there is none.
+func rankTransform(offered, local message.TransformContainer) (int, *message.Transform) {
+	for i, l := range local {
+		for _, o := range offered {
+			if equalTransform(o, l) {
+				return i, o
+			}
+		}
+	}
+	return -1, nil
+}
+
+func equalTransform(a, b *message.Transform) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	if a.TransformID != b.TransformID || a.AttributePresent != b.AttributePresent {
+		return false
+	}
+	if !a.AttributePresent {
+		return true
+	}
+	return a.AttributeType == b.AttributeType &&
+		a.AttributeValue == b.AttributeValue &&
+		bytes.Equal(a.VariableLengthAttributeValue, b.VariableLengthAttributeValue)
+}
+
+type proposalTier struct {
+	encr  string
+	integ string
+	prf   string
+	dh    string
+}
+
+// Transforms offered for each security level, in bits
+var proposalTiers = map[int]proposalTier{
+	256: {encr.ENCR_AES_CBC_256, integ.AUTH_HMAC_SHA2_512_256, prf.PRF_HMAC_SHA2_512, dh.DH_521_BIT_RANDOM_ECP},
+	192: {encr.ENCR_AES_CBC_192, integ.AUTH_HMAC_SHA2_384_192, prf.PRF_HMAC_SHA2_384, dh.DH_384_BIT_RANDOM_ECP},
+	128: {encr.ENCR_AES_CBC_128, integ.AUTH_HMAC_SHA2_256_128, prf.PRF_HMAC_SHA2_256, dh.DH_256_BIT_RANDOM_ECP},
+}
+
+// ProposalTiers builds one IKE proposal per security level of levels (256, 192
+// or 128 bits), each holding transforms matched to that level: the NIST curve
+// of that strength, and the SHA2 PRF and integrity algorithm over a hash of
+// twice the level. The proposals are ordered from the strongest to the
+// weakest level and numbered from 1, so they can be offered as a
+// preference-ordered menu.
+func ProposalTiers(levels []int) ([]*message.Proposal, error) {
+	sorted := append([]int(nil), levels...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+
+	var proposals []*message.Proposal
+	for i, level := range sorted {
+		if i > 0 && level == sorted[i-1] {
+			continue
+		}
+		tier, ok := proposalTiers[level]
+		if !ok {
+			return nil, errors.Errorf("ProposalTiers : unsupported security level %d", level)
+		}
+
+		encrTransform, err := encr.ToTransform(encr.StrToType(tier.encr))
+		if err != nil {
+			return nil, errors.Wrapf(err, "ProposalTiers")
+		}
+
+		p := new(message.Proposal)
+		p.ProposalNumber = uint8(len(proposals) + 1)
+		p.ProtocolID = message.TypeIKE
+		p.EncryptionAlgorithm = append(p.EncryptionAlgorithm, encrTransform)
+		p.IntegrityAlgorithm = append(p.IntegrityAlgorithm, integ.ToTransform(integ.StrToType(tier.integ)))
+		p.PseudorandomFunction = append(p.PseudorandomFunction, prf.ToTransform(prf.StrToType(tier.prf)))
+		p.DiffieHellmanGroup = append(p.DiffieHellmanGroup, dh.ToTransform(dh.StrToType(tier.dh)))
+		proposals = append(proposals, p)
+	}
+	return proposals, nil
+}
+
+// CompatibleWith checks that the transforms chosen in peerProposal are the
+// ones of the IKE SA, including the encryption key length, and returns an
+// ErrIncompatibleProposal error describing the first mismatch otherwise.
+func (ikesaKey *IKESAKey) CompatibleWith(peerProposal *message.Proposal) error {
+	peer, err := newIKESAKeyByProposal(peerProposal)
+	if err != nil {
+		return errors.Wrapf(err, "CompatibleWith")
+	}
+
+	switch {
+	case ikesaKey.EncrInfo == nil || ikesaKey.EncrInfo.TransformID() != peer.EncrInfo.TransformID():
+		return errors.Wrapf(ErrIncompatibleProposal, "encryption algorithm %d", peer.EncrInfo.TransformID())
+	case ikesaKey.EncrInfo.GetKeyLength() != peer.EncrInfo.GetKeyLength():
+		return errors.Wrapf(ErrIncompatibleProposal, "encryption key length %d bytes, expected %d bytes",
+			peer.EncrInfo.GetKeyLength(), ikesaKey.EncrInfo.GetKeyLength())
+	case (ikesaKey.IntegInfo == nil) != (peer.IntegInfo == nil):
+		return errors.Wrapf(ErrIncompatibleProposal, "integrity algorithm %s",
+			integrityAlgorithmString(peer.IntegInfo))
+	case ikesaKey.IntegInfo != nil && ikesaKey.IntegInfo.TransformID() != peer.IntegInfo.TransformID():
+		return errors.Wrapf(ErrIncompatibleProposal, "integrity algorithm %d", peer.IntegInfo.TransformID())
+	case ikesaKey.PrfInfo == nil || ikesaKey.PrfInfo.TransformID() != peer.PrfInfo.TransformID():
+		return errors.Wrapf(ErrIncompatibleProposal, "pseudorandom function %d", peer.PrfInfo.TransformID())
+	case ikesaKey.DhInfo == nil || ikesaKey.DhInfo.TransformID() != peer.DhInfo.TransformID():
+		return errors.Wrapf(ErrIncompatibleProposal, "Diffie-Hellman group %d", peer.DhInfo.TransformID())
+	}
+	return nil
+}
+
+// TransformSummary describes a transform of a proposal
+type TransformSummary struct {
+	TransformID uint16
+	// Name of the transform, or "unsupported(ID)" if it is not supported
+	Name      string
+	Supported bool
+	// Length of the key material taken by the transform in bytes, as
+	// GetKeyLength returns it. It is 0 for a Diffie-Hellman group and for an
+	// unsupported transform.
+	KeyLength int
+}
+
+// ProposalSummary lists the transforms of a proposal by type, in the order
+// they are offered
+type ProposalSummary struct {
+	ProposalNumber       uint8
+	ProtocolID           uint8
+	EncryptionAlgorithm  []TransformSummary
+	PseudorandomFunction []TransformSummary
+	IntegrityAlgorithm   []TransformSummary
+	DiffieHellmanGroup   []TransformSummary
+}
+
+func (s ProposalSummary) String() string {
+	var names []string
+	for _, transforms := range [][]TransformSummary{
+		s.EncryptionAlgorithm,
+		s.PseudorandomFunction,
+		s.IntegrityAlgorithm,
+		s.DiffieHellmanGroup,
+	} {
+		for _, t := range transforms {
+			names = append(names, t.Name)
+		}
+	}
+	return fmt.Sprintf("proposal %d (protocol %d): %s", s.ProposalNumber, s.ProtocolID,
+		strings.Join(names, ", "))
+}
+
+// DescribeProposal decodes the transforms of p without deriving any key, so
+// that an offer can be logged. A transform that is not supported is reported
+// as "unsupported(ID)" rather than failing, while a transform held in the
+// slot of another transform type returns ErrTransformTypeMismatch.
+func DescribeProposal(p *message.Proposal) (ProposalSummary, error) {
+	if p == nil {
+		return ProposalSummary{}, errors.Errorf("DescribeProposal : proposal is nil")
+	}
+	if err := checkTransformTypes(p); err != nil {
+		return ProposalSummary{}, errors.Wrapf(err, "DescribeProposal")
+	}
+
+	return ProposalSummary{
+		ProposalNumber: p.ProposalNumber,
+		ProtocolID:     p.ProtocolID,
+		EncryptionAlgorithm: describeTransforms(p.EncryptionAlgorithm,
+			func(t *message.Transform) (string, int, bool) {
+				if encrType := encr.DecodeTransform(t); encrType != nil {
+					return encrType.Name(), encrType.GetKeyLength(), true
+				}
+				return "", 0, false
+			}),
+		PseudorandomFunction: describeTransforms(p.PseudorandomFunction,
+			func(t *message.Transform) (string, int, bool) {
+				if prfType := prf.DecodeTransform(t); prfType != nil {
+					return prfType.Name(), prfType.GetKeyLength(), true
+				}
+				return "", 0, false
+			}),
+		IntegrityAlgorithm: describeTransforms(p.IntegrityAlgorithm,
+			func(t *message.Transform) (string, int, bool) {
+				if integType := integ.DecodeTransform(t); integType != nil {
+					return integType.Name(), integType.GetKeyLength(), true
+				}
+				return "", 0, false
+			}),
+		DiffieHellmanGroup: describeTransforms(p.DiffieHellmanGroup,
+			func(t *message.Transform) (string, int, bool) {
+				if dhType := dh.DecodeTransform(t); dhType != nil {
+					return dhType.Name(), 0, true
+				}
+				return "", 0, false
+			}),
+	}, nil
+}
+
+// describeTransforms summarizes each non-nil transform of transforms with
+// decode, which returns false for an unsupported transform
+func describeTransforms(
+	transforms message.TransformContainer,
+	decode func(*message.Transform) (string, int, bool),
+) []TransformSummary {
+	var summaries []TransformSummary
+	for _, t := range transforms {
+		if t == nil {
+			continue
+		}
+		s := TransformSummary{TransformID: t.TransformID}
+		if s.Name, s.KeyLength, s.Supported = decode(t); !s.Supported {
+			s.Name = fmt.Sprintf("unsupported(%d)", t.TransformID)
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries
+}
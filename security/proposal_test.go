@@ -0,0 +1,382 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nathaniel-bennett/ike/message"
+	"github.com/nathaniel-bennett/ike/security/dh"
+	"github.com/nathaniel-bennett/ike/security/encr"
+	"github.com/nathaniel-bennett/ike/security/integ"
+	"github.com/nathaniel-bennett/ike/security/prf"
+)
+
+func newTestIKEProposal(t *testing.T, proposalNumber uint8, encrName string,
+	integNames ...string,
+) *message.Proposal {
+	p := new(message.Proposal)
+	p.ProposalNumber = proposalNumber
+	p.ProtocolID = message.TypeIKE
+	encrTransform, err := encr.ToTransform(encr.StrToType(encrName))
+	require.NoError(t, err)
+	p.EncryptionAlgorithm = append(p.EncryptionAlgorithm, encrTransform)
+	p.PseudorandomFunction = append(p.PseudorandomFunction,
+		prf.ToTransform(prf.StrToType("PRF_HMAC_SHA1")))
+	for _, name := range integNames {
+		p.IntegrityAlgorithm = append(p.IntegrityAlgorithm, integ.ToTransform(integ.StrToType(name)))
+	}
+	p.DiffieHellmanGroup = append(p.DiffieHellmanGroup,
+		dh.ToTransform(dh.StrToType("DH_2048_BIT_MODP")))
+	return p
+}
+
+func TestSelectProposalMultipleIntegrity(t *testing.T) {
+	offered := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256",
+		"AUTH_HMAC_SHA2_256_128", "AUTH_HMAC_SHA1_96", "AUTH_HMAC_MD5_96")
+	require.Len(t, offered.IntegrityAlgorithm, 3)
+
+	// The offer must survive encoding with all three integrity transforms
+	var payloads message.IKEPayloadContainer
+	sa := payloads.BuildSecurityAssociation()
+	sa.Proposals = append(sa.Proposals, offered)
+	b, err := payloads.Encode()
+	require.NoError(t, err)
+
+	var decoded message.IKEPayloadContainer
+	err = decoded.Decode(uint8(message.TypeSA), b)
+	require.NoError(t, err)
+	require.Len(t, decoded, 1)
+	decodedSA := decoded[0].(*message.SecurityAssociation)
+	require.Len(t, decodedSA.Proposals, 1)
+	require.Len(t, decodedSA.Proposals[0].IntegrityAlgorithm, 3)
+
+	// Responder prefers SHA1 over SHA2-256, so SHA1 is chosen even though the
+	// initiator listed SHA2-256 first
+	local := newTestIKEProposal(t, 0, "ENCR_AES_CBC_256",
+		"AUTH_HMAC_SHA1_96", "AUTH_HMAC_SHA2_256_128")
+
+	chosen, err := SelectProposal(decodedSA.Proposals, local)
+	require.NoError(t, err)
+	require.Len(t, chosen.IntegrityAlgorithm, 1)
+	require.Equal(t, uint16(message.AUTH_HMAC_SHA1_96), chosen.IntegrityAlgorithm[0].TransformID)
+	require.Len(t, chosen.EncryptionAlgorithm, 1)
+	require.Len(t, chosen.PseudorandomFunction, 1)
+	require.Len(t, chosen.DiffieHellmanGroup, 1)
+
+	ikesaKey, _, err := NewIKESAKey(chosen, []byte{0x05, 0x06, 0x07, 0x08},
+		[]byte{0x01, 0x02, 0x03, 0x04}, 0x123, 0x456)
+	require.NoError(t, err)
+	require.Equal(t, uint16(message.AUTH_HMAC_SHA1_96), ikesaKey.IntegInfo.TransformID())
+}
+
+func TestSelectProposalNoMatch(t *testing.T) {
+	offered := newTestIKEProposal(t, 1, "ENCR_AES_CBC_128", "AUTH_HMAC_MD5_96")
+	local := newTestIKEProposal(t, 0, "ENCR_AES_CBC_256", "AUTH_HMAC_MD5_96")
+
+	_, err := SelectProposal(message.ProposalContainer{offered}, local)
+	require.ErrorIs(t, err, ErrNoProposalChosen)
+
+	_, err = SelectProposal(message.ProposalContainer{offered}, nil)
+	require.Error(t, err)
+}
+
+func TestSelectProposalResponderPreference(t *testing.T) {
+	// The initiator prefers the weaker proposal
+	weak := newTestIKEProposal(t, 1, "ENCR_AES_CBC_128", "AUTH_HMAC_MD5_96")
+	strong := newTestIKEProposal(t, 2, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA2_256_128")
+	offered := message.ProposalContainer{weak, strong}
+
+	local := newTestIKEProposal(t, 0, "ENCR_AES_CBC_256",
+		"AUTH_HMAC_SHA2_256_128", "AUTH_HMAC_MD5_96")
+	encrTransform, err := encr.ToTransform(encr.StrToType("ENCR_AES_CBC_128"))
+	require.NoError(t, err)
+	local.EncryptionAlgorithm = append(local.EncryptionAlgorithm, encrTransform)
+
+	preference := PreferenceFromProposal(local)
+	require.Greater(t, ProposalScore(strong, preference), ProposalScore(weak, preference))
+	require.Equal(t, -1, ProposalScore(newTestIKEProposal(t, 3, "ENCR_AES_CBC_192"), preference))
+
+	chosen, err := SelectProposal(offered, local)
+	require.NoError(t, err)
+	require.Equal(t, uint8(2), chosen.ProposalNumber)
+	require.Equal(t, uint16(message.ENCR_AES_CBC), chosen.EncryptionAlgorithm[0].TransformID)
+	require.Equal(t, uint16(256), chosen.EncryptionAlgorithm[0].AttributeValue)
+	require.Equal(t, uint16(message.AUTH_HMAC_SHA2_256_128), chosen.IntegrityAlgorithm[0].TransformID)
+
+	// Equal scores keep the initiator order
+	chosen, err = SelectProposal(message.ProposalContainer{strong, strong}, local)
+	require.NoError(t, err)
+	require.Equal(t, uint8(2), chosen.ProposalNumber)
+}
+
+func TestSelectProposalWithoutLocalDHGroups(t *testing.T) {
+	offered := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA1_96")
+	offered.DiffieHellmanGroup = message.TransformContainer{
+		dh.ToTransform(dh.StrToType("DH_1024_BIT_MODP")),
+		dh.ToTransform(dh.StrToType("DH_2048_BIT_MODP")),
+	}
+
+	// Without local groups, no offered group is accepted
+	local := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA1_96")
+	local.DiffieHellmanGroup = nil
+
+	_, err := SelectProposal(message.ProposalContainer{offered}, local)
+	require.ErrorIs(t, err, ErrNoProposalChosen)
+	require.Nil(t, local.DiffieHellmanGroup)
+}
+
+func TestProposalTiers(t *testing.T) {
+	proposals, err := ProposalTiers([]int{128, 256, 192})
+	require.NoError(t, err)
+	require.Len(t, proposals, 3)
+
+	expectedKeyLengths := []uint16{256, 192, 128}
+	for i, p := range proposals {
+		require.Equal(t, uint8(i+1), p.ProposalNumber)
+		require.Equal(t, uint8(message.TypeIKE), p.ProtocolID)
+		require.Len(t, p.EncryptionAlgorithm, 1)
+		require.Equal(t, expectedKeyLengths[i], p.EncryptionAlgorithm[0].AttributeValue)
+
+		ikesaKey, err := newIKESAKeyByProposal(p)
+		require.NoError(t, err)
+		require.Equal(t, int(expectedKeyLengths[i]/8), ikesaKey.EncrInfo.GetKeyLength())
+
+		// The key exchange and the PRF are at least as strong as the level
+		level := int(expectedKeyLengths[i])
+		require.GreaterOrEqual(t, ikesaKey.DhInfo.GetSecurityStrength(), level)
+		require.GreaterOrEqual(t, ikesaKey.PrfInfo.GetOutputLength()*8, 2*level)
+		require.GreaterOrEqual(t, ikesaKey.IntegInfo.GetOutputLength()*8, level)
+	}
+
+	_, err = ProposalTiers([]int{256, 112})
+	require.Error(t, err)
+}
+
+func TestResponseProposalNumber(t *testing.T) {
+	offered := message.ProposalContainer{
+		newTestIKEProposal(t, 1, "ENCR_AES_CBC_128", "AUTH_HMAC_MD5_96"),
+		newTestIKEProposal(t, 2, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA1_96"),
+	}
+	local := newTestIKEProposal(t, 0, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA1_96")
+
+	chosen, err := SelectProposal(offered, local)
+	require.NoError(t, err)
+	require.Equal(t, uint8(2), chosen.ProposalNumber)
+
+	ikesaKey, _, err := NewIKESAKey(chosen, []byte{0x05, 0x06, 0x07, 0x08},
+		[]byte{0x01, 0x02, 0x03, 0x04}, 0x123, 0x456)
+	require.NoError(t, err)
+	require.Equal(t, uint8(2), ikesaKey.ProposalNumber)
+
+	response, err := ikesaKey.ToProposal()
+	require.NoError(t, err)
+	require.Equal(t, uint8(2), response.ProposalNumber)
+
+	// Child SA
+	childProposal := new(message.Proposal)
+	childProposal.ProposalNumber = 3
+	childProposal.ProtocolID = message.TypeESP
+	childProposal.SPI = []byte{0x01, 0x02, 0x03, 0x04}
+	encrTransform, err := encr.ToTransformChildSA(encr.StrToKType("ENCR_AES_CBC_256"))
+	require.NoError(t, err)
+	childProposal.EncryptionAlgorithm = append(childProposal.EncryptionAlgorithm, encrTransform)
+	childProposal.IntegrityAlgorithm = append(childProposal.IntegrityAlgorithm,
+		integ.ToTransformChildSA(integ.StrToKType("AUTH_HMAC_SHA1_96")))
+	childProposal.ExtendedSequenceNumbers = append(childProposal.ExtendedSequenceNumbers,
+		&message.Transform{TransformType: message.TypeExtendedSequenceNumbers, TransformID: message.ESN_DISABLE})
+
+	childsaKey, err := NewChildSAKeyByProposal(childProposal)
+	require.NoError(t, err)
+	require.Equal(t, uint8(3), childsaKey.ProposalNumber)
+	response, err = childsaKey.ToProposal()
+	require.NoError(t, err)
+	require.Equal(t, uint8(3), response.ProposalNumber)
+}
+
+func TestTransformTypeMismatch(t *testing.T) {
+	proposal := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA1_96")
+	// A DH group in the encryption slot
+	proposal.EncryptionAlgorithm[0].TransformType = message.TypeDiffieHellmanGroup
+
+	_, _, err := NewIKESAKey(proposal, []byte{0x05, 0x06, 0x07, 0x08},
+		[]byte{0x01, 0x02, 0x03, 0x04}, 0x123, 0x456)
+	require.ErrorIs(t, err, ErrTransformTypeMismatch)
+	require.Equal(t, uint16(message.INVALID_SYNTAX), NotifyTypeForError(err))
+
+	local := newTestIKEProposal(t, 0, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA1_96")
+	_, err = SelectProposal(message.ProposalContainer{proposal}, local)
+	require.ErrorIs(t, err, ErrNoProposalChosen)
+
+	childProposal := new(message.Proposal)
+	childProposal.ProtocolID = message.TypeESP
+	encrTransform, err := encr.ToTransformChildSA(encr.StrToKType("ENCR_AES_CBC_256"))
+	require.NoError(t, err)
+	childProposal.EncryptionAlgorithm = append(childProposal.EncryptionAlgorithm, encrTransform)
+	childProposal.IntegrityAlgorithm = append(childProposal.IntegrityAlgorithm,
+		integ.ToTransformChildSA(integ.StrToKType("AUTH_HMAC_SHA1_96")))
+	// An integrity algorithm in the ESN slot
+	childProposal.ExtendedSequenceNumbers = append(childProposal.ExtendedSequenceNumbers,
+		&message.Transform{TransformType: message.TypeIntegrityAlgorithm, TransformID: message.ESN_DISABLE})
+	_, err = NewChildSAKeyByProposal(childProposal)
+	require.ErrorIs(t, err, ErrTransformTypeMismatch)
+}
+
+func TestUnexpectedTransformType(t *testing.T) {
+	proposal := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA1_96")
+	proposal.ExtendedSequenceNumbers = append(proposal.ExtendedSequenceNumbers,
+		&message.Transform{TransformType: message.TypeExtendedSequenceNumbers, TransformID: message.ESN_DISABLE})
+
+	_, _, err := NewIKESAKey(proposal, []byte{0x05, 0x06, 0x07, 0x08},
+		[]byte{0x01, 0x02, 0x03, 0x04}, 0x123, 0x456)
+	require.ErrorIs(t, err, ErrUnexpectedTransformType)
+	require.ErrorContains(t, err, "Extended Sequence Numbers (5)")
+	require.Equal(t, uint16(message.INVALID_SYNTAX), NotifyTypeForError(err))
+
+	local := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA1_96")
+	_, err = SelectProposal(message.ProposalContainer{proposal}, local)
+	require.ErrorIs(t, err, ErrNoProposalChosen)
+
+	// A PRF in a child SA proposal
+	proposal.ProtocolID = message.TypeESP
+	_, err = NewChildSAKeyByProposal(proposal)
+	require.ErrorIs(t, err, ErrUnexpectedTransformType)
+
+	proposal.PseudorandomFunction = nil
+	proposal.DiffieHellmanGroup = nil
+	proposal.SPI = []byte{0x01, 0x02, 0x03, 0x04}
+	_, err = NewChildSAKeyByProposal(proposal)
+	require.NoError(t, err)
+}
+
+func TestTransformTypeName(t *testing.T) {
+	testcases := []struct {
+		transformType uint8
+		name          string
+	}{
+		{message.TypeEncryptionAlgorithm, "Encryption Algorithm"},
+		{message.TypePseudorandomFunction, "Pseudorandom Function"},
+		{message.TypeIntegrityAlgorithm, "Integrity Algorithm"},
+		{message.TypeDiffieHellmanGroup, "Diffie-Hellman Group"},
+		{message.TypeExtendedSequenceNumbers, "Extended Sequence Numbers"},
+		{0, "Unknown"},
+		{6, "Unknown"},
+	}
+	for _, tc := range testcases {
+		require.Equal(t, tc.name, TransformTypeName(tc.transformType))
+	}
+
+	proposal := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256")
+	_, _, err := NewIKESAKey(proposal, []byte{0x05, 0x06, 0x07, 0x08},
+		[]byte{0x01, 0x02, 0x03, 0x04}, 0x123, 0x456)
+	require.ErrorContains(t, err, "missing transform type Integrity Algorithm (3)")
+}
+
+func TestIKESAKeyCompatibleWith(t *testing.T) {
+	proposal := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA1_96")
+	ikesaKey, _, err := NewIKESAKey(proposal, []byte{0x05, 0x06, 0x07, 0x08},
+		[]byte{0x01, 0x02, 0x03, 0x04}, 0x123, 0x456)
+	require.NoError(t, err)
+
+	require.NoError(t, ikesaKey.CompatibleWith(proposal))
+	response, err := ikesaKey.ToProposal()
+	require.NoError(t, err)
+	require.NoError(t, ikesaKey.CompatibleWith(response))
+
+	// Same cipher, other key length
+	err = ikesaKey.CompatibleWith(newTestIKEProposal(t, 1, "ENCR_AES_CBC_192", "AUTH_HMAC_SHA1_96"))
+	require.ErrorIs(t, err, ErrIncompatibleProposal)
+	require.Contains(t, err.Error(), "key length 24 bytes, expected 32 bytes")
+
+	err = ikesaKey.CompatibleWith(newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_MD5_96"))
+	require.ErrorIs(t, err, ErrIncompatibleProposal)
+
+	err = ikesaKey.CompatibleWith(nil)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrIncompatibleProposal)
+}
+
+func TestDescribeProposal(t *testing.T) {
+	p := newTestIKEProposal(t, 2, "ENCR_AES_GCM_16_256", "AUTH_HMAC_SHA2_256_128")
+	p.EncryptionAlgorithm = append(p.EncryptionAlgorithm,
+		&message.Transform{
+			TransformType:    message.TypeEncryptionAlgorithm,
+			TransformID:      message.ENCR_AES_CBC,
+			AttributePresent: true,
+			AttributeFormat:  message.AttributeFormatUseTV,
+			AttributeType:    message.AttributeTypeKeyLength,
+			AttributeValue:   512,
+		},
+		&message.Transform{TransformType: message.TypeEncryptionAlgorithm, TransformID: message.ENCR_DES})
+	p.PseudorandomFunction = append(p.PseudorandomFunction,
+		&message.Transform{TransformType: message.TypePseudorandomFunction, TransformID: 1024})
+	p.IntegrityAlgorithm = append(p.IntegrityAlgorithm,
+		&message.Transform{TransformType: message.TypeIntegrityAlgorithm, TransformID: 1025})
+	p.DiffieHellmanGroup = append(p.DiffieHellmanGroup,
+		dh.ToTransform(dh.StrToType("DH_CURVE25519")),
+		&message.Transform{TransformType: message.TypeDiffieHellmanGroup, TransformID: message.DH_1536_BIT_MODP})
+
+	summary, err := DescribeProposal(p)
+	require.NoError(t, err)
+	require.Equal(t, uint8(2), summary.ProposalNumber)
+	require.Equal(t, uint8(message.TypeIKE), summary.ProtocolID)
+	require.Equal(t, []TransformSummary{
+		{message.ENCR_AES_GCM_16, "ENCR_AES_GCM_16_256", true, 36},
+		{message.ENCR_AES_CBC, "unsupported(12)", false, 0},
+		{message.ENCR_DES, "unsupported(2)", false, 0},
+	}, summary.EncryptionAlgorithm)
+	require.Equal(t, []TransformSummary{
+		{message.PRF_HMAC_SHA1, "PRF_HMAC_SHA1", true, 20},
+		{1024, "unsupported(1024)", false, 0},
+	}, summary.PseudorandomFunction)
+	require.Equal(t, []TransformSummary{
+		{message.AUTH_HMAC_SHA2_256_128, "AUTH_HMAC_SHA2_256_128", true, 32},
+		{1025, "unsupported(1025)", false, 0},
+	}, summary.IntegrityAlgorithm)
+	require.Equal(t, []TransformSummary{
+		{message.DH_2048_BIT_MODP, "DH_2048_BIT_MODP", true, 0},
+		{message.DH_CURVE25519, "DH_CURVE25519", true, 0},
+		{message.DH_1536_BIT_MODP, "unsupported(5)", false, 0},
+	}, summary.DiffieHellmanGroup)
+	require.Equal(t, "proposal 2 (protocol 1): ENCR_AES_GCM_16_256, unsupported(12), unsupported(2), "+
+		"PRF_HMAC_SHA1, unsupported(1024), AUTH_HMAC_SHA2_256_128, unsupported(1025), "+
+		"DH_2048_BIT_MODP, DH_CURVE25519, unsupported(5)", summary.String())
+
+	p.IntegrityAlgorithm[0].TransformType = message.TypePseudorandomFunction
+	_, err = DescribeProposal(p)
+	require.ErrorIs(t, err, ErrTransformTypeMismatch)
+
+	_, err = DescribeProposal(nil)
+	require.Error(t, err)
+}
+
+func TestSelectProposalCombinedMode(t *testing.T) {
+	local := newTestIKEProposal(t, 1, "ENCR_AES_GCM_16_256", "AUTH_HMAC_SHA2_256_128")
+	cbc, err := encr.ToTransform(encr.StrToType("ENCR_AES_CBC_256"))
+	require.NoError(t, err)
+	local.EncryptionAlgorithm = append(local.EncryptionAlgorithm, cbc)
+
+	// AEAD without integrity is accepted
+	aead := newTestIKEProposal(t, 1, "ENCR_AES_GCM_16_256")
+	chosen, err := SelectProposal(message.ProposalContainer{aead}, local)
+	require.NoError(t, err)
+	require.Equal(t, uint16(message.ENCR_AES_GCM_16), chosen.EncryptionAlgorithm[0].TransformID)
+	require.Empty(t, chosen.IntegrityAlgorithm)
+
+	// AEAD along with an integrity algorithm is rejected
+	aeadWithInteg := newTestIKEProposal(t, 1, "ENCR_AES_GCM_16_256", "AUTH_HMAC_SHA2_256_128")
+	_, err = SelectProposal(message.ProposalContainer{aeadWithInteg}, local)
+	require.ErrorIs(t, err, ErrNoProposalChosen)
+
+	// A non-AEAD cipher needs an integrity algorithm
+	cbcWithoutInteg := newTestIKEProposal(t, 2, "ENCR_AES_CBC_256")
+	_, err = SelectProposal(message.ProposalContainer{cbcWithoutInteg}, local)
+	require.ErrorIs(t, err, ErrNoProposalChosen)
+
+	cbcWithInteg := newTestIKEProposal(t, 3, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA2_256_128")
+	chosen, err = SelectProposal(message.ProposalContainer{aeadWithInteg, cbcWithoutInteg, cbcWithInteg}, local)
+	require.NoError(t, err)
+	require.Equal(t, uint8(3), chosen.ProposalNumber)
+	require.Equal(t, uint16(message.ENCR_AES_CBC), chosen.EncryptionAlgorithm[0].TransformID)
+	require.Equal(t, uint16(message.AUTH_HMAC_SHA2_256_128), chosen.IntegrityAlgorithm[0].TransformID)
+}
@@ -0,0 +1,203 @@
+package security
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/nathaniel-bennett/ike/message"
+)
+
+var (
+	ErrUnassignedSPI     = errors.New("SPI not assigned")
+	ErrUnacceptableRekey = errors.New("rekey proposal not acceptable for child SA")
+)
+
+// RekeyIKESAKey creates the IKE SA replacing ikesaKey, as defined in RFC 7296
+// section 2.18. The new SKEYSEED is computed with the PRF of the old IKE SA
+// keyed by its SK_d, so the SK_d values chain across successive rekeys.
+// Both SPIs of the new IKE SA are part of the key derivation, so they must
+// be assigned before calling RekeyIKESAKey: a zero SPI returns
+// ErrUnassignedSPI.
+// Peer public value as parameter, return new IKE SA and local public value.
+func (ikesaKey *IKESAKey) RekeyIKESAKey(
+	proposal *message.Proposal,
+	keyExchangeData, concatenatedNonce []byte,
+	initiatorSPI, responderSPI uint64,
+	opts ...Option,
+) (*IKESAKey, []byte, error) {
+	if ikesaKey == nil {
+		return nil, nil, errors.Errorf("RekeyIKESAKey : IKE SA is nil")
+	}
+	if ikesaKey.PrfInfo == nil || !ikesaKey.hasSKd() {
+		return nil, nil, errors.Errorf("RekeyIKESAKey : IKE SA has no SK_d")
+	}
+	if len(concatenatedNonce) == 0 {
+		return nil, nil, errors.Errorf("RekeyIKESAKey : No concatenated nonce data")
+	}
+	if initiatorSPI == 0 || responderSPI == 0 {
+		return nil, nil, errors.Wrapf(ErrUnassignedSPI, "RekeyIKESAKey : initiator SPI 0x%x, responder SPI 0x%x",
+			initiatorSPI, responderSPI)
+	}
+
+	newKey, err := newIKESAKeyByProposal(proposal)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "RekeyIKESAKey")
+	}
+
+	localPublicValue, sharedKeyData, err := CalculateDiffieHellmanMaterials(
+		newKey, keyExchangeData)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "RekeyIKESAKey")
+	}
+
+	// The keys of the new IKE SA go to the key store of the old one, unless
+	// opts give another
+	if ikesaKey.keyStore != nil {
+		opts = append([]Option{WithKeyStore(ikesaKey.keyStore)}, opts...)
+	}
+
+	// SKEYSEED = prf(SK_d (old), g^ir (new) | Ni | Nr)
+	o := newOptions(opts)
+	prf, err := ikesaKey.keyedPRF(KeyLabelSK_d, ikesaKey.SK_d, o.initPRF)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "RekeyIKESAKey")
+	}
+	if _, err = prf.Write(sharedKeyData); err != nil {
+		return nil, nil, errors.Wrapf(err, "RekeyIKESAKey")
+	}
+	if _, err = prf.Write(concatenatedNonce); err != nil {
+		return nil, nil, errors.Wrapf(err, "RekeyIKESAKey")
+	}
+	skeyseed := prf.Sum(nil)
+
+	err = newKey.deriveKeysFromSKEYSEED(skeyseed, concatenatedNonce, initiatorSPI, responderSPI, o)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "RekeyIKESAKey")
+	}
+
+	newKey.generation = ikesaKey.generation + 1
+	newKey.parent = &IKESAKey{
+		DhInfo:     ikesaKey.DhInfo,
+		EncrInfo:   ikesaKey.EncrInfo,
+		IntegInfo:  ikesaKey.IntegInfo,
+		PrfInfo:    ikesaKey.PrfInfo,
+		generation: ikesaKey.generation,
+	}
+
+	return newKey, localPublicValue, nil
+}
+
+// Generation returns the number of rekeys between the initial IKE SA and
+// this one. The initial IKE SA is generation 0.
+func (ikesaKey *IKESAKey) Generation() uint {
+	return ikesaKey.generation
+}
+
+// Parent returns the IKE SA this one was rekeyed from, or nil for the initial
+// IKE SA. It is only meant for debugging: the returned IKESAKey holds the
+// transforms and generation of the parent, but none of its keys, and its own
+// Parent is nil.
+func (ikesaKey *IKESAKey) Parent() *IKESAKey {
+	return ikesaKey.parent
+}
+
+// RekeyState tracks a make-before-break rekey of an IKE SA: Old keeps
+// protecting the traffic until New, created by RekeyIKESAKey, is confirmed,
+// so both are held in the meantime.
+type RekeyState struct {
+	Old *IKESAKey
+	New *IKESAKey
+}
+
+// Commit is called once New is confirmed. It zeroizes the keys of Old and
+// drops it, leaving New as the only IKE SA. Old cannot be used afterwards,
+// including through another reference to it.
+func (s *RekeyState) Commit() error {
+	if s == nil || s.Old == nil {
+		return errors.Errorf("RekeyState Commit : No old IKE SA")
+	}
+	if s.New == nil {
+		return errors.Errorf("RekeyState Commit : No new IKE SA")
+	}
+
+	s.Old.Wipe()
+	s.Old = nil
+	return nil
+}
+
+// RekeyChildSAWithPFS creates the child SA replacing old in a CREATE_CHILD_SA
+// exchange with a fresh Diffie-Hellman exchange, as defined in RFC 7296
+// section 2.17. The keys are derived from SK_d of ikesaKey over
+// g^ir (new) | Ni | Nr, and the new child SA keeps the transforms of old.
+// Its SPI is left to the caller, and old is left untouched.
+func (ikesaKey *IKESAKey) RekeyChildSAWithPFS(
+	old *ChildSAKey,
+	newDHShared, initiatorNonce, responderNonce []byte,
+) (*ChildSAKey, error) {
+	if old == nil {
+		return nil, errors.Errorf("RekeyChildSAWithPFS : Child SA is nil")
+	}
+	if len(newDHShared) == 0 {
+		return nil, errors.Wrapf(ErrEmptyKeyExchangeData, "RekeyChildSAWithPFS")
+	}
+	if len(initiatorNonce) == 0 || len(responderNonce) == 0 {
+		return nil, errors.Errorf("RekeyChildSAWithPFS : No nonce data")
+	}
+
+	newKey := &ChildSAKey{
+		ProposalNumber: old.ProposalNumber,
+		DhInfo:         old.DhInfo,
+		EncrKInfo:      old.EncrKInfo,
+		IntegKInfo:     old.IntegKInfo,
+		EsnInfo:        old.EsnInfo,
+		NATTraversal:   old.NATTraversal,
+	}
+
+	seed := make([]byte, 0, len(newDHShared)+len(initiatorNonce)+len(responderNonce))
+	seed = append(seed, newDHShared...)
+	seed = append(seed, initiatorNonce...)
+	seed = append(seed, responderNonce...)
+
+	if err := newKey.GenerateKeyForChildSA(ikesaKey, seed); err != nil {
+		return nil, errors.Wrapf(err, "RekeyChildSAWithPFS")
+	}
+	return newKey, nil
+}
+
+// AcceptableESPRekey checks that newProposal, offered by the peer to rekey
+// the ESP child SA, is an ESP proposal with the encryption algorithm, key
+// length and integrity algorithm of the child SA, and a Diffie-Hellman group
+// at least as strong as the child SA one if it has PFS. It returns an
+// ErrUnacceptableRekey error describing the first mismatch otherwise, so
+// that a peer cannot downgrade a child SA through a rekey.
+//
+// It only handles ESP: an AH proposal has no encryption algorithm, which a
+// ChildSAKey requires, so any other protocol is unacceptable.
+func (childsaKey *ChildSAKey) AcceptableESPRekey(newProposal *message.Proposal) error {
+	if newProposal == nil {
+		return errors.Errorf("AcceptableESPRekey : proposal is nil")
+	}
+	if newProposal.ProtocolID != message.TypeESP {
+		return errors.Wrapf(ErrUnacceptableRekey, "protocol %d", newProposal.ProtocolID)
+	}
+
+	rekeyed, err := NewChildSAKeyByProposal(newProposal)
+	if err != nil {
+		return errors.Wrapf(err, "AcceptableESPRekey")
+	}
+
+	switch {
+	case childsaKey.EncrKInfo == nil || childsaKey.EncrKInfo.TransformID() != rekeyed.EncrKInfo.TransformID():
+		return errors.Wrapf(ErrUnacceptableRekey, "encryption algorithm %d", rekeyed.EncrKInfo.TransformID())
+	case childsaKey.EncrKInfo.GetKeyLength() != rekeyed.EncrKInfo.GetKeyLength():
+		return errors.Wrapf(ErrUnacceptableRekey, "encryption key length %d bytes, expected %d bytes",
+			rekeyed.EncrKInfo.GetKeyLength(), childsaKey.EncrKInfo.GetKeyLength())
+	case (childsaKey.IntegKInfo == nil) != (rekeyed.IntegKInfo == nil):
+		return errors.Wrapf(ErrUnacceptableRekey, "integrity algorithm presence changed")
+	case childsaKey.IntegKInfo != nil && childsaKey.IntegKInfo.TransformID() != rekeyed.IntegKInfo.TransformID():
+		return errors.Wrapf(ErrUnacceptableRekey, "integrity algorithm %d", rekeyed.IntegKInfo.TransformID())
+	case childsaKey.PFSStrength() > rekeyed.PFSStrength():
+		return errors.Wrapf(ErrUnacceptableRekey, "Diffie-Hellman strength %d bits, expected at least %d bits",
+			rekeyed.PFSStrength(), childsaKey.PFSStrength())
+	}
+	return nil
+}
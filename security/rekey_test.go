@@ -0,0 +1,268 @@
+package security
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nathaniel-bennett/ike/message"
+	"github.com/nathaniel-bennett/ike/security/dh"
+	"github.com/nathaniel-bennett/ike/security/encr"
+	"github.com/nathaniel-bennett/ike/security/esn"
+	"github.com/nathaniel-bennett/ike/security/integ"
+)
+
+func TestRekeyIKESAKeyGeneration(t *testing.T) {
+	proposal := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA2_256_128")
+	nonce := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+
+	ikesaKey, _, err := NewIKESAKey(proposal, []byte{0x05, 0x06, 0x07, 0x08}, nonce, 0x123, 0x456)
+	require.NoError(t, err)
+	require.Equal(t, uint(0), ikesaKey.Generation())
+	require.Nil(t, ikesaKey.Parent())
+
+	first, _, err := ikesaKey.RekeyIKESAKey(proposal, []byte{0x09, 0x0a}, nonce, 0x789, 0xabc)
+	require.NoError(t, err)
+	require.Equal(t, uint(1), first.Generation())
+	require.NotEqual(t, ikesaKey.SK_d, first.SK_d)
+
+	second, _, err := first.RekeyIKESAKey(proposal, []byte{0x0b, 0x0c}, nonce, 0xdef, 0x1011)
+	require.NoError(t, err)
+	require.Equal(t, uint(2), second.Generation())
+	require.NotEqual(t, first.SK_d, second.SK_d)
+
+	// Parent records the lineage without holding any key of the parent
+	parent := second.Parent()
+	require.NotNil(t, parent)
+	require.Equal(t, uint(1), parent.Generation())
+	require.Equal(t, first.PrfInfo, parent.PrfInfo)
+	require.Nil(t, parent.Parent())
+	require.Empty(t, parent.SK_d)
+	require.Empty(t, parent.SK_ei)
+	require.Nil(t, parent.Prf_d)
+}
+
+func TestRekeyIKESAKeyWithoutSKd(t *testing.T) {
+	proposal := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA2_256_128")
+
+	_, _, err := new(IKESAKey).RekeyIKESAKey(proposal, []byte{0x01}, []byte{0x02}, 1, 2)
+	require.Error(t, err)
+}
+
+func TestRekeyIKESAKeyUnassignedSPI(t *testing.T) {
+	proposal := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA2_256_128")
+	nonce := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+
+	ikesaKey, _, err := NewIKESAKey(proposal, []byte{0x05, 0x06, 0x07, 0x08}, nonce, 0x123, 0x456)
+	require.NoError(t, err)
+
+	_, _, err = ikesaKey.RekeyIKESAKey(proposal, []byte{0x09, 0x0a}, nonce, 0x789, 0)
+	require.ErrorIs(t, err, ErrUnassignedSPI)
+	_, _, err = ikesaKey.RekeyIKESAKey(proposal, []byte{0x09, 0x0a}, nonce, 0, 0xabc)
+	require.ErrorIs(t, err, ErrUnassignedSPI)
+}
+
+func TestRekeyStateCommit(t *testing.T) {
+	proposal := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA2_256_128")
+	nonce := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+
+	ikesaKey, _, err := NewIKESAKey(proposal, []byte{0x05, 0x06, 0x07, 0x08}, nonce, 0x123, 0x456)
+	require.NoError(t, err)
+	oldSKd := ikesaKey.SK_d
+	oldSKei := ikesaKey.SK_ei
+
+	state := &RekeyState{Old: ikesaKey}
+	require.Error(t, state.Commit())
+	require.NotNil(t, ikesaKey.Encr_i)
+
+	// Both IKE SAs are usable until the new one is confirmed
+	state.New, _, err = ikesaKey.RekeyIKESAKey(proposal, []byte{0x09, 0x0a}, nonce, 0x789, 0xabc)
+	require.NoError(t, err)
+	require.NotNil(t, state.Old.Encr_i)
+	require.NotNil(t, state.New.Encr_i)
+	newSKd := append([]byte(nil), state.New.SK_d...)
+
+	require.NoError(t, state.Commit())
+	require.Nil(t, state.Old)
+	require.Equal(t, newSKd, state.New.SK_d)
+
+	// The old keys are zeroized in place and the security objects dropped
+	require.Equal(t, make([]byte, len(oldSKd)), oldSKd)
+	require.Equal(t, make([]byte, len(oldSKei)), oldSKei)
+	require.Nil(t, ikesaKey.SK_d)
+	require.Nil(t, ikesaKey.Encr_i)
+	require.Nil(t, ikesaKey.Integ_r)
+	require.Nil(t, ikesaKey.Prf_d)
+
+	require.Error(t, state.Commit())
+}
+
+func TestGenerateKeyForChildSAAfterIKESARekey(t *testing.T) {
+	proposal := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA2_256_128")
+	nonce := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+
+	ikesaKey, _, err := NewIKESAKey(proposal, []byte{0x05, 0x06, 0x07, 0x08}, nonce, 0x123, 0x456)
+	require.NoError(t, err)
+	rekeyed, _, err := ikesaKey.RekeyIKESAKey(proposal, []byte{0x09, 0x0a}, nonce, 0x789, 0xabc)
+	require.NoError(t, err)
+
+	childsaKey := &ChildSAKey{
+		EncrKInfo:  encr.StrToKType("ENCR_AES_CBC_256"),
+		IntegKInfo: integ.StrToKType("AUTH_HMAC_SHA1_96"),
+	}
+	err = childsaKey.GenerateKeyForChildSA(ikesaKey, nonce)
+	require.NoError(t, err)
+
+	err = childsaKey.GenerateKeyForChildSA(rekeyed, nonce)
+	require.ErrorIs(t, err, ErrParentSKdMismatch)
+
+	// A new child SA derives from the rekeyed IKE SA
+	newChildsaKey := &ChildSAKey{
+		EncrKInfo:  encr.StrToKType("ENCR_AES_CBC_256"),
+		IntegKInfo: integ.StrToKType("AUTH_HMAC_SHA1_96"),
+	}
+	err = newChildsaKey.GenerateKeyForChildSA(rekeyed, nonce)
+	require.NoError(t, err)
+	require.NotEqual(t, childsaKey.InitiatorToResponderEncryptionKey,
+		newChildsaKey.InitiatorToResponderEncryptionKey)
+}
+
+func TestGenerateKeyForChildSAFailureKeepsParent(t *testing.T) {
+	proposal := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA2_256_128")
+	nonce := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+
+	ikesaKey, _, err := NewIKESAKey(proposal, []byte{0x05, 0x06, 0x07, 0x08}, nonce, 0x123, 0x456)
+	require.NoError(t, err)
+	rekeyed, _, err := ikesaKey.RekeyIKESAKey(proposal, []byte{0x09, 0x0a}, nonce, 0x789, 0xabc)
+	require.NoError(t, err)
+
+	// A failed derivation records no parent, so the child SA can still be
+	// derived from another IKE SA
+	childsaKey := &ChildSAKey{
+		EncrKInfo:  encr.StrToKType("ENCR_AES_CBC_256"),
+		IntegKInfo: integ.StrToKType("AUTH_HMAC_SHA1_96"),
+	}
+	err = childsaKey.GenerateKeyForChildSA(ikesaKey, nonce, WithKDF(failingKDF{}))
+	require.Error(t, err)
+	err = childsaKey.GenerateKeyForChildSA(rekeyed, nonce)
+	require.NoError(t, err)
+}
+
+func TestBindTranscriptAfterChildSA(t *testing.T) {
+	transcriptHash := []byte{0x0a, 0x0b, 0x0c, 0x0d}
+	nonce := []byte{0x01, 0x02, 0x03, 0x04}
+	newChildSAKey := func() *ChildSAKey {
+		return &ChildSAKey{
+			EncrKInfo:  encr.StrToKType("ENCR_AES_CBC_256"),
+			IntegKInfo: integ.StrToKType("AUTH_HMAC_SHA1_96"),
+		}
+	}
+
+	// Binding before deriving child SAs binds their keys, and the child SA
+	// can be rekeyed from the bound SK_d
+	ikesaKey := newTestLabelIKESAKey(t)
+	require.NoError(t, ikesaKey.BindTranscript(transcriptHash))
+	childsaKey := newChildSAKey()
+	require.NoError(t, childsaKey.GenerateKeyForChildSA(ikesaKey, nonce))
+	require.NoError(t, childsaKey.GenerateKeyForChildSA(ikesaKey, nonce))
+
+	// Binding after deriving a child SA is refused, leaving SK_d unchanged
+	ikesaKey = newTestLabelIKESAKey(t)
+	require.NoError(t, newChildSAKey().GenerateKeyForChildSA(ikesaKey, nonce))
+	SK_d := append([]byte(nil), ikesaKey.SK_d...)
+	err := ikesaKey.BindTranscript(transcriptHash)
+	require.ErrorIs(t, err, ErrChildSAKeysDerived)
+	require.Equal(t, SK_d, ikesaKey.SK_d)
+}
+
+func TestRekeyChildSAWithPFS(t *testing.T) {
+	ikesaKey := newTestLabelIKESAKey(t)
+	ni := []byte{0x01, 0x02, 0x03, 0x04}
+	nr := []byte{0x05, 0x06, 0x07, 0x08}
+	shared := []byte{0x09, 0x0a, 0x0b, 0x0c}
+
+	old := &ChildSAKey{
+		SPI:          0x1234,
+		EncrKInfo:    encr.StrToKType("ENCR_AES_CBC_256"),
+		IntegKInfo:   integ.StrToKType("AUTH_HMAC_SHA1_96"),
+		NATTraversal: true,
+	}
+	require.NoError(t, old.GenerateKeyForChildSA(ikesaKey, append(append([]byte{}, ni...), nr...)))
+	oldKey := append([]byte{}, old.InitiatorToResponderEncryptionKey...)
+
+	rekeyed, err := ikesaKey.RekeyChildSAWithPFS(old, shared, ni, nr)
+	require.NoError(t, err)
+	require.Equal(t, old.EncrKInfo, rekeyed.EncrKInfo)
+	require.Equal(t, old.IntegKInfo, rekeyed.IntegKInfo)
+	require.True(t, rekeyed.NATTraversal)
+	require.Zero(t, rekeyed.SPI)
+
+	// The keys of a rekey without PFS are derived from Ni | Nr only
+	noPFS, err := DeriveChildKeysFromSKd(ikesaKey.SK_d, ikesaKey.PrfInfo, old.EncrKInfo, old.IntegKInfo,
+		append(append([]byte{}, ni...), nr...))
+	require.NoError(t, err)
+	require.NotEqual(t, noPFS.InitiatorToResponderEncryptionKey, rekeyed.InitiatorToResponderEncryptionKey)
+	require.NotEqual(t, noPFS.ResponderToInitiatorIntegrityKey, rekeyed.ResponderToInitiatorIntegrityKey)
+
+	// Same as deriving over g^ir | Ni | Nr
+	withPFS, err := DeriveChildKeysFromSKd(ikesaKey.SK_d, ikesaKey.PrfInfo, old.EncrKInfo, old.IntegKInfo,
+		append(append(append([]byte{}, shared...), ni...), nr...))
+	require.NoError(t, err)
+	require.Equal(t, withPFS.InitiatorToResponderEncryptionKey, rekeyed.InitiatorToResponderEncryptionKey)
+	require.Equal(t, withPFS.ResponderToInitiatorIntegrityKey, rekeyed.ResponderToInitiatorIntegrityKey)
+
+	// The old child SA is left untouched
+	require.Equal(t, oldKey, old.InitiatorToResponderEncryptionKey)
+
+	_, err = ikesaKey.RekeyChildSAWithPFS(old, nil, ni, nr)
+	require.ErrorIs(t, err, ErrEmptyKeyExchangeData)
+}
+
+func TestChildSAAcceptableESPRekey(t *testing.T) {
+	esnType, err := esn.StrToType("ESN_ENABLE")
+	require.NoError(t, err)
+	childsaKey := &ChildSAKey{
+		DhInfo:     dh.StrToType("DH_2048_BIT_MODP"),
+		EncrKInfo:  encr.StrToKType("ENCR_AES_CBC_256"),
+		IntegKInfo: integ.StrToKType("AUTH_HMAC_SHA2_256_128"),
+		EsnInfo:    esnType,
+	}
+
+	newRekeyProposal := func(modify func(*ChildSAKey)) *message.Proposal {
+		rekeyed := *childsaKey
+		modify(&rekeyed)
+		proposal, err := rekeyed.ToProposal()
+		require.NoError(t, err)
+		proposal.SPI = []byte{0x01, 0x02, 0x03, 0x04}
+		return proposal
+	}
+
+	require.NoError(t, childsaKey.AcceptableESPRekey(newRekeyProposal(func(*ChildSAKey) {})))
+
+	err = childsaKey.AcceptableESPRekey(newRekeyProposal(func(c *ChildSAKey) {
+		c.EncrKInfo = encr.StrToKType("ENCR_AES_CBC_128")
+	}))
+	require.ErrorIs(t, err, ErrUnacceptableRekey)
+
+	err = childsaKey.AcceptableESPRekey(newRekeyProposal(func(c *ChildSAKey) {
+		c.IntegKInfo = integ.StrToKType("AUTH_HMAC_SHA1_96")
+	}))
+	require.ErrorIs(t, err, ErrUnacceptableRekey)
+
+	err = childsaKey.AcceptableESPRekey(newRekeyProposal(func(c *ChildSAKey) {
+		c.DhInfo = dh.StrToType("DH_1024_BIT_MODP")
+	}))
+	require.ErrorIs(t, err, ErrUnacceptableRekey)
+
+	err = childsaKey.AcceptableESPRekey(newRekeyProposal(func(c *ChildSAKey) {
+		c.DhInfo = nil
+	}))
+	require.ErrorIs(t, err, ErrUnacceptableRekey)
+
+	// Only ESP is handled, AH and IKE proposals are rejected
+	for _, protocolID := range []uint8{message.TypeAH, message.TypeIKE} {
+		proposal := newRekeyProposal(func(*ChildSAKey) {})
+		proposal.ProtocolID = protocolID
+		require.ErrorIs(t, childsaKey.AcceptableESPRekey(proposal), ErrUnacceptableRekey, "protocol %d", protocolID)
+	}
+}
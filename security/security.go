@@ -3,8 +3,10 @@ package security
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
+	"fmt"
 	"hash"
 	"io"
 	"math/big"
@@ -19,7 +21,6 @@ import (
 	"github.com/nathaniel-bennett/ike/security/encr"
 	"github.com/nathaniel-bennett/ike/security/esn"
 	"github.com/nathaniel-bennett/ike/security/integ"
-	"github.com/nathaniel-bennett/ike/security/lib"
 	"github.com/nathaniel-bennett/ike/security/prf"
 )
 
@@ -49,6 +50,28 @@ func GenerateRandomNumber() (*big.Int, error) {
 	return number, nil
 }
 
+// GenerateSecret generates a Diffie-Hellman secret exponent
+func GenerateSecret() (dh.DHSecret, error) {
+	number, err := GenerateRandomNumber()
+	if err != nil {
+		return dh.DHSecret{}, errors.Wrapf(err, "GenerateSecret()")
+	}
+	return dh.NewDHSecret(number), nil
+}
+
+// GenerateSecretForGroup generates a Diffie-Hellman secret for dhType: an
+// exponent of exactly GetSecretBitLength bits for a MODP group
+func GenerateSecretForGroup(dhType dh.DHType) (dh.DHSecret, error) {
+	if dhType == nil {
+		return dh.DHSecret{}, errors.Errorf("GenerateSecretForGroup(): No Diffie-Hellman group")
+	}
+	secret, err := dhType.GenerateSecret()
+	if err != nil {
+		return dh.DHSecret{}, errors.Wrapf(err, "GenerateSecretForGroup()")
+	}
+	return secret, nil
+}
+
 func GenerateRandomUint8() (uint8, error) {
 	number := make([]byte, 1)
 	_, err := io.ReadFull(rand.Reader, number)
@@ -58,6 +81,31 @@ func GenerateRandomUint8() (uint8, error) {
 	return number[0], nil
 }
 
+// Minimum nonce length, as defined in RFC 7296 section 2.10
+const minimumNonceLength = 16
+
+// GenerateNonce generates a nonce sized for the negotiated PRF: at least 128
+// bits and at least half the key size of the PRF, as defined in RFC 7296
+// section 2.10. The same minimum applies to the IKE_SA_INIT exchange and to
+// the CREATE_CHILD_SA exchanges rekeying the IKE SA or a child SA, so this
+// helper is used for all of them.
+func GenerateNonce(prfType prf.PRFType) ([]byte, error) {
+	if prfType == nil {
+		return nil, errors.Errorf("GenerateNonce(): No pseudorandom function specified")
+	}
+
+	length := (prfType.GetKeyLength() + 1) / 2
+	if length < minimumNonceLength {
+		length = minimumNonceLength
+	}
+
+	nonce := make([]byte, length)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Errorf("GenerateNonce(): Read random failed: %+v", err)
+	}
+	return nonce, nil
+}
+
 func concatenateNonceAndSPI(nonce []byte, spi_initiator uint64, spi_responder uint64) []byte {
 	var newSlice []byte
 	spi := make([]byte, 8)
@@ -72,6 +120,10 @@ func concatenateNonceAndSPI(nonce []byte, spi_initiator uint64, spi_responder ui
 }
 
 type IKESAKey struct {
+	// Number of the proposal the IKE SA was created from, echoed in the
+	// response proposal
+	ProposalNumber uint8
+
 	// IKE SA transform types
 	DhInfo    dh.DHType
 	EncrInfo  encr.ENCRType
@@ -87,7 +139,7 @@ type IKESAKey struct {
 	Prf_i   hash.Hash           // used by initiator for IKE authentication
 	Prf_r   hash.Hash           // used by responder for IKE authentication
 
-	// Keys
+	// Keys, nil when they are held by a KeyStore
 	SK_d  []byte // used for child SA key deriving
 	SK_ai []byte // used by initiator for integrity checking
 	SK_ar []byte // used by responder for integrity checking
@@ -95,6 +147,22 @@ type IKESAKey struct {
 	SK_er []byte // used by responder for encrypting
 	SK_pi []byte // used by initiator for IKE authentication
 	SK_pr []byte // used by responder for IKE authentication
+
+	// Handles of the keys, by key label, when a KeyStore is given. The
+	// security objects are then built by the key store from the handles.
+	KeyHandles map[string]KeyHandle
+	keyStore   KeyStore
+
+	// Rekey lineage
+	generation uint      // number of rekeys since the initial IKE SA
+	parent     *IKESAKey // transforms of the rekeyed IKE SA, without keys
+
+	// Byte and time lifetimes, see SetLifetime
+	lifetime lifetime
+
+	// Set once child SA keys have been derived from SK_d, after which
+	// BindTranscript is refused
+	childSAKeysDerived bool
 }
 
 func (ikesaKey *IKESAKey) String() string {
@@ -102,8 +170,7 @@ func (ikesaKey *IKESAKey) String() string {
 		strconv.FormatUint(uint64(ikesaKey.EncrInfo.TransformID()), 10) +
 		"\nSK_ei: " + hex.EncodeToString(ikesaKey.SK_ei) +
 		"\nSK_er: " + hex.EncodeToString(ikesaKey.SK_er) +
-		"\nIntegrity Algorithm: " +
-		strconv.FormatUint(uint64(ikesaKey.IntegInfo.TransformID()), 10) +
+		"\nIntegrity Algorithm: " + integrityAlgorithmString(ikesaKey.IntegInfo) +
 		"\nSK_ai: " + hex.EncodeToString(ikesaKey.SK_ai) +
 		"\nSK_ar: " + hex.EncodeToString(ikesaKey.SK_ar) +
 		"\nSK_pi: " + hex.EncodeToString(ikesaKey.SK_pi) +
@@ -111,8 +178,16 @@ func (ikesaKey *IKESAKey) String() string {
 		"\nSK_d : " + hex.EncodeToString(ikesaKey.SK_d) + "\n"
 }
 
+func integrityAlgorithmString(integType integ.INTEGType) string {
+	if integType == nil {
+		return "none"
+	}
+	return strconv.FormatUint(uint64(integType.TransformID()), 10)
+}
+
 func (ikesaKey *IKESAKey) ToProposal() (*message.Proposal, error) {
 	p := new(message.Proposal)
+	p.ProposalNumber = ikesaKey.ProposalNumber
 	p.ProtocolID = message.TypeIKE
 	p.DiffieHellmanGroup = append(p.DiffieHellmanGroup, dh.ToTransform(ikesaKey.DhInfo))
 	p.PseudorandomFunction = append(p.PseudorandomFunction, prf.ToTransform(ikesaKey.PrfInfo))
@@ -121,75 +196,294 @@ func (ikesaKey *IKESAKey) ToProposal() (*message.Proposal, error) {
 		return nil, errors.Wrapf(err, "IKESAKey ToProposal")
 	}
 	p.EncryptionAlgorithm = append(p.EncryptionAlgorithm, encrTranform)
-	p.IntegrityAlgorithm = append(p.IntegrityAlgorithm, integ.ToTransform(ikesaKey.IntegInfo))
+	if ikesaKey.IntegInfo != nil {
+		p.IntegrityAlgorithm = append(p.IntegrityAlgorithm, integ.ToTransform(ikesaKey.IntegInfo))
+	}
 	return p, nil
 }
 
+// ICVOffset returns where the ICV starts in an encrypted payload of
+// payloadLen bytes (IV, ciphertext and ICV), and the ICV length, which is the
+// output length of the negotiated integrity algorithm, or the ICV length of
+// an AEAD cipher. The offset is 0 when payloadLen is shorter than the ICV, so
+// callers must check that payloadLen is at least icvLen.
+func (ikesaKey *IKESAKey) ICVOffset(payloadLen int) (offset, icvLen int) {
+	icvLen = ikesaKey.icvLength()
+	offset = payloadLen - icvLen
+	if offset < 0 {
+		offset = 0
+	}
+	return offset, icvLen
+}
+
+// isAEAD reports whether the negotiated encryption algorithm also provides
+// integrity
+func (ikesaKey *IKESAKey) isAEAD() bool {
+	return ikesaKey.EncrInfo != nil && ikesaKey.EncrInfo.IsAEAD()
+}
+
+func (ikesaKey *IKESAKey) icvLength() int {
+	switch {
+	case ikesaKey.isAEAD():
+		return ikesaKey.EncrInfo.GetICVLength()
+	case ikesaKey.IntegInfo != nil:
+		return ikesaKey.IntegInfo.GetOutputLength()
+	default:
+		return 0
+	}
+}
+
+// Overhead of an unfragmented encrypted IKE message besides the IV, padding
+// and ICV: IPv4 and UDP headers, IKE header and Encrypted payload header
+const encryptedMessageOverhead = 20 + 8 + message.IKE_HEADER_LEN + 4
+
+// FragmentationThreshold returns the maximum number of plaintext bytes an
+// encrypted IKE message sent over IPv4 can carry without exceeding pathMTU,
+// given the IV, padding and ICV of the negotiated algorithms. A larger message
+// has to be fragmented as defined in RFC 7383. It returns 0 if not even an
+// empty message fits.
+func (ikesaKey *IKESAKey) FragmentationThreshold(pathMTU int) int {
+	available := pathMTU - encryptedMessageOverhead
+	blockSize := 1
+	if ikesaKey.EncrInfo != nil {
+		available -= ikesaKey.EncrInfo.GetIVLength()
+		blockSize = ikesaKey.EncrInfo.GetBlockSize()
+	}
+	available -= ikesaKey.icvLength()
+
+	// The ciphertext is a whole number of blocks, ending with the pad length
+	// byte
+	threshold := available - available%blockSize - 1
+	if threshold < 0 {
+		return 0
+	}
+	return threshold
+}
+
+// Fingerprint returns a hex encoded SHA-256 over the SPIs and the transforms
+// of the IKE SA, to index or log an SA without exposing its keys. Two IKE SAs
+// with the same SPIs and transforms have the same fingerprint whatever their
+// keys are.
+func (ikesaKey *IKESAKey) Fingerprint(initiatorSPI, responderSPI uint64) string {
+	h := sha256.New()
+	_, _ = h.Write(concatenateNonceAndSPI(nil, initiatorSPI, responderSPI))
+
+	var transforms []*message.Transform
+	if ikesaKey.EncrInfo != nil {
+		if t, err := encr.ToTransform(ikesaKey.EncrInfo); err == nil {
+			transforms = append(transforms, t)
+		}
+	}
+	if ikesaKey.PrfInfo != nil {
+		transforms = append(transforms, prf.ToTransform(ikesaKey.PrfInfo))
+	}
+	if ikesaKey.IntegInfo != nil {
+		transforms = append(transforms, integ.ToTransform(ikesaKey.IntegInfo))
+	}
+	if ikesaKey.DhInfo != nil {
+		transforms = append(transforms, dh.ToTransform(ikesaKey.DhInfo))
+	}
+
+	b := make([]byte, 5)
+	for _, t := range transforms {
+		b[0] = t.TransformType
+		binary.BigEndian.PutUint16(b[1:3], t.TransformID)
+		binary.BigEndian.PutUint16(b[3:5], t.AttributeValue)
+		_, _ = h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+var ErrChildSAKeysDerived = errors.New("child SA keys already derived from SK_d")
+
+// BindTranscript folds the hash of a message transcript into SK_d:
+// SK_d = prf(SK_d, transcriptHash)
+// and resets Prf_d accordingly, so that the keys of the child SAs derived
+// afterwards are bound to the transcript. It is used by extensions requiring
+// channel binding; the other keys are left unchanged.
+//
+// It must be called before any child SA keys are derived from the IKE SA:
+// a child SA derived from the previous SK_d could not be rekeyed from the
+// new one (see GenerateKeyForChildSA), so ErrChildSAKeysDerived is returned
+// instead.
+func (ikesaKey *IKESAKey) BindTranscript(transcriptHash []byte) error {
+	if ikesaKey.childSAKeysDerived {
+		return errors.WithStack(ErrChildSAKeysDerived)
+	}
+	if ikesaKey.PrfInfo == nil {
+		return errors.Errorf("BindTranscript : No pseudorandom function specified")
+	}
+	if !ikesaKey.hasSKd() {
+		return errors.Errorf("BindTranscript : No SK_d")
+	}
+	if len(transcriptHash) == 0 {
+		return errors.Errorf("BindTranscript : No transcript hash")
+	}
+
+	prfSKd, err := ikesaKey.keyedPRF(KeyLabelSK_d, ikesaKey.SK_d, prf.PRFType.Init)
+	if err != nil {
+		return errors.Wrapf(err, "BindTranscript")
+	}
+	if _, err = prfSKd.Write(transcriptHash); err != nil {
+		return errors.Wrapf(err, "BindTranscript")
+	}
+	if ikesaKey.keyStore != nil {
+		if err = ikesaKey.replaceStoredSKd(prfSKd.Sum(nil)); err != nil {
+			return errors.Wrapf(err, "BindTranscript : KeyStore")
+		}
+		return nil
+	}
+	ikesaKey.SK_d = prfSKd.Sum(nil)
+	ikesaKey.Prf_d = ikesaKey.PrfInfo.Init(ikesaKey.SK_d)
+	return nil
+}
+
 // return IKESAKey and local public value
 func NewIKESAKey(
 	proposal *message.Proposal,
 	keyExchangeData, concatenatedNonce []byte,
 	initiatorSPI, responderSPI uint64,
+	opts ...Option,
 ) (*IKESAKey, []byte, error) {
+	ikesaKey, err := newIKESAKeyByProposal(proposal)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "NewIKESAKey")
+	}
+
+	localPublicValue, sharedKeyData, err := CalculateDiffieHellmanMaterials(
+		ikesaKey, keyExchangeData)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "NewIKESAKey")
+	}
+
+	err = ikesaKey.GenerateKeyForIKESA(concatenatedNonce, sharedKeyData,
+		initiatorSPI, responderSPI, opts...)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "NewIKESAKey")
+	}
+
+	return ikesaKey, localPublicValue, nil
+}
+
+var (
+	ErrNoPRF          = errors.New("no pseudorandom function offered")
+	ErrUnsupportedPRF = errors.New("unsupported pseudorandom function")
+)
+
+var ErrUnsupportedDHGroup = errors.New("unsupported Diffie-Hellman group")
+
+// UnsupportedDHGroupError is returned when a proposal carries a
+// Diffie-Hellman group that is not supported. It matches
+// ErrUnsupportedDHGroup with errors.Is, and lists the supported groups so
+// that the responder can name one in the INVALID_KE_PAYLOAD notify. The
+// groups are listed from the most preferred.
+type UnsupportedDHGroupError struct {
+	// Transform ID of the proposed group
+	TransformID uint16
+	// Transform IDs of the supported groups, by preference
+	SupportedGroups []uint16
+}
+
+func newUnsupportedDHGroupError(transformID uint16) *UnsupportedDHGroupError {
+	return &UnsupportedDHGroupError{
+		TransformID:     transformID,
+		SupportedGroups: dh.SupportedGroupsByPreference(),
+	}
+}
+
+func (e *UnsupportedDHGroupError) Error() string {
+	return fmt.Sprintf("%s %d, supported groups %v", ErrUnsupportedDHGroup, e.TransformID, e.SupportedGroups)
+}
+
+func (e *UnsupportedDHGroupError) Is(target error) bool {
+	return target == ErrUnsupportedDHGroup
+}
+
+// checkAEADIntegrity returns an error if the proposal, whose encryption
+// algorithm is an AEAD cipher, carries an integrity algorithm other than NONE
+func checkAEADIntegrity(proposal *message.Proposal) error {
+	for _, t := range proposal.IntegrityAlgorithm {
+		if t.TransformID != message.AUTH_NONE {
+			return errors.Errorf("IntegrityAlgorithm[%v] along with combined mode EncryptionAlgorithm[%v]",
+				t.TransformID, proposal.EncryptionAlgorithm[0].TransformID)
+		}
+	}
+	return nil
+}
+
+// newIKESAKeyByProposal returns an IKESAKey holding the transforms of the
+// proposal, without any key
+func newIKESAKeyByProposal(proposal *message.Proposal) (*IKESAKey, error) {
 	if proposal == nil {
-		return nil, nil, errors.Errorf("NewIKESAKey : proposal is nil")
+		return nil, errors.Errorf("proposal is nil")
 	}
 	if len(proposal.DiffieHellmanGroup) == 0 {
-		return nil, nil, errors.Errorf("NewIKESAKey : DiffieHellmanGroup is nil")
+		return nil, missingTransformError(message.TypeDiffieHellmanGroup)
 	}
 
 	if len(proposal.EncryptionAlgorithm) == 0 {
-		return nil, nil, errors.Errorf("NewIKESAKey : EncryptionAlgorithm is nil")
+		return nil, missingTransformError(message.TypeEncryptionAlgorithm)
 	}
 
-	if len(proposal.IntegrityAlgorithm) == 0 {
-		return nil, nil, errors.Errorf("NewIKESAKey : IntegrityAlgorithm is nil")
+	if len(proposal.PseudorandomFunction) == 0 {
+		return nil, errors.WithStack(ErrNoPRF)
 	}
 
-	if len(proposal.PseudorandomFunction) == 0 {
-		return nil, nil, errors.Errorf("NewIKESAKey : PseudorandomFunction is nil")
+	if err := checkTransformTypes(proposal); err != nil {
+		return nil, err
+	}
+	if err := checkUnexpectedTransforms(proposal); err != nil {
+		return nil, err
 	}
 
 	ikesaKey := new(IKESAKey)
+	ikesaKey.ProposalNumber = proposal.ProposalNumber
 	ikesaKey.DhInfo = dh.DecodeTransform(proposal.DiffieHellmanGroup[0])
 	if ikesaKey.DhInfo == nil {
-		return nil, nil, errors.Errorf("NewIKESAKey : Get unsupport DiffieHellmanGroup[%v]",
-			proposal.DiffieHellmanGroup[0].TransformID)
+		return nil, errors.WithStack(newUnsupportedDHGroupError(proposal.DiffieHellmanGroup[0].TransformID))
 	}
 
 	ikesaKey.EncrInfo = encr.DecodeTransform(proposal.EncryptionAlgorithm[0])
 	if ikesaKey.EncrInfo == nil {
-		return nil, nil, errors.Errorf("NewIKESAKey : Get unsupport EncryptionAlgorithm[%v]",
+		if err := encr.CheckTransform(proposal.EncryptionAlgorithm[0]); err != nil {
+			return nil, err
+		}
+		return nil, errors.Errorf("Get unsupport EncryptionAlgorithm[%v]",
 			proposal.EncryptionAlgorithm[0].TransformID)
 	}
 
-	ikesaKey.IntegInfo = integ.DecodeTransform(proposal.IntegrityAlgorithm[0])
-	if ikesaKey.EncrInfo == nil {
-		return nil, nil, errors.Errorf("NewIKESAKey : Get unsupport IntegrityAlgorithm[%v]",
-			proposal.IntegrityAlgorithm[0].TransformID)
+	// An AEAD cipher provides integrity itself: RFC 5282 section 8 allows no
+	// integrity transform other than NONE along with it
+	if ikesaKey.EncrInfo.IsAEAD() {
+		if err := checkAEADIntegrity(proposal); err != nil {
+			return nil, err
+		}
+	} else {
+		if len(proposal.IntegrityAlgorithm) == 0 {
+			return nil, missingTransformError(message.TypeIntegrityAlgorithm)
+		}
+
+		ikesaKey.IntegInfo = integ.DecodeTransform(proposal.IntegrityAlgorithm[0])
+		if ikesaKey.IntegInfo == nil {
+			return nil, errors.Errorf("Get unsupport IntegrityAlgorithm[%v]",
+				proposal.IntegrityAlgorithm[0].TransformID)
+		}
 	}
 
 	ikesaKey.PrfInfo = prf.DecodeTransform(proposal.PseudorandomFunction[0])
 	if ikesaKey.PrfInfo == nil {
-		return nil, nil, errors.Errorf("NewIKESAKey : Get unsupport PseudorandomFunction[%v]",
+		return nil, errors.Wrapf(ErrUnsupportedPRF, "PseudorandomFunction[%v]",
 			proposal.PseudorandomFunction[0].TransformID)
 	}
 
-	localPublicValue, sharedKeyData, err := CalculateDiffieHellmanMaterials(
-		ikesaKey, keyExchangeData)
-	if err != nil {
-		return nil, nil, errors.Wrapf(err, "NewIKESAKey")
-	}
-
-	err = ikesaKey.GenerateKeyForIKESA(concatenatedNonce, sharedKeyData,
-		initiatorSPI, responderSPI)
-	if err != nil {
-		return nil, nil, errors.Wrapf(err, "NewIKESAKey")
-	}
-
-	return ikesaKey, localPublicValue, nil
+	return ikesaKey, nil
 }
 
+var (
+	ErrEmptyKeyExchangeData = errors.New("empty key exchange data")
+	ErrReflectedSPI         = errors.New("initiator and responder SPIs are equal")
+)
+
 // CalculateDiffieHellmanMaterials generates secret and calculate Diffie-Hellman public key
 // exchange material.
 // Peer public value as parameter, return local public value and shared key.
@@ -197,18 +491,55 @@ func CalculateDiffieHellmanMaterials(
 	ikesaKey *IKESAKey,
 	peerPublicValue []byte,
 ) ([]byte, []byte, error) {
-	secret, err := GenerateRandomNumber()
+	// An empty peer public value is read as 0, which gives an all-zero
+	// shared key
+	if len(peerPublicValue) == 0 {
+		return nil, nil, errors.Wrapf(ErrEmptyKeyExchangeData, "CalculateDiffieHellmanMaterials()")
+	}
+
+	if err := ikesaKey.DhInfo.ValidatePublicValue(peerPublicValue); err != nil {
+		return nil, nil, errors.Wrapf(err, "CalculateDiffieHellmanMaterials()")
+	}
+
+	secret, err := GenerateSecretForGroup(ikesaKey.DhInfo)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "CalculateDiffieHellmanMaterials()")
 	}
+	// The secret is not needed once the shared key is computed
+	defer secret.Zeroize()
 
-	peerPublicValueBig := new(big.Int).SetBytes(peerPublicValue)
-	return ikesaKey.DhInfo.GetPublicValue(secret), ikesaKey.DhInfo.GetSharedKey(secret, peerPublicValueBig), nil
+	localPublicValue, err := ikesaKey.DhInfo.GetPublicValue(secret)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "CalculateDiffieHellmanMaterials()")
+	}
+	sharedKey, err := ikesaKey.DhInfo.GetSharedKey(secret, peerPublicValue)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "CalculateDiffieHellmanMaterials()")
+	}
+	return localPublicValue, sharedKey, nil
 }
 
+// GenerateKeyForIKESA computes SKEYSEED and derives the IKE SA keys from
+// it. With a PRF taking a fixed-length key, SKEYSEED is keyed by
+// Ni[0..63] | Nr[0..63] (the first 64 bits of each nonce), Ni and Nr being
+// taken as the two halves of concatenatedNonce:
+// GenerateKeyForIKESAFromNonces handles nonces of different lengths.
 func (ikesaKey *IKESAKey) GenerateKeyForIKESA(
 	concatenatedNonce, diffieHellmanSharedKey []byte,
 	initiatorSPI, responderSPI uint64,
+	opts ...Option,
+) error {
+	return ikesaKey.generateKeyForIKESA(concatenatedNonce, len(concatenatedNonce)/2, diffieHellmanSharedKey,
+		initiatorSPI, responderSPI, opts...)
+}
+
+// generateKeyForIKESA is GenerateKeyForIKESA, Ni being the first
+// initiatorNonceLength bytes of concatenatedNonce
+func (ikesaKey *IKESAKey) generateKeyForIKESA(
+	concatenatedNonce []byte, initiatorNonceLength int,
+	diffieHellmanSharedKey []byte,
+	initiatorSPI, responderSPI uint64,
+	opts ...Option,
 ) error {
 	// Check parameters
 	if ikesaKey == nil {
@@ -219,7 +550,7 @@ func (ikesaKey *IKESAKey) GenerateKeyForIKESA(
 	if ikesaKey.EncrInfo == nil {
 		return errors.Errorf("No encryption algorithm specified")
 	}
-	if ikesaKey.IntegInfo == nil {
+	if ikesaKey.IntegInfo == nil && !ikesaKey.EncrInfo.IsAEAD() {
 		return errors.Errorf("No integrity algorithm specified")
 	}
 	if ikesaKey.PrfInfo == nil {
@@ -235,12 +566,91 @@ func (ikesaKey *IKESAKey) GenerateKeyForIKESA(
 	if len(diffieHellmanSharedKey) == 0 {
 		return errors.Errorf("No Diffie-Hellman shared key")
 	}
+	// Equal SPIs mean the peer reflected our own IKE_SA_INIT back
+	if initiatorSPI == responderSPI {
+		return errors.Wrapf(ErrReflectedSPI, "SPI 0x%x", initiatorSPI)
+	}
+
+	// Generate IKE SA key as defined in RFC7296 Section 1.3 and Section 1.4
+	// fmt.Printf("Concatenated nonce:\n%s", hex.Dump(concatenatedNonce))
+	// fmt.Printf("DH shared key:\n%s", hex.Dump(diffieHellmanSharedKey))
+
+	o := newOptions(opts)
+	prf := o.initPRF(ikesaKey.PrfInfo, skeyseedKey(ikesaKey.PrfInfo,
+		concatenatedNonce[:initiatorNonceLength], concatenatedNonce[initiatorNonceLength:]))
+	if _, err := prf.Write(diffieHellmanSharedKey); err != nil {
+		return err
+	}
+
+	skeyseed := prf.Sum(nil)
+
+	// fmt.Printf("SKEYSEED:\n%s", hex.Dump(skeyseed))
+
+	return ikesaKey.deriveKeysFromSKEYSEED(skeyseed, concatenatedNonce, initiatorSPI, responderSPI, o)
+}
+
+// GenerateKeyForIKESAFromNonces is GenerateKeyForIKESA taking the nonces of
+// the initiator and of the responder separately, and concatenating them in
+// the Ni | Nr order defined in RFC 7296 section 2.14
+func (ikesaKey *IKESAKey) GenerateKeyForIKESAFromNonces(
+	initiatorNonce, responderNonce, diffieHellmanSharedKey []byte,
+	initiatorSPI, responderSPI uint64,
+	opts ...Option,
+) error {
+	if len(initiatorNonce) == 0 || len(responderNonce) == 0 {
+		return errors.Errorf("No nonce data")
+	}
+
+	concatenatedNonce := make([]byte, 0, len(initiatorNonce)+len(responderNonce))
+	concatenatedNonce = append(concatenatedNonce, initiatorNonce...)
+	concatenatedNonce = append(concatenatedNonce, responderNonce...)
+
+	return ikesaKey.generateKeyForIKESA(concatenatedNonce, len(initiatorNonce), diffieHellmanSharedKey,
+		initiatorSPI, responderSPI, opts...)
+}
+
+// skeyseedKey returns the key of the PRF computing SKEYSEED, RFC 7296
+// section 2.14: Ni | Nr, but Ni[0..63] | Nr[0..63] (the first 64 bits of
+// each nonce) for a PRF taking a fixed-length key
+func skeyseedKey(prfType prf.PRFType, initiatorNonce, responderNonce []byte) []byte {
+	if prfType.IsFixedKeyLength() {
+		half := prfType.GetKeyLength() / 2
+		if len(initiatorNonce) > half {
+			initiatorNonce = initiatorNonce[:half]
+		}
+		if len(responderNonce) > half {
+			responderNonce = responderNonce[:half]
+		}
+	}
+	key := make([]byte, 0, len(initiatorNonce)+len(responderNonce))
+	key = append(key, initiatorNonce...)
+	return append(key, responderNonce...)
+}
 
+// deriveKeysFromSKEYSEED expands SKEYSEED into SK_d, SK_ai, SK_ar, SK_ei,
+// SK_er, SK_pi and SK_pr with prf+ and sets up the security objects, as
+// defined in RFC 7296 section 2.14. With an AEAD cipher, SK_ai and SK_ar are
+// empty and no integrity object is set up. It is the tail shared by every
+// way of computing SKEYSEED, GenerateKeyForIKESA and RekeyIKESAKey, and must
+// also be the one of a feature producing its own SKEYSEED, such as PPK
+// (RFC 8784) or session resumption (RFC 5723), so that the keys are always
+// sliced the same way.
+func (ikesaKey *IKESAKey) deriveKeysFromSKEYSEED(
+	skeyseed, concatenatedNonce []byte,
+	initiatorSPI, responderSPI uint64,
+	o *options,
+) error {
 	// Get key length of SK_d, SK_ai, SK_ar, SK_ei, SK_er, SK_pi, SK_pr
 	var length_SK_d, length_SK_ai, length_SK_ar, length_SK_ei, length_SK_er, length_SK_pi, length_SK_pr, totalKeyLength int
 
 	length_SK_d = ikesaKey.PrfInfo.GetKeyLength()
-	length_SK_ai = ikesaKey.IntegInfo.GetKeyLength()
+	if !ikesaKey.EncrInfo.IsAEAD() {
+		length_SK_ai = ikesaKey.IntegInfo.GetKeyLength()
+		if length_SK_ai <= 0 {
+			return errors.Errorf("Integrity algorithm[%d] has invalid key length %d",
+				ikesaKey.IntegInfo.TransformID(), length_SK_ai)
+		}
+	}
 	length_SK_ar = length_SK_ai
 	length_SK_ei = ikesaKey.EncrInfo.GetKeyLength()
 	length_SK_er = length_SK_ei
@@ -248,66 +658,115 @@ func (ikesaKey *IKESAKey) GenerateKeyForIKESA(
 
 	totalKeyLength = length_SK_d + length_SK_ai + length_SK_ar + length_SK_ei + length_SK_er + length_SK_pi + length_SK_pr
 
-	// Generate IKE SA key as defined in RFC7296 Section 1.3 and Section 1.4
-	// fmt.Printf("Concatenated nonce:\n%s", hex.Dump(concatenatedNonce))
-	// fmt.Printf("DH shared key:\n%s", hex.Dump(diffieHellmanSharedKey))
-
-	prf := ikesaKey.PrfInfo.Init(concatenatedNonce)
-	if _, err := prf.Write(diffieHellmanSharedKey); err != nil {
-		return err
-	}
-
-	skeyseed := prf.Sum(nil)
 	seed := concatenateNonceAndSPI(concatenatedNonce, initiatorSPI, responderSPI)
+	seed = append(seed, o.label...)
 
-	// fmt.Printf("SKEYSEED:\n%s", hex.Dump(skeyseed))
-
-	keyStream := lib.PrfPlus(ikesaKey.PrfInfo.Init(skeyseed), seed, totalKeyLength)
-	if keyStream == nil {
-		return errors.Errorf("Error happened in PrfPlus")
+	keyStream, err := expand(o.kdf, o.initPRF(ikesaKey.PrfInfo, skeyseed), seed, totalKeyLength)
+	if err != nil {
+		ikesaKey.Wipe()
+		return err
 	}
 
-	// Assign keys into context
-	ikesaKey.SK_d = keyStream[:length_SK_d]
+	// Assign keys into context. Each key is capped to its length, so that an
+	// append to one key cannot overwrite the next one in the keymat.
+	ikesaKey.SK_d = keyStream[:length_SK_d:length_SK_d]
 	keyStream = keyStream[length_SK_d:]
-	ikesaKey.SK_ai = keyStream[:length_SK_ai]
+	ikesaKey.SK_ai = keyStream[:length_SK_ai:length_SK_ai]
 	keyStream = keyStream[length_SK_ai:]
-	ikesaKey.SK_ar = keyStream[:length_SK_ar]
+	ikesaKey.SK_ar = keyStream[:length_SK_ar:length_SK_ar]
 	keyStream = keyStream[length_SK_ar:]
-	ikesaKey.SK_ei = keyStream[:length_SK_ei]
+	ikesaKey.SK_ei = keyStream[:length_SK_ei:length_SK_ei]
 	keyStream = keyStream[length_SK_ei:]
-	ikesaKey.SK_er = keyStream[:length_SK_er]
+	ikesaKey.SK_er = keyStream[:length_SK_er:length_SK_er]
 	keyStream = keyStream[length_SK_er:]
-	ikesaKey.SK_pi = keyStream[:length_SK_pi]
+	ikesaKey.SK_pi = keyStream[:length_SK_pi:length_SK_pi]
 	keyStream = keyStream[length_SK_pi:]
-	ikesaKey.SK_pr = keyStream[:length_SK_pr]
+	ikesaKey.SK_pr = keyStream[:length_SK_pr:length_SK_pr]
+
+	if o.keyStore != nil {
+		if err = ikesaKey.storeKeys(o.keyStore); err != nil {
+			ikesaKey.Wipe()
+			return errors.Wrapf(err, "KeyStore")
+		}
+		return nil
+	}
 
 	// Set security objects
-	ikesaKey.Prf_d = ikesaKey.PrfInfo.Init(ikesaKey.SK_d)
-	ikesaKey.Integ_i = ikesaKey.IntegInfo.Init(ikesaKey.SK_ai)
-	ikesaKey.Integ_r = ikesaKey.IntegInfo.Init(ikesaKey.SK_ar)
+	ikesaKey.Prf_d = o.initPRF(ikesaKey.PrfInfo, ikesaKey.SK_d)
+	if !ikesaKey.EncrInfo.IsAEAD() {
+		ikesaKey.Integ_i = ikesaKey.IntegInfo.Init(ikesaKey.SK_ai)
+		ikesaKey.Integ_r = ikesaKey.IntegInfo.Init(ikesaKey.SK_ar)
+	}
 
-	var err error
 	ikesaKey.Encr_i, err = ikesaKey.EncrInfo.NewCrypto(ikesaKey.SK_ei)
 	if err != nil {
+		ikesaKey.Wipe()
 		return err
 	}
 
 	ikesaKey.Encr_r, err = ikesaKey.EncrInfo.NewCrypto(ikesaKey.SK_er)
 	if err != nil {
+		ikesaKey.Wipe()
 		return err
 	}
 
-	ikesaKey.Prf_i = ikesaKey.PrfInfo.Init(ikesaKey.SK_pi)
-	ikesaKey.Prf_r = ikesaKey.PrfInfo.Init(ikesaKey.SK_pr)
+	ikesaKey.Prf_i = o.initPRF(ikesaKey.PrfInfo, ikesaKey.SK_pi)
+	ikesaKey.Prf_r = o.initPRF(ikesaKey.PrfInfo, ikesaKey.SK_pr)
 
 	return nil
 }
 
+// Wipe overwrites the keys of the IKE SA with zeros and drops them along with
+// the security objects and key handles, deleting the keys held by a KeyStore,
+// so that a torn down IKE SA, or one whose derivation failed half way, cannot
+// be used and leaves no key behind. It is best effort: copies made by the Go
+// runtime, such as when the garbage collector moves memory, the key state
+// held inside the security objects (the HMAC pads, the expanded cipher keys)
+// and keys the KeyStore fails to delete are not reached.
+func (ikesaKey *IKESAKey) Wipe() {
+	if ikesaKey == nil {
+		return
+	}
+	ikesaKey.wipeKeys()
+
+	ikesaKey.Prf_d = nil
+	ikesaKey.Integ_i, ikesaKey.Integ_r = nil, nil
+	ikesaKey.Encr_i, ikesaKey.Encr_r = nil, nil
+	ikesaKey.Prf_i, ikesaKey.Prf_r = nil, nil
+
+	ikesaKey.deleteStoredKeys()
+	ikesaKey.KeyHandles = nil
+	ikesaKey.keyStore = nil
+}
+
+// wipeKeys overwrites the keys of the IKE SA with zeros and drops them
+func (ikesaKey *IKESAKey) wipeKeys() {
+	for _, key := range [][]byte{
+		ikesaKey.SK_d, ikesaKey.SK_ai, ikesaKey.SK_ar, ikesaKey.SK_ei,
+		ikesaKey.SK_er, ikesaKey.SK_pi, ikesaKey.SK_pr,
+	} {
+		wipeBytes(key)
+	}
+	ikesaKey.SK_d, ikesaKey.SK_ai, ikesaKey.SK_ar = nil, nil, nil
+	ikesaKey.SK_ei, ikesaKey.SK_er = nil, nil
+	ikesaKey.SK_pi, ikesaKey.SK_pr = nil, nil
+}
+
+// wipeBytes overwrites b with zeros
+func wipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
 type ChildSAKey struct {
 	// SPI
 	SPI uint32
 
+	// Number of the proposal the child SA was created from, echoed in the
+	// response proposal
+	ProposalNumber uint8
+
 	// Child SA transform types
 	DhInfo     dh.DHType
 	EncrKInfo  encr.ENCRKType
@@ -319,11 +778,41 @@ type ChildSAKey struct {
 	ResponderToInitiatorEncryptionKey []byte
 	InitiatorToResponderIntegrityKey  []byte
 	ResponderToInitiatorIntegrityKey  []byte
+
+	// NAT traversal, set by the caller when the NAT_DETECTION_*_IP notifies
+	// show a NAT between the peers. It does not affect the keymat, but the SA
+	// must be installed with UDP encapsulation (RFC 3948) when it is set.
+	NATTraversal bool
+
+	// SHA-256 of the SK_d the keys were derived from
+	parentSKdFingerprint []byte
 }
 
+// Wipe overwrites the keys of the child SA with zeros and drops them. It is
+// best effort in the same way as IKESAKey.Wipe.
+func (childsaKey *ChildSAKey) Wipe() {
+	if childsaKey == nil {
+		return
+	}
+	for _, key := range [][]byte{
+		childsaKey.InitiatorToResponderEncryptionKey, childsaKey.ResponderToInitiatorEncryptionKey,
+		childsaKey.InitiatorToResponderIntegrityKey, childsaKey.ResponderToInitiatorIntegrityKey,
+	} {
+		wipeBytes(key)
+	}
+	childsaKey.InitiatorToResponderEncryptionKey, childsaKey.ResponderToInitiatorEncryptionKey = nil, nil
+	childsaKey.InitiatorToResponderIntegrityKey, childsaKey.ResponderToInitiatorIntegrityKey = nil, nil
+	childsaKey.parentSKdFingerprint = nil
+}
+
+// ToProposal returns the ESP proposal of the child SA, carrying its SPI, so
+// that NewChildSAKeyByProposal gives back the same child SA
 func (childsaKey *ChildSAKey) ToProposal() (*message.Proposal, error) {
 	p := new(message.Proposal)
+	p.ProposalNumber = childsaKey.ProposalNumber
 	p.ProtocolID = message.TypeESP
+	p.SPI = make([]byte, childSPISize)
+	binary.BigEndian.PutUint32(p.SPI, childsaKey.SPI)
 	if childsaKey.DhInfo != nil {
 		p.DiffieHellmanGroup = append(p.DiffieHellmanGroup, dh.ToTransform(childsaKey.DhInfo))
 	}
@@ -339,39 +828,77 @@ func (childsaKey *ChildSAKey) ToProposal() (*message.Proposal, error) {
 	return p, nil
 }
 
-func NewChildSAKeyByProposal(proposal *message.Proposal) (*ChildSAKey, error) {
+var (
+	ErrMissingESNTransform = errors.New("ESN transform missing from proposal")
+	ErrInvalidChildSPI     = errors.New("invalid child SA SPI")
+)
+
+// Size of the SPI of an ESP or AH proposal, RFC 7296 section 3.3.1
+const childSPISize = 4
+
+// childSPI returns the SPI of an ESP or AH proposal, which must be 4 bytes
+// long, and ErrInvalidChildSPI otherwise
+func childSPI(proposal *message.Proposal) (uint32, error) {
+	if len(proposal.SPI) != childSPISize {
+		return 0, errors.Wrapf(ErrInvalidChildSPI, "protocol %d: SPI size %d, expected %d",
+			proposal.ProtocolID, len(proposal.SPI), childSPISize)
+	}
+	return binary.BigEndian.Uint32(proposal.SPI), nil
+}
+
+// NewChildSAKeyByProposal returns a ChildSAKey holding the transforms of
+// proposal. A proposal without the ESN transform is rejected with
+// ErrMissingESNTransform, which maps to NO_PROPOSAL_CHOSEN, unless the
+// WithPermissiveESN option is given. The SPI of an ESP or AH proposal is
+// stored in ChildSAKey.SPI, and ErrInvalidChildSPI is returned if it is not 4
+// bytes long.
+func NewChildSAKeyByProposal(proposal *message.Proposal, opts ...Option) (*ChildSAKey, error) {
 	if proposal == nil {
 		return nil, errors.Errorf("NewChildSAKeyByProposal : proposal is nil")
 	}
 
 	if len(proposal.EncryptionAlgorithm) == 0 {
-		return nil, errors.Errorf("NewChildSAKeyByProposal : EncryptionAlgorithm is nil")
+		return nil, errors.Wrapf(missingTransformError(message.TypeEncryptionAlgorithm), "NewChildSAKeyByProposal")
 	}
 
-	if len(proposal.IntegrityAlgorithm) == 0 {
-		return nil, errors.Errorf("NewChildSAKeyByProposal : IntegrityAlgorithm is nil")
+	o := newOptions(opts)
+	if len(proposal.ExtendedSequenceNumbers) == 0 && !o.permissiveESN {
+		return nil, errors.Wrapf(ErrMissingESNTransform, "NewChildSAKeyByProposal")
 	}
 
-	if len(proposal.ExtendedSequenceNumbers) == 0 {
-		return nil, errors.Errorf("NewChildSAKeyByProposal : ExtendedSequenceNumbers is nil")
+	if err := checkTransformTypes(proposal); err != nil {
+		return nil, errors.Wrapf(err, "NewChildSAKeyByProposal")
+	}
+	if err := checkUnexpectedTransforms(proposal); err != nil {
+		return nil, errors.Wrapf(err, "NewChildSAKeyByProposal")
 	}
 
 	childsaKey := new(ChildSAKey)
+	childsaKey.ProposalNumber = proposal.ProposalNumber
 	if len(proposal.DiffieHellmanGroup) == 1 {
 		childsaKey.DhInfo = dh.DecodeTransform(proposal.DiffieHellmanGroup[0])
 		if childsaKey.DhInfo == nil {
-			return nil, errors.Errorf("NewChildSAKeyByProposal : Get unsupport DiffieHellmanGroup[%v]",
-				proposal.DiffieHellmanGroup[0].TransformID)
+			return nil, errors.Wrapf(newUnsupportedDHGroupError(proposal.DiffieHellmanGroup[0].TransformID),
+				"NewChildSAKeyByProposal")
 		}
 	}
 
 	childsaKey.EncrKInfo = encr.DecodeTransformChildSA(proposal.EncryptionAlgorithm[0])
 	if childsaKey.EncrKInfo == nil {
+		if err := encr.CheckTransform(proposal.EncryptionAlgorithm[0]); err != nil {
+			return nil, errors.Wrapf(err, "NewChildSAKeyByProposal")
+		}
 		return nil, errors.Errorf("NewChildSAKeyByProposal : Get unsupport EncryptionAlgorithm[%v]",
 			proposal.EncryptionAlgorithm[0].TransformID)
 	}
 
-	if len(proposal.IntegrityAlgorithm) == 1 {
+	if childsaKey.EncrKInfo.IsAEAD() {
+		if err := checkAEADIntegrity(proposal); err != nil {
+			return nil, errors.Wrapf(err, "NewChildSAKeyByProposal")
+		}
+	} else if len(proposal.IntegrityAlgorithm) == 0 {
+		return nil, errors.Wrapf(missingTransformError(message.TypeIntegrityAlgorithm), "NewChildSAKeyByProposal")
+	} else if len(proposal.IntegrityAlgorithm) == 1 {
 		childsaKey.IntegKInfo = integ.DecodeTransformChildSA(proposal.IntegrityAlgorithm[0])
 		if childsaKey.IntegKInfo == nil {
 			return nil, errors.Errorf("NewChildSAKeyByProposal : Get unsupport IntegrityAlgorithm[%v]",
@@ -379,19 +906,91 @@ func NewChildSAKeyByProposal(proposal *message.Proposal) (*ChildSAKey, error) {
 		}
 	}
 
-	var err error
-	childsaKey.EsnInfo, err = esn.DecodeTransform(proposal.ExtendedSequenceNumbers[0])
-	if err != nil {
-		return nil, errors.Wrapf(err, "NewChildSAKeyByProposal")
+	if len(proposal.ExtendedSequenceNumbers) != 0 {
+		var err error
+		childsaKey.EsnInfo, err = esn.DecodeTransform(proposal.ExtendedSequenceNumbers[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "NewChildSAKeyByProposal")
+		}
+	}
+
+	if proposal.ProtocolID == message.TypeESP || proposal.ProtocolID == message.TypeAH {
+		spi, err := childSPI(proposal)
+		if err != nil {
+			return nil, errors.Wrapf(err, "NewChildSAKeyByProposal")
+		}
+		childsaKey.SPI = spi
 	}
 
 	return childsaKey, nil
 }
 
+// HasPFS reports whether the child SA keys are derived with a Diffie-Hellman
+// exchange, providing perfect forward secrecy
+func (childsaKey *ChildSAKey) HasPFS() bool {
+	return childsaKey.DhInfo != nil
+}
+
+// PFSStrength returns the security strength in bits of the Diffie-Hellman
+// group of the child SA, or 0 if the child SA has no perfect forward secrecy
+func (childsaKey *ChildSAKey) PFSStrength() int {
+	if childsaKey.DhInfo == nil {
+		return 0
+	}
+	return childsaKey.DhInfo.GetSecurityStrength()
+}
+
+var ErrDHGroupMismatch = errors.New("child SA and IKE SA Diffie-Hellman groups differ")
+
+// ValidateChildDHMatchesIKE checks that the Diffie-Hellman group of the child
+// SA, if any, is the group of the IKE SA. A child SA without perfect forward
+// secrecy always passes. The check is not required by RFC 7296, the caller
+// decides whether to enforce it.
+func ValidateChildDHMatchesIKE(ikeSA *IKESAKey, childSA *ChildSAKey) error {
+	if ikeSA == nil || childSA == nil {
+		return errors.Errorf("ValidateChildDHMatchesIKE : SA is nil")
+	}
+	if childSA.DhInfo == nil {
+		return nil
+	}
+	if ikeSA.DhInfo == nil {
+		return errors.Errorf("ValidateChildDHMatchesIKE : No Diffie-hellman group algorithm specified")
+	}
+	if childSA.DhInfo.TransformID() != ikeSA.DhInfo.TransformID() {
+		return errors.Wrapf(ErrDHGroupMismatch, "child SA group %d, IKE SA group %d",
+			childSA.DhInfo.TransformID(), ikeSA.DhInfo.TransformID())
+	}
+	return nil
+}
+
+// DirectionalKeys returns the keys of the child SA oriented for installation
+// on the local side: outbound keys protect the traffic sent to the peer and
+// inbound keys the traffic received from it.
+func (childsaKey *ChildSAKey) DirectionalKeys(localIsInitiator bool) (outEnc, outInteg, inEnc, inInteg []byte) {
+	if localIsInitiator {
+		return childsaKey.InitiatorToResponderEncryptionKey, childsaKey.InitiatorToResponderIntegrityKey,
+			childsaKey.ResponderToInitiatorEncryptionKey, childsaKey.ResponderToInitiatorIntegrityKey
+	}
+	return childsaKey.ResponderToInitiatorEncryptionKey, childsaKey.ResponderToInitiatorIntegrityKey,
+		childsaKey.InitiatorToResponderEncryptionKey, childsaKey.InitiatorToResponderIntegrityKey
+}
+
+var ErrParentSKdMismatch = errors.New("child SA keys derived from another IKE SA")
+
 // Key Gen for child SA
+// The keys are derived from the SK_d of ikeSA, which changes when the IKE SA
+// is rekeyed: after an IKE SA rekey, the new IKESAKey must be passed. The
+// first successful call records a fingerprint of the SK_d of ikeSA, and a
+// later call on the same ChildSAKey with an IKE SA holding another SK_d
+// returns ErrParentSKdMismatch. The check is skipped when ikeSA has no SK_d.
+// Any BindTranscript on ikeSA must come before the first call.
+// Only the WithKDF option applies: SK_d is expanded with the Prf_d of ikeSA,
+// which a PRF factory or key store given to the IKE SA already built, and the
+// seed is Ni | Nr without any label.
 func (childsaKey *ChildSAKey) GenerateKeyForChildSA(
 	ikeSA *IKESAKey,
 	concatenatedNonce []byte,
+	opts ...Option,
 ) error {
 	// Check parameters
 	if ikeSA == nil {
@@ -411,6 +1010,19 @@ func (childsaKey *ChildSAKey) GenerateKeyForChildSA(
 	if ikeSA.Prf_d == nil {
 		return errors.Errorf("No key deriving key")
 	}
+	var fingerprint []byte
+	if ikeSA.hasSKd() {
+		// A key held by a key store is identified by its handle
+		sum := sha256.Sum256(ikeSA.SK_d)
+		if len(ikeSA.SK_d) == 0 {
+			sum = sha256.Sum256([]byte(ikeSA.KeyHandles[KeyLabelSK_d]))
+		}
+		fingerprint = sum[:]
+		if childsaKey.parentSKdFingerprint != nil &&
+			!bytes.Equal(childsaKey.parentSKdFingerprint, fingerprint) {
+			return errors.WithStack(ErrParentSKdMismatch)
+		}
+	}
 
 	// Get key length for encryption and integrity key for IPSec
 	var lengthEncryptionKeyIPSec, lengthIntegrityKeyIPSec, totalKeyLength int
@@ -418,36 +1030,75 @@ func (childsaKey *ChildSAKey) GenerateKeyForChildSA(
 	lengthEncryptionKeyIPSec = childsaKey.EncrKInfo.GetKeyLength()
 	if childsaKey.IntegKInfo != nil {
 		lengthIntegrityKeyIPSec = childsaKey.IntegKInfo.GetKeyLength()
+		if lengthIntegrityKeyIPSec <= 0 {
+			return errors.Errorf("Integrity algorithm[%d] has invalid key length %d",
+				childsaKey.IntegKInfo.TransformID(), lengthIntegrityKeyIPSec)
+		}
 	}
 	totalKeyLength = (lengthEncryptionKeyIPSec + lengthIntegrityKeyIPSec) * 2
 
 	// Generate key for child security association as specified in RFC 7296 section 2.17
 	seed := concatenatedNonce
 
-	keyStream := lib.PrfPlus(ikeSA.Prf_d, seed, totalKeyLength)
-	if keyStream == nil {
-		return errors.Errorf("Error happened in PrfPlus")
+	keyStream, err := expand(newOptions(opts).kdf, ikeSA.Prf_d, seed, totalKeyLength)
+	if err != nil {
+		return err
 	}
 
-	childsaKey.InitiatorToResponderEncryptionKey = append(
-		childsaKey.InitiatorToResponderEncryptionKey,
+	// The keys are replaced, so that deriving them again does not extend
+	// the keys of a previous derivation
+	childsaKey.InitiatorToResponderEncryptionKey = append([]byte(nil),
 		keyStream[:lengthEncryptionKeyIPSec]...)
 	keyStream = keyStream[lengthEncryptionKeyIPSec:]
-	childsaKey.InitiatorToResponderIntegrityKey = append(
-		childsaKey.InitiatorToResponderIntegrityKey,
+	childsaKey.InitiatorToResponderIntegrityKey = append([]byte(nil),
 		keyStream[:lengthIntegrityKeyIPSec]...)
 	keyStream = keyStream[lengthIntegrityKeyIPSec:]
-	childsaKey.ResponderToInitiatorEncryptionKey = append(
-		childsaKey.ResponderToInitiatorEncryptionKey,
+	childsaKey.ResponderToInitiatorEncryptionKey = append([]byte(nil),
 		keyStream[:lengthEncryptionKeyIPSec]...)
 	keyStream = keyStream[lengthEncryptionKeyIPSec:]
-	childsaKey.ResponderToInitiatorIntegrityKey = append(
-		childsaKey.ResponderToInitiatorIntegrityKey,
+	childsaKey.ResponderToInitiatorIntegrityKey = append([]byte(nil),
 		keyStream[:lengthIntegrityKeyIPSec]...)
 
+	if fingerprint != nil {
+		childsaKey.parentSKdFingerprint = fingerprint
+	}
+	ikeSA.childSAKeysDerived = true
 	return nil
 }
 
+// DeriveChildKeysFromSKd derives the keys of a child SA using encrK and
+// integK from the key deriving key skd of the IKE SA, without a full
+// IKESAKey. integK may be nil for a combined mode cipher.
+func DeriveChildKeysFromSKd(
+	skd []byte,
+	prfType prf.PRFType,
+	encrK encr.ENCRKType,
+	integK integ.INTEGKType,
+	concatenatedNonce []byte,
+	opts ...Option,
+) (*ChildSAKey, error) {
+	if prfType == nil {
+		return nil, errors.Errorf("DeriveChildKeysFromSKd : No pseudorandom function specified")
+	}
+	if len(skd) == 0 {
+		return nil, errors.Errorf("DeriveChildKeysFromSKd : No SK_d")
+	}
+
+	ikeSA := &IKESAKey{
+		PrfInfo: prfType,
+		SK_d:    skd,
+		Prf_d:   prfType.Init(skd),
+	}
+	childsaKey := &ChildSAKey{
+		EncrKInfo:  encrK,
+		IntegKInfo: integK,
+	}
+	if err := childsaKey.GenerateKeyForChildSA(ikeSA, concatenatedNonce, opts...); err != nil {
+		return nil, errors.Wrapf(err, "DeriveChildKeysFromSKd")
+	}
+	return childsaKey, nil
+}
+
 // Certificate
 func CompareRootCertificate(
 	ca []byte,
@@ -4,16 +4,21 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"hash"
+	"math/big"
 	"sync"
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 
 	"github.com/nathaniel-bennett/ike/message"
+	ikeCrypto "github.com/nathaniel-bennett/ike/security/IKECrypto"
 	"github.com/nathaniel-bennett/ike/security/dh"
 	"github.com/nathaniel-bennett/ike/security/encr"
 	"github.com/nathaniel-bennett/ike/security/esn"
 	"github.com/nathaniel-bennett/ike/security/integ"
+	"github.com/nathaniel-bennett/ike/security/lib"
 	"github.com/nathaniel-bennett/ike/security/prf"
 )
 
@@ -171,6 +176,11 @@ func TestGenerateKeyForIKESA(t *testing.T) {
 		initiatorSPI, responderSPI)
 	require.Error(t, err)
 
+	// Reflected SPI
+	err = ikesaKey.GenerateKeyForIKESA(concatenatedNonce, diffieHellmanSharedKey,
+		initiatorSPI, initiatorSPI)
+	require.ErrorIs(t, err, ErrReflectedSPI)
+
 	// Normal case
 	err = ikesaKey.GenerateKeyForIKESA(concatenatedNonce, diffieHellmanSharedKey,
 		initiatorSPI, responderSPI)
@@ -326,3 +336,992 @@ func TestChildSetProposal(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestNewChildSAKeyInvalidSPI(t *testing.T) {
+	proposal := new(message.Proposal)
+	proposal.ProtocolID = message.TypeESP
+	encrKTranform, err := encr.ToTransformChildSA(encr.StrToKType("ENCR_AES_CBC_256"))
+	require.NoError(t, err)
+	proposal.EncryptionAlgorithm = append(proposal.EncryptionAlgorithm, encrKTranform)
+	proposal.IntegrityAlgorithm = append(proposal.IntegrityAlgorithm,
+		integ.ToTransformChildSA(integ.StrToKType("AUTH_HMAC_SHA1_96")))
+	proposal.ExtendedSequenceNumbers = append(proposal.ExtendedSequenceNumbers,
+		&message.Transform{TransformType: message.TypeExtendedSequenceNumbers, TransformID: message.ESN_DISABLE})
+
+	// No SPI, a short one, and an 8 bytes IKE SPI
+	for _, spi := range [][]byte{nil, {0x01, 0x02, 0x03}, {0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}} {
+		proposal.SPI = spi
+		_, err = NewChildSAKeyByProposal(proposal)
+		require.ErrorIs(t, err, ErrInvalidChildSPI, "SPI size %d", len(spi))
+		require.Equal(t, uint16(message.INVALID_SYNTAX), NotifyTypeForError(err))
+	}
+
+	proposal.SPI = []byte{0xc0, 0x01, 0xca, 0xfe}
+	childsaKey, err := NewChildSAKeyByProposal(proposal)
+	require.NoError(t, err)
+	require.Equal(t, uint32(0xc001cafe), childsaKey.SPI)
+
+	// ToProposal sends the SPI back
+	proposal, err = childsaKey.ToProposal()
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xc0, 0x01, 0xca, 0xfe}, proposal.SPI)
+	roundTrip, err := NewChildSAKeyByProposal(proposal)
+	require.NoError(t, err)
+	require.Equal(t, childsaKey.SPI, roundTrip.SPI)
+}
+
+func TestWipe(t *testing.T) {
+	ikesaKey := newTestLabelIKESAKey(t)
+	keys := [][]byte{
+		ikesaKey.SK_d, ikesaKey.SK_ai, ikesaKey.SK_ar, ikesaKey.SK_ei,
+		ikesaKey.SK_er, ikesaKey.SK_pi, ikesaKey.SK_pr,
+	}
+
+	childsaKey := &ChildSAKey{
+		EncrKInfo:  encr.StrToKType("ENCR_AES_CBC_256"),
+		IntegKInfo: integ.StrToKType("AUTH_HMAC_SHA1_96"),
+	}
+	require.NoError(t, childsaKey.GenerateKeyForChildSA(ikesaKey, []byte{0x01, 0x02}))
+	childKeys := [][]byte{
+		childsaKey.InitiatorToResponderEncryptionKey, childsaKey.ResponderToInitiatorEncryptionKey,
+		childsaKey.InitiatorToResponderIntegrityKey, childsaKey.ResponderToInitiatorIntegrityKey,
+	}
+
+	ikesaKey.Wipe()
+	for i, key := range keys {
+		require.NotEmpty(t, key, "key %d", i)
+		require.Equal(t, make([]byte, len(key)), key, "key %d", i)
+	}
+	require.Nil(t, ikesaKey.SK_d)
+	require.Nil(t, ikesaKey.SK_pr)
+	require.Nil(t, ikesaKey.Prf_d)
+	require.Nil(t, ikesaKey.Integ_i)
+	require.Nil(t, ikesaKey.Integ_r)
+	require.Nil(t, ikesaKey.Encr_i)
+	require.Nil(t, ikesaKey.Encr_r)
+	require.Nil(t, ikesaKey.Prf_i)
+	require.Nil(t, ikesaKey.Prf_r)
+	// The transforms are kept
+	require.NotNil(t, ikesaKey.PrfInfo)
+
+	childsaKey.Wipe()
+	for i, key := range childKeys {
+		require.NotEmpty(t, key, "key %d", i)
+		require.Equal(t, make([]byte, len(key)), key, "key %d", i)
+	}
+	require.Nil(t, childsaKey.InitiatorToResponderEncryptionKey)
+	require.Nil(t, childsaKey.ResponderToInitiatorIntegrityKey)
+
+	// Wiping twice or a nil SA does nothing
+	ikesaKey.Wipe()
+	childsaKey.Wipe()
+	(*IKESAKey)(nil).Wipe()
+	(*ChildSAKey)(nil).Wipe()
+}
+
+func TestChildSANATTraversal(t *testing.T) {
+	childsaKey := &ChildSAKey{
+		EncrKInfo:  encr.StrToKType("ENCR_AES_CBC_256"),
+		IntegKInfo: integ.StrToKType("AUTH_HMAC_SHA1_96"),
+	}
+	esnType, err := esn.StrToType("ESN_ENABLE")
+	require.NoError(t, err)
+	childsaKey.EsnInfo = esnType
+
+	// NAT traversal is not a transform, so it is not carried in the proposal
+	childsaKey.NATTraversal = true
+	proposal, err := childsaKey.ToProposal()
+	require.NoError(t, err)
+	newChildsaKey, err := NewChildSAKeyByProposal(proposal)
+	require.NoError(t, err)
+	require.False(t, newChildsaKey.NATTraversal)
+	newChildsaKey.NATTraversal = true
+	require.True(t, newChildsaKey.NATTraversal)
+
+	// The flag must not change the keymat
+	ikeSAKey := &IKESAKey{
+		PrfInfo: prf.StrToType("PRF_HMAC_SHA1"),
+	}
+	sk_d, err := hex.DecodeString("276e1a8f0d65dae5309da66277ff7c82d39a8956")
+	require.NoError(t, err)
+	ikeSAKey.Prf_d = ikeSAKey.PrfInfo.Init(sk_d)
+
+	err = childsaKey.GenerateKeyForChildSA(ikeSAKey, nil)
+	require.NoError(t, err)
+	newChildsaKey.NATTraversal = false
+	err = newChildsaKey.GenerateKeyForChildSA(ikeSAKey, nil)
+	require.NoError(t, err)
+	require.Equal(t, childsaKey.InitiatorToResponderEncryptionKey, newChildsaKey.InitiatorToResponderEncryptionKey)
+	require.Equal(t, childsaKey.ResponderToInitiatorIntegrityKey, newChildsaKey.ResponderToInitiatorIntegrityKey)
+}
+
+func TestCalculateDiffieHellmanMaterialsEmptyPeerValue(t *testing.T) {
+	ikesaKey := &IKESAKey{
+		DhInfo: dh.StrToType("DH_2048_BIT_MODP"),
+	}
+
+	_, _, err := CalculateDiffieHellmanMaterials(ikesaKey, nil)
+	require.ErrorIs(t, err, ErrEmptyKeyExchangeData)
+	_, _, err = CalculateDiffieHellmanMaterials(ikesaKey, []byte{})
+	require.ErrorIs(t, err, ErrEmptyKeyExchangeData)
+	require.Equal(t, uint16(message.INVALID_SYNTAX), NotifyTypeForError(err))
+
+	_, _, err = NewIKESAKey(newTestIKEProposal(t, 1, "ENCR_AES_CBC_128", "AUTH_HMAC_SHA1_96"),
+		nil, []byte{0x01, 0x02, 0x03, 0x04}, 0x123, 0x456)
+	require.ErrorIs(t, err, ErrEmptyKeyExchangeData)
+}
+
+func TestICVOffset(t *testing.T) {
+	ikesaKey := &IKESAKey{
+		EncrInfo:  encr.StrToType("ENCR_AES_CBC_256"),
+		IntegInfo: integ.StrToType("AUTH_HMAC_SHA2_256_128"),
+	}
+
+	// 16 bytes IV, 32 bytes ciphertext and 16 bytes ICV
+	offset, icvLen := ikesaKey.ICVOffset(64)
+	require.Equal(t, 48, offset)
+	require.Equal(t, 16, icvLen)
+
+	ikesaKey.IntegInfo = integ.StrToType("AUTH_HMAC_SHA1_96")
+	offset, icvLen = ikesaKey.ICVOffset(60)
+	require.Equal(t, 48, offset)
+	require.Equal(t, 12, icvLen)
+
+	// Payload shorter than the ICV
+	offset, icvLen = ikesaKey.ICVOffset(8)
+	require.Equal(t, 0, offset)
+	require.Equal(t, 12, icvLen)
+}
+
+func TestChildSADirectionalKeys(t *testing.T) {
+	childsaKey := &ChildSAKey{
+		InitiatorToResponderEncryptionKey: []byte{0x01},
+		InitiatorToResponderIntegrityKey:  []byte{0x02},
+		ResponderToInitiatorEncryptionKey: []byte{0x03},
+		ResponderToInitiatorIntegrityKey:  []byte{0x04},
+	}
+
+	outEnc, outInteg, inEnc, inInteg := childsaKey.DirectionalKeys(true)
+	require.Equal(t, []byte{0x01}, outEnc)
+	require.Equal(t, []byte{0x02}, outInteg)
+	require.Equal(t, []byte{0x03}, inEnc)
+	require.Equal(t, []byte{0x04}, inInteg)
+
+	outEnc, outInteg, inEnc, inInteg = childsaKey.DirectionalKeys(false)
+	require.Equal(t, []byte{0x03}, outEnc)
+	require.Equal(t, []byte{0x04}, outInteg)
+	require.Equal(t, []byte{0x01}, inEnc)
+	require.Equal(t, []byte{0x02}, inInteg)
+}
+
+func TestSingleDESProposalRejected(t *testing.T) {
+	proposal := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA1_96")
+	proposal.EncryptionAlgorithm[0] = &message.Transform{
+		TransformType: message.TypeEncryptionAlgorithm,
+		TransformID:   message.ENCR_DES,
+	}
+
+	_, _, err := NewIKESAKey(proposal, []byte{0x05, 0x06, 0x07, 0x08},
+		[]byte{0x01, 0x02, 0x03, 0x04}, 0x123, 0x456)
+	require.ErrorIs(t, err, encr.ErrInsecureAlgorithmRejected)
+	require.Equal(t, uint16(message.NO_PROPOSAL_CHOSEN), NotifyTypeForError(err))
+
+	proposal.ProtocolID = message.TypeESP
+	proposal.PseudorandomFunction = nil
+	proposal.ExtendedSequenceNumbers = append(proposal.ExtendedSequenceNumbers,
+		&message.Transform{TransformType: message.TypeExtendedSequenceNumbers, TransformID: message.ESN_DISABLE})
+	_, err = NewChildSAKeyByProposal(proposal)
+	require.ErrorIs(t, err, encr.ErrInsecureAlgorithmRejected)
+}
+
+func TestAesCbcInvalidKeyLengthProposalRejected(t *testing.T) {
+	proposal := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA1_96")
+	proposal.EncryptionAlgorithm[0].AttributeValue = 160
+
+	_, _, err := NewIKESAKey(proposal, []byte{0x05, 0x06, 0x07, 0x08},
+		[]byte{0x01, 0x02, 0x03, 0x04}, 0x123, 0x456)
+	require.ErrorIs(t, err, encr.ErrInvalidKeyLength)
+	require.Equal(t, uint16(message.NO_PROPOSAL_CHOSEN), NotifyTypeForError(err))
+}
+
+func TestBindTranscript(t *testing.T) {
+	transcriptHash := []byte{0x0a, 0x0b, 0x0c, 0x0d}
+
+	ikesaKey := newTestLabelIKESAKey(t)
+	standardSK_d := ikesaKey.SK_d
+	standardSK_ei := ikesaKey.SK_ei
+
+	err := ikesaKey.BindTranscript(transcriptHash)
+	require.NoError(t, err)
+	require.NotEqual(t, standardSK_d, ikesaKey.SK_d)
+	require.Equal(t, standardSK_ei, ikesaKey.SK_ei)
+	require.Equal(t, ikesaKey.PrfInfo.Init(ikesaKey.SK_d), ikesaKey.Prf_d)
+
+	again := newTestLabelIKESAKey(t)
+	err = again.BindTranscript(transcriptHash)
+	require.NoError(t, err)
+	require.Equal(t, ikesaKey.SK_d, again.SK_d)
+
+	other := newTestLabelIKESAKey(t)
+	err = other.BindTranscript([]byte{0x0e})
+	require.NoError(t, err)
+	require.NotEqual(t, ikesaKey.SK_d, other.SK_d)
+
+	err = other.BindTranscript(nil)
+	require.Error(t, err)
+	err = new(IKESAKey).BindTranscript(transcriptHash)
+	require.Error(t, err)
+}
+
+func TestGenerateKeyForIKESAMixedPRFAndIntegrity(t *testing.T) {
+	// PRF of the SHA2 family with integrity of the SHA1 family: SK_d, SK_pi
+	// and SK_pr take the 32 bytes PRF key length while SK_ai and SK_ar take
+	// the 20 bytes integrity key length
+	ikesaKey := &IKESAKey{
+		DhInfo:    dh.StrToType("DH_2048_BIT_MODP"),
+		EncrInfo:  encr.StrToType("ENCR_AES_CBC_256"),
+		IntegInfo: integ.StrToType("AUTH_HMAC_SHA1_96"),
+		PrfInfo:   prf.StrToType("PRF_HMAC_SHA2_256"),
+	}
+	err := ikesaKey.GenerateKeyForIKESA([]byte{0x01, 0x02, 0x03, 0x04},
+		[]byte{0x05, 0x06, 0x07, 0x08}, 0x456, 0x123)
+	require.NoError(t, err)
+
+	expected := []struct {
+		name string
+		key  []byte
+		hex  string
+	}{
+		{"SK_d", ikesaKey.SK_d, "42f50b0a433d9420264a608cdb4b7dbeec5ea29297d8f7dc444ab22d637463ca"},
+		{"SK_ai", ikesaKey.SK_ai, "03c07626c682be8d8d22dc56a3b754e8be3d54e0"},
+		{"SK_ar", ikesaKey.SK_ar, "68eafb5088bb661ac325176f452f7650c7e09176"},
+		{"SK_ei", ikesaKey.SK_ei, "76df26c5f4b9313cccaa9b5157f720ec01d7227fdd249407232907a573648d3e"},
+		{"SK_er", ikesaKey.SK_er, "a2fee44ea855d990d6aae221e75529215bc52d70e6be180ed96f511814054689"},
+		{"SK_pi", ikesaKey.SK_pi, "051faf2ce69aee838241508259a6f7fe3fe3bc2c9706f49d20d24befd1fb73f9"},
+		{"SK_pr", ikesaKey.SK_pr, "7abc6380fa210b3cac345c82bb52b1ea2dd332e9b64c44511f26915256c5133a"},
+	}
+	for _, e := range expected {
+		expectedKey, err := hex.DecodeString(e.hex)
+		require.NoError(t, err)
+		require.Equal(t, expectedKey, e.key, "%s does not match expected value", e.name)
+	}
+
+	require.Len(t, ikesaKey.SK_d, ikesaKey.PrfInfo.GetKeyLength())
+	require.Len(t, ikesaKey.SK_pi, ikesaKey.PrfInfo.GetKeyLength())
+	require.Len(t, ikesaKey.SK_ai, ikesaKey.IntegInfo.GetKeyLength())
+}
+
+func TestChildSAPFS(t *testing.T) {
+	childsaKey := &ChildSAKey{
+		EncrKInfo:  encr.StrToKType("ENCR_AES_CBC_256"),
+		IntegKInfo: integ.StrToKType("AUTH_HMAC_SHA1_96"),
+	}
+	require.False(t, childsaKey.HasPFS())
+	require.Equal(t, 0, childsaKey.PFSStrength())
+
+	childsaKey.DhInfo = dh.StrToType("DH_1024_BIT_MODP")
+	require.True(t, childsaKey.HasPFS())
+	require.Equal(t, 80, childsaKey.PFSStrength())
+
+	childsaKey.DhInfo = dh.StrToType("DH_2048_BIT_MODP")
+	require.True(t, childsaKey.HasPFS())
+	require.Equal(t, 112, childsaKey.PFSStrength())
+}
+
+func TestValidateChildDHMatchesIKE(t *testing.T) {
+	ikesaKey := &IKESAKey{
+		DhInfo: dh.StrToType("DH_2048_BIT_MODP"),
+	}
+	childsaKey := &ChildSAKey{}
+
+	// No PFS
+	require.NoError(t, ValidateChildDHMatchesIKE(ikesaKey, childsaKey))
+
+	childsaKey.DhInfo = dh.StrToType("DH_2048_BIT_MODP")
+	require.NoError(t, ValidateChildDHMatchesIKE(ikesaKey, childsaKey))
+
+	childsaKey.DhInfo = dh.StrToType("DH_1024_BIT_MODP")
+	err := ValidateChildDHMatchesIKE(ikesaKey, childsaKey)
+	require.ErrorIs(t, err, ErrDHGroupMismatch)
+
+	require.Error(t, ValidateChildDHMatchesIKE(nil, childsaKey))
+}
+
+func TestIKESAKeyFingerprint(t *testing.T) {
+	ikesaKey := newTestLabelIKESAKey(t)
+	fingerprint := ikesaKey.Fingerprint(0x456, 0x123)
+	require.Len(t, fingerprint, 64)
+
+	// Keys do not affect the fingerprint
+	labeled := newTestLabelIKESAKey(t, WithLabel([]byte("label")))
+	require.NotEqual(t, ikesaKey.SK_d, labeled.SK_d)
+	require.Equal(t, fingerprint, labeled.Fingerprint(0x456, 0x123))
+	require.Equal(t, fingerprint, (&IKESAKey{
+		DhInfo:    ikesaKey.DhInfo,
+		EncrInfo:  ikesaKey.EncrInfo,
+		IntegInfo: ikesaKey.IntegInfo,
+		PrfInfo:   ikesaKey.PrfInfo,
+	}).Fingerprint(0x456, 0x123))
+
+	// SPIs do
+	require.NotEqual(t, fingerprint, ikesaKey.Fingerprint(0x123, 0x456))
+
+	// Transforms do, including the key length
+	other := *ikesaKey
+	other.EncrInfo = encr.StrToType("ENCR_AES_CBC_128")
+	require.NotEqual(t, fingerprint, other.Fingerprint(0x456, 0x123))
+	other = *ikesaKey
+	other.IntegInfo = integ.StrToType("AUTH_HMAC_SHA2_256_128")
+	require.NotEqual(t, fingerprint, other.Fingerprint(0x456, 0x123))
+	other = *ikesaKey
+	other.DhInfo = dh.StrToType("DH_1024_BIT_MODP")
+	require.NotEqual(t, fingerprint, other.Fingerprint(0x456, 0x123))
+}
+
+func TestChildSAProposalMissingESN(t *testing.T) {
+	proposal := new(message.Proposal)
+	encrKTranform, err := encr.ToTransformChildSA(encr.StrToKType("ENCR_AES_CBC_256"))
+	require.NoError(t, err)
+	proposal.EncryptionAlgorithm = append(proposal.EncryptionAlgorithm, encrKTranform)
+	proposal.IntegrityAlgorithm = append(proposal.IntegrityAlgorithm,
+		integ.ToTransformChildSA(integ.StrToKType("AUTH_HMAC_SHA1_96")))
+
+	// Strict
+	_, err = NewChildSAKeyByProposal(proposal)
+	require.ErrorIs(t, err, ErrMissingESNTransform)
+	require.Equal(t, uint16(message.NO_PROPOSAL_CHOSEN), NotifyTypeForError(err))
+
+	// Permissive
+	childsaKey, err := NewChildSAKeyByProposal(proposal, WithPermissiveESN())
+	require.NoError(t, err)
+	require.False(t, childsaKey.EsnInfo.GetNeedESN())
+	require.Equal(t, uint16(message.ESN_DISABLE), childsaKey.EsnInfo.TransformID())
+
+	// The permissive option still honours an offered ESN transform
+	esnType, err := esn.StrToType("ESN_ENABLE")
+	require.NoError(t, err)
+	proposal.ExtendedSequenceNumbers = append(proposal.ExtendedSequenceNumbers, esn.ToTransform(esnType))
+	childsaKey, err = NewChildSAKeyByProposal(proposal, WithPermissiveESN())
+	require.NoError(t, err)
+	require.True(t, childsaKey.EsnInfo.GetNeedESN())
+}
+
+func TestGenerateNonce(t *testing.T) {
+	testcases := []struct {
+		prf    string
+		length int
+	}{
+		{"PRF_HMAC_MD5", 16},
+		{"PRF_HMAC_SHA1", 16},
+		{"PRF_HMAC_SHA2_256", 16},
+		{"PRF_HMAC_SHA2_384", 24},
+		{"PRF_HMAC_SHA2_512", 32},
+		{"PRF_AES128_XCBC", 16},
+		{"PRF_AES128_CMAC", 16},
+	}
+
+	for _, tc := range testcases {
+		prfType := prf.StrToType(tc.prf)
+		nonce, err := GenerateNonce(prfType)
+		require.NoError(t, err, tc.prf)
+		require.Len(t, nonce, tc.length, tc.prf)
+		require.GreaterOrEqual(t, len(nonce), prfType.GetKeyLength()/2, tc.prf)
+
+		other, err := GenerateNonce(prfType)
+		require.NoError(t, err, tc.prf)
+		require.NotEqual(t, nonce, other, tc.prf)
+	}
+
+	_, err := GenerateNonce(nil)
+	require.Error(t, err)
+}
+
+// failingENCRType fails to create the crypto of the responder
+type failingENCRType struct {
+	encr.ENCRType
+	calls int
+}
+
+func (t *failingENCRType) NewCrypto(key []byte) (ikeCrypto.IKECrypto, error) {
+	t.calls++
+	if t.calls > 1 {
+		return nil, errors.New("NewCrypto failed")
+	}
+	return t.ENCRType.NewCrypto(key)
+}
+
+func TestGenerateKeyForIKESANewCryptoFailure(t *testing.T) {
+	ikesaKey := &IKESAKey{
+		DhInfo:    dh.StrToType("DH_2048_BIT_MODP"),
+		EncrInfo:  &failingENCRType{ENCRType: encr.StrToType("ENCR_AES_CBC_256")},
+		IntegInfo: integ.StrToType("AUTH_HMAC_SHA1_96"),
+		PrfInfo:   prf.StrToType("PRF_HMAC_SHA1"),
+	}
+
+	err := ikesaKey.GenerateKeyForIKESA([]byte{0x01, 0x02, 0x03, 0x04},
+		[]byte{0x05, 0x06, 0x07, 0x08}, 0x456, 0x123)
+	require.Error(t, err)
+
+	require.Nil(t, ikesaKey.SK_d)
+	require.Nil(t, ikesaKey.SK_ai)
+	require.Nil(t, ikesaKey.SK_ar)
+	require.Nil(t, ikesaKey.SK_ei)
+	require.Nil(t, ikesaKey.SK_er)
+	require.Nil(t, ikesaKey.SK_pi)
+	require.Nil(t, ikesaKey.SK_pr)
+	require.Nil(t, ikesaKey.Prf_d)
+	require.Nil(t, ikesaKey.Integ_i)
+	require.Nil(t, ikesaKey.Integ_r)
+	require.Nil(t, ikesaKey.Encr_i)
+	require.Nil(t, ikesaKey.Encr_r)
+	require.Nil(t, ikesaKey.Prf_i)
+	require.Nil(t, ikesaKey.Prf_r)
+}
+
+// zeroKeyINTEGType is a misregistered integrity algorithm without key
+type zeroKeyINTEGType struct {
+	integ.INTEGType
+}
+
+func (t *zeroKeyINTEGType) GetKeyLength() int {
+	return 0
+}
+
+type zeroKeyINTEGKType struct {
+	integ.INTEGKType
+}
+
+func (t *zeroKeyINTEGKType) GetKeyLength() int {
+	return 0
+}
+
+func TestGenerateKeyZeroIntegrityKeyLength(t *testing.T) {
+	ikesaKey := &IKESAKey{
+		DhInfo:    dh.StrToType("DH_2048_BIT_MODP"),
+		EncrInfo:  encr.StrToType("ENCR_AES_CBC_256"),
+		IntegInfo: &zeroKeyINTEGType{INTEGType: integ.StrToType("AUTH_HMAC_SHA1_96")},
+		PrfInfo:   prf.StrToType("PRF_HMAC_SHA1"),
+	}
+	err := ikesaKey.GenerateKeyForIKESA([]byte{0x01, 0x02, 0x03, 0x04},
+		[]byte{0x05, 0x06, 0x07, 0x08}, 0x456, 0x123)
+	require.Error(t, err)
+	require.Nil(t, ikesaKey.Integ_i)
+
+	childsaKey := &ChildSAKey{
+		EncrKInfo:  encr.StrToKType("ENCR_AES_CBC_256"),
+		IntegKInfo: &zeroKeyINTEGKType{INTEGKType: integ.StrToKType("AUTH_HMAC_SHA1_96")},
+	}
+	err = childsaKey.GenerateKeyForChildSA(newTestLabelIKESAKey(t), []byte{0x01, 0x02, 0x03, 0x04})
+	require.Error(t, err)
+	require.Empty(t, childsaKey.InitiatorToResponderIntegrityKey)
+}
+
+func TestGenerateSecretForGroup(t *testing.T) {
+	testcases := []struct {
+		name      string
+		bitLength int
+	}{
+		{"DH_1024_BIT_MODP", 160},
+		{"DH_2048_BIT_MODP", 224},
+	}
+
+	for _, tc := range testcases {
+		dhType := dh.StrToType(tc.name)
+		require.Equal(t, tc.bitLength, dhType.GetSecretBitLength(), tc.name)
+		for i := 0; i < 20; i++ {
+			secret, err := GenerateSecretForGroup(dhType)
+			require.NoError(t, err)
+			require.Equal(t, tc.bitLength, secret.Int().BitLen(), tc.name)
+		}
+	}
+
+	_, err := GenerateSecretForGroup(nil)
+	require.Error(t, err)
+}
+
+func TestGenerateKeyForIKESASmallSharedKey(t *testing.T) {
+	dhType := dh.StrToType("DH_2048_BIT_MODP")
+
+	// A peer public value of 1 makes the shared key 1, encoded with 255
+	// leading zero bytes
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	sharedKey, err := dhType.GetSharedKey(secret, []byte{0x01})
+	require.NoError(t, err)
+	require.Len(t, sharedKey, 256)
+	require.Equal(t, make([]byte, 255), sharedKey[:255])
+	require.Equal(t, byte(1), sharedKey[255])
+
+	newPeer := func(sharedKey []byte) *IKESAKey {
+		ikesaKey := &IKESAKey{
+			DhInfo:    dhType,
+			EncrInfo:  encr.StrToType("ENCR_AES_CBC_256"),
+			IntegInfo: integ.StrToType("AUTH_HMAC_SHA2_256_128"),
+			PrfInfo:   prf.StrToType("PRF_HMAC_SHA2_256"),
+		}
+		err := ikesaKey.GenerateKeyForIKESA([]byte{0x01, 0x02, 0x03, 0x04},
+			sharedKey, 0x456, 0x123)
+		require.NoError(t, err)
+		return ikesaKey
+	}
+
+	// Both peers hold the full length encoding and derive the same keys
+	initiator := newPeer(append([]byte(nil), sharedKey...))
+	responder := newPeer(append([]byte(nil), sharedKey...))
+	require.Equal(t, initiator.SK_d, responder.SK_d)
+	require.Equal(t, initiator.SK_ei, responder.SK_ei)
+	require.Equal(t, initiator.SK_ar, responder.SK_ar)
+	require.Equal(t, initiator.SK_pr, responder.SK_pr)
+
+	// Dropping the leading zeros would change the keys
+	stripped := newPeer([]byte{0x01})
+	require.NotEqual(t, initiator.SK_d, stripped.SK_d)
+}
+
+func TestDeriveChildKeysFromSKd(t *testing.T) {
+	ikesaKey := newTestLabelIKESAKey(t)
+	nonce := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+
+	childsaKey := &ChildSAKey{
+		EncrKInfo:  encr.StrToKType("ENCR_AES_CBC_256"),
+		IntegKInfo: integ.StrToKType("AUTH_HMAC_SHA2_256_128"),
+	}
+	err := childsaKey.GenerateKeyForChildSA(ikesaKey, nonce)
+	require.NoError(t, err)
+
+	derived, err := DeriveChildKeysFromSKd(ikesaKey.SK_d, ikesaKey.PrfInfo,
+		encr.StrToKType("ENCR_AES_CBC_256"), integ.StrToKType("AUTH_HMAC_SHA2_256_128"), nonce)
+	require.NoError(t, err)
+	require.Equal(t, childsaKey.InitiatorToResponderEncryptionKey, derived.InitiatorToResponderEncryptionKey)
+	require.Equal(t, childsaKey.InitiatorToResponderIntegrityKey, derived.InitiatorToResponderIntegrityKey)
+	require.Equal(t, childsaKey.ResponderToInitiatorEncryptionKey, derived.ResponderToInitiatorEncryptionKey)
+	require.Equal(t, childsaKey.ResponderToInitiatorIntegrityKey, derived.ResponderToInitiatorIntegrityKey)
+
+	_, err = DeriveChildKeysFromSKd(nil, ikesaKey.PrfInfo,
+		encr.StrToKType("ENCR_AES_CBC_256"), nil, nonce)
+	require.Error(t, err)
+	_, err = DeriveChildKeysFromSKd(ikesaKey.SK_d, nil,
+		encr.StrToKType("ENCR_AES_CBC_256"), nil, nonce)
+	require.Error(t, err)
+	_, err = DeriveChildKeysFromSKd(ikesaKey.SK_d, ikesaKey.PrfInfo, nil, nil, nonce)
+	require.Error(t, err)
+}
+
+func TestIKESAProposalPRFErrors(t *testing.T) {
+	proposal := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA1_96")
+
+	// No PRF offered
+	noPRF := *proposal
+	noPRF.PseudorandomFunction = nil
+	_, _, err := NewIKESAKey(&noPRF, []byte{0x05, 0x06, 0x07, 0x08},
+		[]byte{0x01, 0x02, 0x03, 0x04}, 0x123, 0x456)
+	require.ErrorIs(t, err, ErrNoPRF)
+	require.NotErrorIs(t, err, ErrUnsupportedPRF)
+	require.Equal(t, uint16(message.NO_PROPOSAL_CHOSEN), NotifyTypeForError(err))
+
+	// PRF_HMAC_TIGER offered
+	unsupported := *proposal
+	unsupported.PseudorandomFunction = message.TransformContainer{
+		{TransformType: message.TypePseudorandomFunction, TransformID: message.PRF_HMAC_TIGER},
+	}
+	_, _, err = NewIKESAKey(&unsupported, []byte{0x05, 0x06, 0x07, 0x08},
+		[]byte{0x01, 0x02, 0x03, 0x04}, 0x123, 0x456)
+	require.ErrorIs(t, err, ErrUnsupportedPRF)
+	require.NotErrorIs(t, err, ErrNoPRF)
+	require.Equal(t, uint16(message.NO_PROPOSAL_CHOSEN), NotifyTypeForError(err))
+}
+
+func TestGenerateKeyForIKESAFromNonces(t *testing.T) {
+	// Same keys as TestGenerateKeyForIKESA, with the nonce 0x01020304 split
+	// into Ni and Nr
+	standard := newTestLabelIKESAKey(t)
+
+	ikesaKey := &IKESAKey{
+		DhInfo:    standard.DhInfo,
+		EncrInfo:  standard.EncrInfo,
+		IntegInfo: standard.IntegInfo,
+		PrfInfo:   standard.PrfInfo,
+	}
+	err := ikesaKey.GenerateKeyForIKESAFromNonces([]byte{0x01, 0x02}, []byte{0x03, 0x04},
+		[]byte{0x05, 0x06, 0x07, 0x08}, 0x456, 0x123)
+	require.NoError(t, err)
+	require.Equal(t, standard.SK_d, ikesaKey.SK_d)
+	require.Equal(t, standard.SK_ei, ikesaKey.SK_ei)
+	require.Equal(t, standard.SK_pr, ikesaKey.SK_pr)
+
+	// Swapped nonces derive other keys
+	err = ikesaKey.GenerateKeyForIKESAFromNonces([]byte{0x03, 0x04}, []byte{0x01, 0x02},
+		[]byte{0x05, 0x06, 0x07, 0x08}, 0x456, 0x123)
+	require.NoError(t, err)
+	require.NotEqual(t, standard.SK_d, ikesaKey.SK_d)
+
+	err = ikesaKey.GenerateKeyForIKESAFromNonces(nil, []byte{0x01, 0x02},
+		[]byte{0x05, 0x06, 0x07, 0x08}, 0x456, 0x123)
+	require.Error(t, err)
+}
+
+func TestGenerateKeyForIKESAPRFKeyLengths(t *testing.T) {
+	// SK_d, SK_pi and SK_pr are as long as the preferred key of the PRF
+	testcases := []struct {
+		prf       string
+		keyLength int
+	}{
+		{"PRF_HMAC_SHA1", 20},
+		{"PRF_HMAC_SHA2_256", 32},
+		{"PRF_HMAC_SHA2_384", 48},
+		{"PRF_HMAC_SHA2_512", 64},
+	}
+
+	for _, tc := range testcases {
+		ikesaKey := &IKESAKey{
+			DhInfo:    dh.StrToType("DH_2048_BIT_MODP"),
+			EncrInfo:  encr.StrToType("ENCR_AES_CBC_256"),
+			IntegInfo: integ.StrToType("AUTH_HMAC_SHA2_256_128"),
+			PrfInfo:   prf.StrToType(tc.prf),
+		}
+		require.Equal(t, tc.keyLength, ikesaKey.PrfInfo.GetOutputLength(), tc.prf)
+
+		err := ikesaKey.GenerateKeyForIKESA(bytes.Repeat([]byte{0x01}, 64),
+			[]byte{0x05, 0x06, 0x07, 0x08}, 0x456, 0x123)
+		require.NoError(t, err, tc.prf)
+		require.Len(t, ikesaKey.SK_d, tc.keyLength, tc.prf)
+		require.Len(t, ikesaKey.SK_pi, tc.keyLength, tc.prf)
+		require.Len(t, ikesaKey.SK_pr, tc.keyLength, tc.prf)
+		require.Len(t, ikesaKey.SK_ai, 32, tc.prf)
+		require.Len(t, ikesaKey.SK_ei, 32, tc.prf)
+
+		transform := prf.ToTransform(ikesaKey.PrfInfo)
+		require.Equal(t, ikesaKey.PrfInfo, prf.DecodeTransform(transform), tc.prf)
+	}
+}
+
+func TestGenerateKeyForIKESAWithAESMAC(t *testing.T) {
+	// Ni = 00..0f, Nr = 10..1f and g^ir = 05060708. SKEYSEED and SK_d were
+	// computed independently, with AES-XCBC built from AES-ECB and with the
+	// AES-CMAC of OpenSSL.
+	testcases := []struct {
+		integ    string
+		prf      string
+		skeyseed string
+		skd      string
+	}{
+		// SKEYSEED keyed by Ni[0..63] | Nr[0..63]
+		{
+			"AUTH_AES_XCBC_96", "PRF_AES128_XCBC",
+			"bdb862fd3e4a8226cf16d037e01ad814", "23514df327ee8ef4b1cf6f41d381ea04",
+		},
+		{
+			"AUTH_AES_CMAC_96", "PRF_AES128_CMAC",
+			"984ba1183b7993037401066dc7862750", "61fced84eedef7819add536ef088cce4",
+		},
+	}
+
+	initiatorNonce, responderNonce := make([]byte, 16), make([]byte, 16)
+	for i := range initiatorNonce {
+		initiatorNonce[i], responderNonce[i] = byte(i), byte(16+i)
+	}
+	for _, tc := range testcases {
+		ikesaKey := &IKESAKey{
+			DhInfo:    dh.StrToType("DH_2048_BIT_MODP"),
+			EncrInfo:  encr.StrToType("ENCR_AES_CBC_128"),
+			IntegInfo: integ.StrToType(tc.integ),
+			PrfInfo:   prf.StrToType(tc.prf),
+		}
+
+		// The second PRF keyed is prf+, by SKEYSEED
+		var prfKeys [][]byte
+		newPRF := func(key []byte) hash.Hash {
+			prfKeys = append(prfKeys, append([]byte(nil), key...))
+			return ikesaKey.PrfInfo.Init(key)
+		}
+		err := ikesaKey.GenerateKeyForIKESAFromNonces(initiatorNonce, responderNonce,
+			[]byte{0x05, 0x06, 0x07, 0x08}, 0x456, 0x123, WithPRFFactory(newPRF))
+		require.NoError(t, err, tc.prf)
+		require.Equal(t, tc.skeyseed, hex.EncodeToString(prfKeys[1]), tc.prf)
+		require.Equal(t, tc.skd, hex.EncodeToString(ikesaKey.SK_d), tc.prf)
+
+		// The nonces split in the middle give the same keys
+		concatenated := &IKESAKey{
+			DhInfo:    ikesaKey.DhInfo,
+			EncrInfo:  ikesaKey.EncrInfo,
+			IntegInfo: ikesaKey.IntegInfo,
+			PrfInfo:   ikesaKey.PrfInfo,
+		}
+		err = concatenated.GenerateKeyForIKESA(append(append([]byte(nil), initiatorNonce...), responderNonce...),
+			[]byte{0x05, 0x06, 0x07, 0x08}, 0x456, 0x123)
+		require.NoError(t, err, tc.prf)
+		require.Equal(t, ikesaKey.SK_d, concatenated.SK_d, tc.prf)
+		require.NotNil(t, ikesaKey.Integ_i, tc.prf)
+		require.NotEqual(t, ikesaKey.SK_ai, ikesaKey.SK_ar, tc.prf)
+	}
+}
+
+func TestUnsupportedDHGroupError(t *testing.T) {
+	proposal := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA2_256_128")
+	proposal.DiffieHellmanGroup[0].TransformID = message.DH_1536_BIT_MODP
+
+	_, _, err := NewIKESAKey(proposal, []byte{0x01}, []byte{0x02}, 0x123, 0x456)
+	require.ErrorIs(t, err, ErrUnsupportedDHGroup)
+	require.Equal(t, uint16(message.INVALID_KE_PAYLOAD), NotifyTypeForError(err))
+
+	var dhErr *UnsupportedDHGroupError
+	require.ErrorAs(t, err, &dhErr)
+	require.Equal(t, uint16(message.DH_1536_BIT_MODP), dhErr.TransformID)
+	require.Equal(t, dh.SupportedGroupsByPreference(), dhErr.SupportedGroups)
+	require.Equal(t, uint16(message.DH_521_BIT_RANDOM_ECP), dhErr.SupportedGroups[0])
+}
+
+func TestNewIKESAKeyUnsupportedIntegrity(t *testing.T) {
+	proposal := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA2_256_128")
+	proposal.IntegrityAlgorithm[0].TransformID = 0xfff0
+
+	require.NotPanics(t, func() {
+		ikesaKey, _, err := NewIKESAKey(proposal, []byte{0x05, 0x06, 0x07, 0x08},
+			[]byte{0x01, 0x02, 0x03, 0x04}, 0x123, 0x456)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unsupport IntegrityAlgorithm[65520]")
+		require.Nil(t, ikesaKey)
+	})
+}
+
+func TestCalculateDiffieHellmanMaterialsInvalidPublicValue(t *testing.T) {
+	ikesaKey := &IKESAKey{
+		DhInfo: dh.StrToType("DH_1024_BIT_MODP"),
+	}
+	p, ok := new(big.Int).SetString(dh.Group2PrimeString, 16)
+	require.True(t, ok)
+
+	for _, peerPublicValue := range [][]byte{
+		{0x00},
+		make([]byte, 128),
+		{0x01},
+		new(big.Int).Sub(p, big.NewInt(1)).Bytes(),
+		p.Bytes(),
+	} {
+		_, _, err := CalculateDiffieHellmanMaterials(ikesaKey, peerPublicValue)
+		require.ErrorIs(t, err, dh.ErrInvalidPublicValue)
+		require.Equal(t, uint16(message.INVALID_SYNTAX), NotifyTypeForError(err))
+	}
+
+	_, sharedKey, err := CalculateDiffieHellmanMaterials(ikesaKey, []byte{0x02})
+	require.NoError(t, err)
+	require.Len(t, sharedKey, 128)
+}
+
+func TestNewIKESAKeyCurve25519(t *testing.T) {
+	proposal := newTestIKEProposal(t, 1, "ENCR_AES_CBC_256", "AUTH_HMAC_SHA2_256_128")
+	proposal.DiffieHellmanGroup[0].TransformID = message.DH_CURVE25519
+	dhType := dh.StrToType("DH_CURVE25519")
+	nonce := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+
+	peerSecret, err := dhType.GenerateSecret()
+	require.NoError(t, err)
+	peerPublicValue, err := dhType.GetPublicValue(peerSecret)
+	require.NoError(t, err)
+
+	ikesaKey, localPublicValue, err := NewIKESAKey(proposal, peerPublicValue, nonce, 0x123, 0x456)
+	require.NoError(t, err)
+	require.Len(t, localPublicValue, 32)
+
+	// The peer derives the same keys from the 32 bytes shared key
+	sharedKey, err := dhType.GetSharedKey(peerSecret, localPublicValue)
+	require.NoError(t, err)
+	require.Len(t, sharedKey, 32)
+	peerKey, err := newIKESAKeyByProposal(proposal)
+	require.NoError(t, err)
+	err = peerKey.GenerateKeyForIKESA(nonce, sharedKey, 0x123, 0x456)
+	require.NoError(t, err)
+	require.Equal(t, ikesaKey.SK_d, peerKey.SK_d)
+
+	_, _, err = NewIKESAKey(proposal, make([]byte, 32), nonce, 0x123, 0x456)
+	require.ErrorIs(t, err, dh.ErrInvalidPublicValue)
+	require.Equal(t, uint16(message.INVALID_SYNTAX), NotifyTypeForError(err))
+}
+
+func TestFragmentationThreshold(t *testing.T) {
+	ikesaKey := &IKESAKey{
+		EncrInfo:  encr.StrToType("ENCR_AES_CBC_256"),
+		IntegInfo: integ.StrToType("AUTH_HMAC_SHA2_256_128"),
+	}
+
+	// 1500 - IPv4 (20) - UDP (8) - IKE header (28) - SK header (4) - IV (16)
+	// - ICV (16) leaves 1408 bytes, that is 88 blocks including the pad
+	// length byte
+	threshold := ikesaKey.FragmentationThreshold(1500)
+	require.Equal(t, 1407, threshold)
+
+	encrypter, err := ikesaKey.EncrInfo.NewCrypto(make([]byte, ikesaKey.EncrInfo.GetKeyLength()))
+	require.NoError(t, err)
+	overhead := 20 + 8 + message.IKE_HEADER_LEN + 4 + ikesaKey.IntegInfo.GetOutputLength()
+
+	cipherText, err := encrypter.Encrypt(make([]byte, threshold))
+	require.NoError(t, err)
+	require.Equal(t, 1500, overhead+len(cipherText))
+
+	cipherText, err = encrypter.Encrypt(make([]byte, threshold+1))
+	require.NoError(t, err)
+	require.Greater(t, overhead+len(cipherText), 1500)
+
+	require.Equal(t, 0, ikesaKey.FragmentationThreshold(64))
+}
+
+func TestNewIKESAKeyAEAD(t *testing.T) {
+	proposal := newTestIKEProposal(t, 1, "ENCR_AES_GCM_16_256")
+
+	ikesaKey, _, err := NewIKESAKey(proposal, []byte{0x05, 0x06, 0x07, 0x08},
+		[]byte{0x01, 0x02, 0x03, 0x04}, 0x123, 0x456)
+	require.NoError(t, err)
+	require.Nil(t, ikesaKey.IntegInfo)
+	require.Empty(t, ikesaKey.SK_ai)
+	require.Empty(t, ikesaKey.SK_ar)
+	require.Nil(t, ikesaKey.Integ_i)
+	require.Nil(t, ikesaKey.Integ_r)
+	require.Len(t, ikesaKey.SK_ei, 36)
+	require.Len(t, ikesaKey.SK_er, 36)
+	require.NotEqual(t, ikesaKey.SK_ei, ikesaKey.SK_er)
+
+	offset, icvLen := ikesaKey.ICVOffset(100)
+	require.Equal(t, 84, offset)
+	require.Equal(t, 16, icvLen)
+
+	// Round trip between the two ends, which use the same SK_ei
+	peerEncr, err := ikesaKey.EncrInfo.NewCrypto(ikesaKey.SK_ei)
+	require.NoError(t, err)
+	cipherText, err := ikesaKey.Encr_i.Encrypt([]byte("payloads"))
+	require.NoError(t, err)
+	plainText, err := peerEncr.Decrypt(cipherText)
+	require.NoError(t, err)
+	require.Equal(t, []byte("payloads"), plainText)
+
+	cipherText[len(cipherText)-1] ^= 0x01
+	_, err = peerEncr.Decrypt(cipherText)
+	require.ErrorIs(t, err, encr.ErrAEADAuthFailed)
+
+	responseProposal, err := ikesaKey.ToProposal()
+	require.NoError(t, err)
+	require.Empty(t, responseProposal.IntegrityAlgorithm)
+	require.NoError(t, ikesaKey.CompatibleWith(responseProposal))
+
+	// An integrity algorithm other than NONE is not allowed with AES-GCM
+	proposal = newTestIKEProposal(t, 1, "ENCR_AES_GCM_16_256", "AUTH_HMAC_SHA1_96")
+	_, _, err = NewIKESAKey(proposal, []byte{0x05, 0x06, 0x07, 0x08},
+		[]byte{0x01, 0x02, 0x03, 0x04}, 0x123, 0x456)
+	require.Error(t, err)
+
+	proposal.IntegrityAlgorithm[0].TransformID = message.AUTH_NONE
+	_, _, err = NewIKESAKey(proposal, []byte{0x05, 0x06, 0x07, 0x08},
+		[]byte{0x01, 0x02, 0x03, 0x04}, 0x123, 0x456)
+	require.NoError(t, err)
+}
+
+func TestNewIKESAKeyAesCtr(t *testing.T) {
+	// AES-CTR has no integrity of its own: it requires an integrity algorithm
+	proposal := newTestIKEProposal(t, 1, "ENCR_AES_CTR_128")
+	_, _, err := NewIKESAKey(proposal, []byte{0x05, 0x06, 0x07, 0x08},
+		[]byte{0x01, 0x02, 0x03, 0x04}, 0x123, 0x456)
+	require.Error(t, err)
+
+	proposal = newTestIKEProposal(t, 1, "ENCR_AES_CTR_128", "AUTH_HMAC_SHA2_256_128")
+	ikesaKey, _, err := NewIKESAKey(proposal, []byte{0x05, 0x06, 0x07, 0x08},
+		[]byte{0x01, 0x02, 0x03, 0x04}, 0x123, 0x456)
+	require.NoError(t, err)
+	require.NotNil(t, ikesaKey.Integ_i)
+	require.Len(t, ikesaKey.SK_ei, 20)
+	require.Len(t, ikesaKey.SK_ai, 32)
+}
+
+func TestNewChildSAKeyAEAD(t *testing.T) {
+	esnType, err := esn.StrToType("ESN_ENABLE")
+	require.NoError(t, err)
+
+	proposal := new(message.Proposal)
+	proposal.ProtocolID = message.TypeESP
+	proposal.SPI = []byte{0x01, 0x02, 0x03, 0x04}
+	encrTransform, err := encr.ToTransformChildSA(encr.StrToKType("ENCR_AES_GCM_16_128"))
+	require.NoError(t, err)
+	proposal.EncryptionAlgorithm = append(proposal.EncryptionAlgorithm, encrTransform)
+	proposal.ExtendedSequenceNumbers = append(proposal.ExtendedSequenceNumbers, esn.ToTransform(esnType))
+
+	childsaKey, err := NewChildSAKeyByProposal(proposal)
+	require.NoError(t, err)
+	require.Nil(t, childsaKey.IntegKInfo)
+
+	ikesaKey := newTestLabelIKESAKey(t)
+	require.NoError(t, childsaKey.GenerateKeyForChildSA(ikesaKey, []byte{0x01, 0x02}))
+	require.Len(t, childsaKey.InitiatorToResponderEncryptionKey, 20)
+	require.Len(t, childsaKey.ResponderToInitiatorEncryptionKey, 20)
+	require.Empty(t, childsaKey.InitiatorToResponderIntegrityKey)
+	require.Empty(t, childsaKey.ResponderToInitiatorIntegrityKey)
+}
+
+func TestGenerateKeyForChildSATwice(t *testing.T) {
+	ikesaKey := newTestLabelIKESAKey(t)
+	childsaKey := &ChildSAKey{
+		EncrKInfo:  encr.StrToKType("ENCR_AES_CBC_256"),
+		IntegKInfo: integ.StrToKType("AUTH_HMAC_SHA1_96"),
+	}
+
+	require.NoError(t, childsaKey.GenerateKeyForChildSA(ikesaKey, []byte{0x01, 0x02}))
+	first := *childsaKey
+
+	require.NoError(t, childsaKey.GenerateKeyForChildSA(ikesaKey, []byte{0x01, 0x02}))
+	require.Len(t, childsaKey.InitiatorToResponderEncryptionKey, 32)
+	require.Len(t, childsaKey.InitiatorToResponderIntegrityKey, 20)
+	require.Len(t, childsaKey.ResponderToInitiatorEncryptionKey, 32)
+	require.Len(t, childsaKey.ResponderToInitiatorIntegrityKey, 20)
+	require.Equal(t, first.InitiatorToResponderEncryptionKey, childsaKey.InitiatorToResponderEncryptionKey)
+	require.Equal(t, first.ResponderToInitiatorIntegrityKey, childsaKey.ResponderToInitiatorIntegrityKey)
+
+	// Other nonces replace the keys
+	require.NoError(t, childsaKey.GenerateKeyForChildSA(ikesaKey, []byte{0x03, 0x04}))
+	require.Len(t, childsaKey.InitiatorToResponderEncryptionKey, 32)
+	require.NotEqual(t, first.InitiatorToResponderEncryptionKey, childsaKey.InitiatorToResponderEncryptionKey)
+}
+
+func TestDeriveKeysFromSKEYSEED(t *testing.T) {
+	newKey := func() *IKESAKey {
+		return &IKESAKey{
+			EncrInfo:  encr.StrToType("ENCR_AES_CBC_128"),
+			IntegInfo: integ.StrToType("AUTH_HMAC_SHA1_96"),
+			PrfInfo:   prf.StrToType("PRF_HMAC_SHA1"),
+			DhInfo:    dh.StrToType("DH_2048_BIT_MODP"),
+		}
+	}
+	nonce := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+	sharedKey := []byte{0x0a, 0x0b, 0x0c, 0x0d}
+
+	// A SKEYSEED computed by another feature goes through the same tail:
+	// prf+(SKEYSEED, Ni | Nr | SPIi | SPIr) sliced into the seven keys
+	skeyseed := bytes.Repeat([]byte{0x5a}, 20)
+	ikesaKey := newKey()
+	err := ikesaKey.deriveKeysFromSKEYSEED(skeyseed, nonce, 0x123, 0x456, newOptions(nil))
+	require.NoError(t, err)
+
+	keymat := lib.PrfPlus(ikesaKey.PrfInfo.Init(skeyseed),
+		concatenateNonceAndSPI(nonce, 0x123, 0x456), 20+20+20+16+16+20+20)
+	require.Equal(t, keymat[:20], ikesaKey.SK_d)
+	require.Equal(t, keymat[20:40], ikesaKey.SK_ai)
+	require.Equal(t, keymat[40:60], ikesaKey.SK_ar)
+	require.Equal(t, keymat[60:76], ikesaKey.SK_ei)
+	require.Equal(t, keymat[76:92], ikesaKey.SK_er)
+	require.Equal(t, keymat[92:112], ikesaKey.SK_pi)
+	require.Equal(t, keymat[112:132], ikesaKey.SK_pr)
+	require.NotNil(t, ikesaKey.Prf_d)
+	require.NotNil(t, ikesaKey.Integ_i)
+	require.NotNil(t, ikesaKey.Encr_r)
+
+	// GenerateKeyForIKESA is the tail fed with SKEYSEED = prf(Ni | Nr, g^ir)
+	standard := newKey()
+	err = standard.GenerateKeyForIKESA(nonce, sharedKey, 0x123, 0x456)
+	require.NoError(t, err)
+
+	h := standard.PrfInfo.Init(nonce)
+	_, err = h.Write(sharedKey)
+	require.NoError(t, err)
+	tail := newKey()
+	err = tail.deriveKeysFromSKEYSEED(h.Sum(nil), nonce, 0x123, 0x456, newOptions(nil))
+	require.NoError(t, err)
+	require.Equal(t, standard.SK_d, tail.SK_d)
+	require.Equal(t, standard.SK_ei, tail.SK_ei)
+	require.Equal(t, standard.SK_pr, tail.SK_pr)
+}
@@ -0,0 +1,98 @@
+package security
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/nathaniel-bennett/ike/security/dh"
+	"github.com/nathaniel-bennett/ike/security/encr"
+	"github.com/nathaniel-bennett/ike/security/integ"
+	"github.com/nathaniel-bennett/ike/security/prf"
+)
+
+// ikeSAKeyVector is an IKE SA key derivation test vector. Algorithms are
+// given by name, SPIs, nonces, shared key and keys as hex strings.
+type ikeSAKeyVector struct {
+	Name           string `json:"name"`
+	Encr           string `json:"encr"`
+	Integ          string `json:"integ"`
+	Prf            string `json:"prf"`
+	Dh             string `json:"dh"`
+	InitiatorSPI   string `json:"initiatorSPI"`
+	ResponderSPI   string `json:"responderSPI"`
+	InitiatorNonce string `json:"initiatorNonce"`
+	ResponderNonce string `json:"responderNonce"`
+	SharedKey      string `json:"sharedKey"`
+	SK_d           string `json:"SK_d"`
+	SK_ai          string `json:"SK_ai"`
+	SK_ar          string `json:"SK_ar"`
+	SK_ei          string `json:"SK_ei"`
+	SK_er          string `json:"SK_er"`
+	SK_pi          string `json:"SK_pi"`
+	SK_pr          string `json:"SK_pr"`
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+func decodeSPI(t *testing.T, s string) uint64 {
+	spi, err := strconv.ParseUint(s, 16, 64)
+	require.NoError(t, err)
+	return spi
+}
+
+// TestIKESAKeyVectors runs every vector of testdata/*_vectors.json through
+// GenerateKeyForIKESAFromNonces. New vectors, such as ones captured from
+// other implementations, only need to be added to these files.
+func TestIKESAKeyVectors(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*_vectors.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+
+		var vectors []ikeSAKeyVector
+		require.NoError(t, json.Unmarshal(data, &vectors), file)
+
+		for _, v := range vectors {
+			v := v
+			t.Run(v.Name, func(t *testing.T) {
+				ikesaKey := &IKESAKey{
+					DhInfo:    dh.StrToType(v.Dh),
+					EncrInfo:  encr.StrToType(v.Encr),
+					IntegInfo: integ.StrToType(v.Integ),
+					PrfInfo:   prf.StrToType(v.Prf),
+				}
+				require.NotNil(t, ikesaKey.DhInfo, v.Dh)
+				require.NotNil(t, ikesaKey.EncrInfo, v.Encr)
+				require.NotNil(t, ikesaKey.IntegInfo, v.Integ)
+				require.NotNil(t, ikesaKey.PrfInfo, v.Prf)
+
+				err := ikesaKey.GenerateKeyForIKESAFromNonces(
+					decodeHex(t, v.InitiatorNonce), decodeHex(t, v.ResponderNonce),
+					decodeHex(t, v.SharedKey),
+					decodeSPI(t, v.InitiatorSPI), decodeSPI(t, v.ResponderSPI))
+				require.NoError(t, err)
+
+				require.Equal(t, decodeHex(t, v.SK_d), ikesaKey.SK_d, "SK_d")
+				require.Equal(t, decodeHex(t, v.SK_ai), ikesaKey.SK_ai, "SK_ai")
+				require.Equal(t, decodeHex(t, v.SK_ar), ikesaKey.SK_ar, "SK_ar")
+				require.Equal(t, decodeHex(t, v.SK_ei), ikesaKey.SK_ei, "SK_ei")
+				require.Equal(t, decodeHex(t, v.SK_er), ikesaKey.SK_er, "SK_er")
+				require.Equal(t, decodeHex(t, v.SK_pi), ikesaKey.SK_pi, "SK_pi")
+				require.Equal(t, decodeHex(t, v.SK_pr), ikesaKey.SK_pr, "SK_pr")
+			})
+		}
+	}
+}